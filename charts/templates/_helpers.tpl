@@ -31,3 +31,29 @@ Selector labels
 app.kubernetes.io/name: {{ include "lxd-csi-driver.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end }}
+
+{{/*
+Name of the CSI driver.
+*/}}
+{{- define "lxd-csi-driver.driverName" -}}
+{{- default "lxd.csi.canonical.com" .Values.driver.name }}
+{{- end }}
+
+{{/*
+Startup probe of the CSI plugin containers. The plugin is not ready while it
+waits on startup for devLXD to become reachable, therefore the probe allows
+the whole startup timeout (plus one period) before the container is restarted.
+The liveness probe only takes effect once the startup probe succeeds.
+*/}}
+{{- define "lxd-csi-driver.startupProbe" -}}
+startupProbe:
+  httpGet:
+    {{- if .host }}
+    host: {{ .host }}
+    {{- end }}
+    path: /healthz
+    port: 39008
+  failureThreshold: {{ add (div (add .timeoutSeconds 9) 10) 1 }}
+  timeoutSeconds: 10
+  periodSeconds: 10
+{{- end }}
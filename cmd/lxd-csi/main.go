@@ -1,25 +1,55 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"os"
 
 	"k8s.io/klog/v2"
 
+	"github.com/canonical/lxd-csi-driver/internal/devlxd"
 	"github.com/canonical/lxd-csi-driver/internal/driver"
 )
 
 var (
 	driverName       = flag.String("driver-name", driver.DefaultDriverName, "Name of the CSI driver")
 	endpoint         = flag.String("endpoint", driver.DefaultDriverEndpoint, "CSI endpoint (unix socket path)")
-	devLXDEndpoint   = flag.String("devlxd-endpoint", driver.DefaultDevLXDEndpoint, "Devlxd endpoint (devlxd unix socket path)")
+	devLXDEndpoint   = flag.String("devlxd-endpoint", driver.DefaultDevLXDEndpoint, "Devlxd endpoint (devlxd unix socket path or remote https address)")
+	devLXDClientCert = flag.String("devlxd-client-cert", "", "Path to the client certificate used for remote https devlxd endpoint")
+	devLXDClientKey  = flag.String("devlxd-client-key", "", "Path to the client key used for remote https devlxd endpoint")
+	devLXDServerCert = flag.String("devlxd-server-cert", "", "Path to the server certificate of remote https devlxd endpoint")
 	volumeNamePrefix = flag.String("volume-name-prefix", driver.DefaultVolumeNamePrefix, "Prefix used for LXD volume names")
 	nodeID           = flag.String("node-id", "", "Kubernetes node ID")
 	isController     = flag.Bool("controller", false, "Start LXD CSI driver controller server")
+	poolAliases      = flag.String("storage-pool-aliases", "", "Comma-separated list of renamed storage pools in format \"<old>=<new>\"")
+	topologyKey      = flag.String("topology-key", driver.AnnotationLXDClusterMember, "Topology segment key that specifies the LXD cluster member")
+	reconcileDevices = flag.Bool("reconcile-publish-devices", false, "Replace an existing disk device that does not match the published volume instead of failing")
+	deleteDryRun     = flag.Bool("delete-volume-dry-run", false, "Log volumes that would be deleted instead of deleting them (volumes are left in LXD)")
+	maintenanceMode  = flag.Bool("maintenance-mode", false, "Start in maintenance mode, in which volumes are not created or deleted but can be published (toggled at runtime with SIGUSR1)")
+	waitVolReady     = flag.Bool("wait-for-volume-ready", false, "Wait until a created volume can be retrieved with the expected content type before returning from CreateVolume")
+	sizeRoundWarn    = flag.Int("size-rounding-warning-threshold", driver.DefaultSizeRoundingWarningThreshold, "Percentage by which the allocated volume size may exceed the requested size before a warning is reported (0 disables the warning)")
+	metricsAddress   = flag.String("metrics-address", "", "Address on which to serve metrics (for example \":9808\"), disabled if empty")
+	debugAddress     = flag.String("debug-address", "", "Address on which to serve the read-only debug endpoint /debug/config reporting the effective configuration (for example \"localhost:9809\"), disabled if empty")
+	maxRetries       = flag.Int("max-retries", driver.DefaultMaxRetries, "Maximum number of retries of a request that fails with a transient LXD error (0 disables retries)")
+	validateSCs      = flag.Bool("validate-storage-classes", false, "Validate storage classes that use the driver on controller startup and log any problems")
+	trimInterval     = flag.Duration("trim-interval", driver.DefaultTrimInterval, "Interval at which the node trims published filesystem volumes that use periodic discard")
+	maxPoolOps       = flag.Int("max-concurrent-operations-per-pool", driver.DefaultMaxConcurrentOperationsPerPool, "Maximum number of concurrent operations per storage pool (0 means unlimited)")
+	startupTimeout   = flag.Duration("startup-timeout", driver.DefaultStartupTimeout, "Maximum time to wait on startup for devLXD to become reachable")
+	deleteGrace      = flag.Duration("delete-volume-grace-period", 0, "Time for which deleted volumes are only marked as deleted and can be recovered before they are permanently deleted (0 deletes volumes immediately)")
+	cloneShutdown    = flag.Duration("clone-shutdown-timeout", driver.DefaultCloneShutdownTimeout, "Maximum time to wait on shutdown for in-progress clones to complete before cancelling them")
+	publishBatch     = flag.Duration("publish-batch-window", 0, "Time to wait for further volumes published to the same node before attaching them in a single instance update (0 disables batching)")
+	detachNode       = flag.String("detach-node-volumes", "", "Detach all volumes managed by the driver from the given node and exit")
+	reportFeatures   = flag.Bool("report-storage-features", false, "Report CSI features available for the storage pools given as arguments (or for each supported storage driver if none are given) and exit")
 	showVersion      = flag.Bool("version", false, "Show driver version and exit")
 )
 
 func run() error {
+	storagePoolAliases, err := driver.ParseStoragePoolAliases(*poolAliases)
+	if err != nil {
+		return err
+	}
+
 	d := driver.NewDriver(driver.DriverOptions{
 		Name:             *driverName,
 		Endpoint:         *endpoint,
@@ -27,6 +57,30 @@ func run() error {
 		VolumeNamePrefix: *volumeNamePrefix,
 		NodeID:           *nodeID,
 		IsController:     *isController,
+
+		MaxConcurrentOperationsPerPool: *maxPoolOps,
+		StoragePoolAliases:             storagePoolAliases,
+		ReconcilePublishDevices:        *reconcileDevices,
+		TopologyKey:                    *topologyKey,
+		DeleteVolumeDryRun:             *deleteDryRun,
+		MaintenanceMode:                *maintenanceMode,
+		WaitForVolumeReady:             *waitVolReady,
+		SizeRoundingWarningThreshold:   *sizeRoundWarn,
+		MetricsAddress:                 *metricsAddress,
+		DebugAddress:                   *debugAddress,
+		MaxRetries:                     *maxRetries,
+		ValidateStorageClasses:         *validateSCs,
+		TrimInterval:                   *trimInterval,
+		StartupTimeout:                 *startupTimeout,
+		PublishBatchWindow:             *publishBatch,
+		DeleteVolumeGracePeriod:        *deleteGrace,
+		CloneShutdownTimeout:           *cloneShutdown,
+
+		DevLXDTLS: devlxd.TLSOptions{
+			ClientCertFile: *devLXDClientCert,
+			ClientKeyFile:  *devLXDClientKey,
+			ServerCertFile: *devLXDServerCert,
+		},
 	})
 
 	if *showVersion {
@@ -34,6 +88,19 @@ func run() error {
 		return nil
 	}
 
+	if *detachNode != "" {
+		volumes, err := d.DetachNodeVolumes(context.Background(), *detachNode)
+		for _, vol := range volumes {
+			fmt.Println("Detached volume", vol)
+		}
+
+		return err
+	}
+
+	if *reportFeatures {
+		return d.ReportStorageFeatures(os.Stdout, flag.Args())
+	}
+
 	return d.Run()
 }
 
@@ -1,13 +1,19 @@
 package devlxd
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/canonical/lxd-csi-driver/internal/utils"
 	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared"
 )
 
 const (
@@ -15,8 +21,38 @@ const (
 	devLXDUserAgent = "lxd-csi-driver"
 )
 
+// TLSOptions contains the TLS configuration used when connecting to
+// a remote devLXD endpoint over HTTPS.
+type TLSOptions struct {
+	// Path to the PEM encoded client certificate.
+	ClientCertFile string
+
+	// Path to the PEM encoded client key.
+	ClientKeyFile string
+
+	// Path to the PEM encoded server certificate. If empty, the server
+	// certificate is verified against the system CA pool.
+	ServerCertFile string
+}
+
 // Connect establishes a connection to the devLXD server at the specified endpoint.
-func Connect(endpoint string, bearerToken string) (lxdClient.DevLXDServer, error) {
+// The endpoint is either a local unix socket ("unix://") or a remote HTTPS
+// address ("https://"), in which case the TLS options are used to authenticate.
+func Connect(endpoint string, bearerToken string, tlsOpts TLSOptions) (lxdClient.DevLXDServer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse devLXD endpoint %q: %w", endpoint, err)
+	}
+
+	if u.Scheme == "https" {
+		return connectHTTPS(u, bearerToken, tlsOpts)
+	}
+
+	return connectUnix(endpoint, bearerToken)
+}
+
+// connectUnix establishes a connection to the devLXD server over a local unix socket.
+func connectUnix(endpoint string, bearerToken string) (lxdClient.DevLXDServer, error) {
 	// Parse and verify devLXD address.
 	_, socket, err := utils.ParseUnixSocketURL(endpoint)
 	if err != nil {
@@ -47,3 +83,85 @@ func Connect(endpoint string, bearerToken string) (lxdClient.DevLXDServer, error
 
 	return client, nil
 }
+
+// connectHTTPS establishes a connection to the remote devLXD server over HTTPS
+// using client certificate authentication.
+func connectHTTPS(endpoint *url.URL, bearerToken string, tlsOpts TLSOptions) (lxdClient.DevLXDServer, error) {
+	httpClient, err := newHTTPSClient(endpoint, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	connArgs := lxdClient.ConnectionArgs{
+		UserAgent:   devLXDUserAgent,
+		BearerToken: bearerToken,
+	}
+
+	client, err := lxdClient.ConnectDevLXDHTTPWithContext(context.Background(), &connArgs, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.InfoS("Connected to devLXD", "endpoint", endpoint.String())
+
+	return client, nil
+}
+
+// newHTTPSClient returns an HTTP client for the remote devLXD endpoint.
+//
+// The devLXD client sends requests to a fixed placeholder host, therefore
+// the returned client always dials the host of the given endpoint.
+func newHTTPSClient(endpoint *url.URL, tlsOpts TLSOptions) (*http.Client, error) {
+	if endpoint.Host == "" {
+		return nil, fmt.Errorf("Invalid devLXD endpoint %q: Host cannot be empty", endpoint.String())
+	}
+
+	if tlsOpts.ClientCertFile == "" || tlsOpts.ClientKeyFile == "" {
+		return nil, fmt.Errorf("Client certificate and key are required when connecting to devLXD endpoint %q", endpoint.String())
+	}
+
+	clientCert, err := os.ReadFile(tlsOpts.ClientCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading client certificate: %w", err)
+	}
+
+	clientKey, err := os.ReadFile(tlsOpts.ClientKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading client key: %w", err)
+	}
+
+	var serverCert []byte
+	if tlsOpts.ServerCertFile != "" {
+		serverCert, err = os.ReadFile(tlsOpts.ServerCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed reading server certificate: %w", err)
+		}
+	}
+
+	tlsConfig, err := shared.GetTLSConfigMem(string(clientCert), string(clientKey), "", string(serverCert), false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to configure TLS for devLXD endpoint %q: %w", endpoint.String(), err)
+	}
+
+	// Verify the server against the endpoint host unless the server name
+	// was already set from the pinned server certificate.
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = endpoint.Hostname()
+	}
+
+	host := endpoint.Host
+	if endpoint.Port() == "" {
+		host = net.JoinHostPort(endpoint.Hostname(), "8443")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, host)
+		},
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
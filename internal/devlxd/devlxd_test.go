@@ -0,0 +1,192 @@
+package devlxd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/canonical/lxd/shared"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// writeFile writes the given content into a file in the test's temporary directory.
+func writeFile(t *testing.T, name string, content []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, content, 0600)
+	require.NoError(t, err)
+	return path
+}
+
+// newTestServer starts an HTTPS server that requires a client certificate and
+// returns its endpoint along with the TLS options required to connect to it.
+func newTestServer(t *testing.T, handler http.HandlerFunc) (string, TLSOptions) {
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	clientCert, clientKey, err := shared.GenerateMemCert(true, shared.CertOptions{CommonName: "lxd-csi"})
+	require.NoError(t, err)
+
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	tlsOpts := TLSOptions{
+		ClientCertFile: writeFile(t, "client.crt", clientCert),
+		ClientKeyFile:  writeFile(t, "client.key", clientKey),
+		ServerCertFile: writeFile(t, "server.crt", serverCert),
+	}
+
+	return "https://" + srv.Listener.Addr().String(), tlsOpts
+}
+
+// writeResponse writes a successful synchronous response with the given metadata.
+func writeResponse(w http.ResponseWriter, metadata any) {
+	resp := api.ResponseRaw{
+		Type:       api.SyncResponse,
+		Status:     api.Success.String(),
+		StatusCode: int(api.Success),
+		Metadata:   metadata,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestConnectHTTPS(t *testing.T) {
+	endpoint, tlsOpts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		writeResponse(w, api.DevLXDGet{
+			DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+				Auth:     api.AuthTrusted,
+				Location: "member1",
+			},
+		})
+	})
+
+	client, err := Connect(endpoint, "token", tlsOpts)
+	require.NoError(t, err)
+
+	state, err := client.GetState()
+	require.NoError(t, err)
+	require.Equal(t, "member1", state.Location)
+}
+
+func TestConnectUseTargetConcurrent(t *testing.T) {
+	// Respond with the volume named after the requested target, so that
+	// each request reveals which target it was sent to.
+	endpoint, tlsOpts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/volumes/") {
+			writeResponse(w, api.DevLXDGet{DevLXDGetUntrusted: api.DevLXDGetUntrusted{Auth: api.AuthTrusted}})
+			return
+		}
+
+		writeResponse(w, api.DevLXDStorageVolume{Name: r.URL.Query().Get("target")})
+	})
+
+	client, err := Connect(endpoint, "token", tlsOpts)
+	require.NoError(t, err)
+
+	// Issue requests with different targets using the same shared client.
+	var wg sync.WaitGroup
+	for i := range 10 {
+		target := fmt.Sprintf("member%d", i)
+
+		wg.Go(func() {
+			for range 10 {
+				vol, _, err := client.UseTarget(target).GetStoragePoolVolume("default", "custom", "vol")
+				assert.NoError(t, err)
+				assert.Equal(t, target, vol.Name, "Request was sent to the wrong target")
+			}
+		})
+	}
+
+	wg.Wait()
+
+	// Ensure the shared client is not bound to any target.
+	vol, _, err := client.GetStoragePoolVolume("default", "custom", "vol")
+	require.NoError(t, err)
+	require.Empty(t, vol.Name)
+}
+
+func TestNewHTTPSClient(t *testing.T) {
+	clientCert, clientKey, err := shared.GenerateMemCert(true, shared.CertOptions{CommonName: "lxd-csi"})
+	require.NoError(t, err)
+
+	certFile := writeFile(t, "client.crt", clientCert)
+	keyFile := writeFile(t, "client.key", clientKey)
+
+	tests := []struct {
+		Name        string
+		Endpoint    string
+		TLSOptions  TLSOptions
+		expectError string
+	}{
+		{
+			Name:     "Ensure client is created with client certificate",
+			Endpoint: "https://lxd.example.com:8443",
+			TLSOptions: TLSOptions{
+				ClientCertFile: certFile,
+				ClientKeyFile:  keyFile,
+			},
+		},
+		{
+			Name:        "Ensure client certificate is required",
+			Endpoint:    "https://lxd.example.com:8443",
+			TLSOptions:  TLSOptions{ClientKeyFile: keyFile},
+			expectError: "Client certificate and key are required",
+		},
+		{
+			Name:        "Ensure endpoint host is required",
+			Endpoint:    "https://",
+			TLSOptions:  TLSOptions{ClientCertFile: certFile, ClientKeyFile: keyFile},
+			expectError: "Host cannot be empty",
+		},
+		{
+			Name:     "Ensure missing server certificate file is rejected",
+			Endpoint: "https://lxd.example.com",
+			TLSOptions: TLSOptions{
+				ClientCertFile: certFile,
+				ClientKeyFile:  keyFile,
+				ServerCertFile: filepath.Join(t.TempDir(), "missing.crt"),
+			},
+			expectError: "Failed reading server certificate",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			u, err := url.Parse(test.Endpoint)
+			require.NoError(t, err)
+
+			client, err := newHTTPSClient(u, test.TLSOptions)
+			if test.expectError != "" {
+				require.ErrorContains(t, err, test.expectError)
+				return
+			}
+
+			require.NoError(t, err)
+
+			transport, ok := client.Transport.(*http.Transport)
+			require.True(t, ok, "Expected HTTP transport")
+			require.NotNil(t, transport.TLSClientConfig)
+			require.Len(t, transport.TLSClientConfig.Certificates, 1)
+			require.Equal(t, "lxd.example.com", transport.TLSClientConfig.ServerName)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/lxd/locking"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// VolumeConfigAttachedTo is the LXD volume configuration key that contains
+// the node (LXD instance) a volume is attached to. The devLXD API cannot list
+// the instances a volume is attached to, therefore the attachment is recorded
+// on the volume to prevent attaching a single-writer volume to a second node,
+// and to report the node the volume is published on in ListVolumes.
+const VolumeConfigAttachedTo = "user.attached-to"
+
+// singleWriterAccessModes contains the access modes that allow only a single
+// node to write to the volume.
+var singleWriterAccessModes = []csi.VolumeCapability_AccessMode_Mode{
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+}
+
+// isSingleWriter returns true if the given capability allows only a single
+// node to write to the volume.
+func isSingleWriter(volCap *csi.VolumeCapability) bool {
+	return slices.Contains(singleWriterAccessModes, volCap.GetAccessMode().GetMode())
+}
+
+// isAttachedTo returns true if the given volume is attached to the given
+// node. A node that no longer exists has no volumes attached.
+func isAttachedTo(client lxdClient.DevLXDServer, nodeID string, poolName string, volName string) (bool, error) {
+	inst, _, err := client.GetInstance(nodeID)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	dev := inst.Devices[volName]
+	return dev["type"] == "disk" && dev["source"] == volName && dev["pool"] == poolName, nil
+}
+
+// setVolumeAttachment records the node the given volume is attached to, or
+// removes the record if the node is empty. Nothing is updated if the record
+// is already up to date.
+func setVolumeAttachment(ctx context.Context, client lxdClient.DevLXDServer, rpc string, poolName string, vol *api.DevLXDStorageVolume, etag string, nodeID string) error {
+	if vol.Config[VolumeConfigAttachedTo] == nodeID {
+		return nil
+	}
+
+	config := maps.Clone(vol.Config)
+	if config == nil {
+		config = make(map[string]string)
+	}
+
+	if nodeID == "" {
+		delete(config, VolumeConfigAttachedTo)
+	} else {
+		config[VolumeConfigAttachedTo] = nodeID
+	}
+
+	return updateVolumeConfig(ctx, client, rpc, poolName, vol, etag, config)
+}
+
+// recordVolumeAttachment records the node the published volume is attached
+// to. The volume is already attached at this point, so failures are only
+// logged rather than failing the publish. The record is written again when
+// the volume is published again, or backfilled on controller startup.
+func recordVolumeAttachment(ctx context.Context, client lxdClient.DevLXDServer, volumeID string, poolName string, vol *api.DevLXDStorageVolume, etag string, nodeID string) {
+	err := setVolumeAttachment(ctx, client, "ControllerPublishVolume", poolName, vol, etag, nodeID)
+	if err != nil {
+		klog.ErrorS(err, "Failed to record volume attachment", "volumeID", volumeID, "node", nodeID)
+	}
+}
+
+// backfillVolumeAttachments records the attachments of the volumes attached
+// before attachments were recorded, or whose record failed to be written, so
+// that ListVolumes reports their published nodes. The attached volumes are
+// taken from the volume attachments in Kubernetes and are recorded only once
+// confirmed in LXD. Failures are logged, as the attachment is also recorded
+// when the volume is published again.
+func (c *controllerServer) backfillVolumeAttachments(ctx context.Context) {
+	attachments, err := c.volumeAttachments(ctx, c.driver.name)
+	if err != nil {
+		klog.ErrorS(err, "Skipping backfill of volume attachment records")
+		return
+	}
+
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping backfill of volume attachment records")
+		return
+	}
+
+	for volumeID, nodeID := range attachments {
+		err := c.backfillVolumeAttachment(ctx, client, volumeID, nodeID)
+		if err != nil {
+			klog.ErrorS(err, "Failed to backfill volume attachment record", "volumeID", volumeID, "node", nodeID)
+		}
+	}
+}
+
+// backfillVolumeAttachment records the attachment of the given volume to the
+// given node, unless an attachment is already recorded or the volume is not
+// attached to the node. Volumes in use by another request are skipped.
+func (c *controllerServer) backfillVolumeAttachment(ctx context.Context, client lxdClient.DevLXDServer, volumeID string, nodeID string) error {
+	target, poolName, volName, err := splitVolumeID(volumeID)
+	if err != nil {
+		return err
+	}
+
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// Set target if provided and LXD is clustered.
+	if target != "" && c.driver.isClustered {
+		client = client.UseTarget(target)
+	}
+
+	unlock := locking.TryLock(c.volumeLockKey(target, poolName, volName))
+	if unlock == nil {
+		return nil
+	}
+
+	defer unlock()
+
+	vol, etag, err := client.GetStoragePoolVolume(poolName, "custom", volName)
+	if err != nil {
+		return err
+	}
+
+	if vol.Config[VolumeConfigAttachedTo] != "" {
+		return nil
+	}
+
+	attached, err := isAttachedTo(client, nodeID, poolName, volName)
+	if err != nil || !attached {
+		return err
+	}
+
+	err = setVolumeAttachment(ctx, client, "ControllerPublishVolume", poolName, vol, etag, nodeID)
+	if err != nil {
+		return err
+	}
+
+	klog.InfoS("Recorded attachment of volume attached before attachments were recorded", "volumeID", volumeID, "node", nodeID)
+
+	return nil
+}
@@ -0,0 +1,337 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestControllerPublishVolumeSingleWriter(t *testing.T) {
+	attachedDevice := map[string]string{"type": "disk", "source": "vol-1", "pool": "remote"}
+
+	tests := []struct {
+		Name             string
+		AccessMode       csi.VolumeCapability_AccessMode_Mode
+		AttachedTo       string
+		OtherDevices     map[string]map[string]string
+		RecordErr        error
+		expectCode       codes.Code
+		expectAttachedTo string
+	}{
+		{
+			Name:             "Ensure attachment is recorded for single-writer volume",
+			AccessMode:       csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			expectCode:       codes.OK,
+			expectAttachedTo: "node-1",
+		},
+		{
+			Name:         "Ensure single-writer volume attached to another node is rejected",
+			AccessMode:   csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			AttachedTo:   "node-2",
+			OtherDevices: map[string]map[string]string{"vol-1": attachedDevice},
+			expectCode:   codes.FailedPrecondition,
+		},
+		{
+			Name:         "Ensure single-pod volume attached to another node is rejected",
+			AccessMode:   csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+			AttachedTo:   "node-2",
+			OtherDevices: map[string]map[string]string{"vol-1": attachedDevice},
+			expectCode:   codes.FailedPrecondition,
+		},
+		{
+			Name:             "Ensure stale attachment to another node is ignored",
+			AccessMode:       csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			AttachedTo:       "node-2",
+			expectCode:       codes.OK,
+			expectAttachedTo: "node-1",
+		},
+		{
+			Name:         "Ensure read-only volume is not restricted",
+			AccessMode:   csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			AttachedTo:   "node-2",
+			OtherDevices: map[string]map[string]string{"vol-1": attachedDevice},
+			expectCode:   codes.OK,
+		},
+		{
+			Name:             "Ensure attachment is recorded for read-only volume",
+			AccessMode:       csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			expectCode:       codes.OK,
+			expectAttachedTo: "node-1",
+		},
+		{
+			Name:             "Ensure publish succeeds when recording the attachment fails",
+			AccessMode:       csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			RecordErr:        errors.New("Volume update failed"),
+			expectCode:       codes.OK,
+			expectAttachedTo: "node-1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var updatedConfig map[string]string
+			var attached bool
+
+			config := map[string]string{VolumeConfigManagedBy: VolumeManagedByValue}
+			if test.AttachedTo != "" {
+				config[VolumeConfigAttachedTo] = test.AttachedTo
+			}
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return &api.DevLXDStorageVolume{Name: name, Pool: pool, Config: config}, "etag", nil
+					},
+					updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+						require.Equal(t, "etag", ETag)
+						updatedConfig = volume.Config
+						if test.RecordErr != nil {
+							return nil, test.RecordErr
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+					getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+						if name == "node-2" {
+							return &api.DevLXDInstance{Name: name, Devices: test.OtherDevices}, "", nil
+						}
+
+						return &api.DevLXDInstance{Name: name}, "", nil
+					},
+					updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+						require.Equal(t, "node-1", name)
+						attached = true
+						return nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+				VolumeId: "remote/vol-1",
+				NodeId:   "node-1",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: test.AccessMode},
+					AccessType: &csi.VolumeCapability_Block{
+						Block: &csi.VolumeCapability_BlockVolume{},
+					},
+				},
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err), "Unexpected error: %v", err)
+			require.Equal(t, test.expectCode == codes.OK, attached, "Unexpected volume attachment")
+
+			if test.expectAttachedTo == "" {
+				require.Nil(t, updatedConfig, "Attachment should not have been recorded")
+			} else {
+				require.Equal(t, test.expectAttachedTo, updatedConfig[VolumeConfigAttachedTo])
+				require.Equal(t, VolumeManagedByValue, updatedConfig[VolumeConfigManagedBy])
+			}
+		})
+	}
+}
+
+func TestControllerUnpublishVolumeRemovesAttachment(t *testing.T) {
+	var updatedConfig map[string]string
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return &api.DevLXDStorageVolume{
+					Name: name,
+					Pool: pool,
+					Config: map[string]string{
+						VolumeConfigManagedBy:  VolumeManagedByValue,
+						VolumeConfigAttachedTo: "node-1",
+					},
+				}, "", nil
+			},
+			updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+				updatedConfig = volume.Config
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	_, err := controller.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "remote/vol-1",
+		NodeId:   "node-1",
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, updatedConfig, "Attachment record should have been removed")
+	require.NotContains(t, updatedConfig, VolumeConfigAttachedTo)
+	require.Equal(t, VolumeManagedByValue, updatedConfig[VolumeConfigManagedBy])
+}
+
+func TestControllerUnpublishVolumeIgnoresAttachmentErrors(t *testing.T) {
+	tests := []struct {
+		Name         string
+		GetVolErr    error
+		UpdateVolErr error
+	}{
+		{
+			Name:      "Ensure unpublish succeeds when the volume cannot be retrieved",
+			GetVolErr: api.StatusErrorf(http.StatusInternalServerError, "Failed to load volume"),
+		},
+		{
+			Name:      "Ensure unpublish succeeds when the volume no longer exists",
+			GetVolErr: api.StatusErrorf(http.StatusNotFound, "Storage volume not found"),
+		},
+		{
+			Name:         "Ensure unpublish succeeds when the attachment record cannot be removed",
+			UpdateVolErr: errors.New("Volume update failed"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var detached bool
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if test.GetVolErr != nil {
+							return nil, "", test.GetVolErr
+						}
+
+						return &api.DevLXDStorageVolume{
+							Name: name,
+							Pool: pool,
+							Config: map[string]string{
+								VolumeConfigManagedBy:  VolumeManagedByValue,
+								VolumeConfigAttachedTo: "node-1",
+							},
+						}, "", nil
+					},
+					updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+						return nil, test.UpdateVolErr
+					},
+					getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+						return &api.DevLXDInstance{
+							Name: name,
+							Devices: map[string]map[string]string{
+								"vol-1": {"type": "disk", "source": "vol-1", "pool": "remote"},
+							},
+						}, "", nil
+					},
+					updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+						require.Nil(t, inst.Devices["vol-1"])
+						detached = true
+						return nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+				VolumeId: "remote/vol-1",
+				NodeId:   "node-1",
+			})
+
+			require.NoError(t, err)
+			require.True(t, detached, "Volume should have been detached")
+		})
+	}
+}
+
+func TestBackfillVolumeAttachments(t *testing.T) {
+	attachedDevice := func(name string) map[string]string {
+		return map[string]string{"type": "disk", "source": name, "pool": "remote"}
+	}
+
+	configs := map[string]map[string]string{
+		"vol-unrecorded": {VolumeConfigManagedBy: VolumeManagedByValue},
+		"vol-recorded":   {VolumeConfigManagedBy: VolumeManagedByValue, VolumeConfigAttachedTo: "node-2"},
+		"vol-detached":   {VolumeConfigManagedBy: VolumeManagedByValue},
+	}
+
+	updated := make(map[string]map[string]string)
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolsFunc: func(pool string) ([]api.DevLXDStorageVolume, error) {
+				var vols []api.DevLXDStorageVolume
+				for name, config := range configs {
+					vols = append(vols, api.DevLXDStorageVolume{Name: name, Type: "custom", Config: config})
+				}
+
+				return vols, nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return &api.DevLXDStorageVolume{Name: name, Pool: pool, Config: configs[name]}, "etag", nil
+			},
+			updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+				require.Equal(t, "etag", ETag)
+				updated[name] = volume.Config
+				configs[name] = volume.Config
+				return &fakeDevLXDOperation{}, nil
+			},
+			getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+				return &api.DevLXDInstance{
+					Name: name,
+					Devices: map[string]map[string]string{
+						"vol-unrecorded": attachedDevice("vol-unrecorded"),
+						"vol-recorded":   attachedDevice("vol-recorded"),
+					},
+				}, "", nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+	controller.volumePools.Track("remote")
+	controller.persistentVolumeIDs = func(ctx context.Context, driverName string) ([]string, error) {
+		return nil, nil
+	}
+
+	controller.volumeAttachments = func(ctx context.Context, driverName string) (map[string]string, error) {
+		require.Equal(t, d.name, driverName)
+		return map[string]string{
+			"remote/vol-unrecorded": "node-1",
+			"remote/vol-recorded":   "node-1",
+			"remote/vol-detached":   "node-1",
+		}, nil
+	}
+
+	// Ensure a volume that is attached without an attachment record is not
+	// reported as published before the record is backfilled.
+	resp, err := controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 3)
+	require.Equal(t, "remote/vol-unrecorded", resp.Entries[2].Volume.VolumeId)
+	require.Empty(t, resp.Entries[2].Status.PublishedNodeIds)
+
+	// Ensure only the attachment of the attached volume without a record
+	// is recorded.
+	controller.backfillVolumeAttachments(context.Background())
+	require.Len(t, updated, 1)
+	require.Equal(t, "node-1", updated["vol-unrecorded"][VolumeConfigAttachedTo])
+	require.Equal(t, VolumeManagedByValue, updated["vol-unrecorded"][VolumeConfigManagedBy])
+
+	// Ensure the volume is reported as published once recorded.
+	resp, err = controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"node-1"}, resp.Entries[2].Status.PublishedNodeIds)
+}
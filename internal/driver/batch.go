@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// deviceBatcher coalesces device additions to the same instance that are
+// requested within a short window into a single instance update. This
+// reduces the number of LXD requests and ETag conflicts when a pod with
+// multiple volumes is scheduled on a node.
+type deviceBatcher struct {
+	// Time to wait for further device additions after the first one.
+	// Zero or negative value disables batching.
+	window time.Duration
+
+	// Pending batches by instance.
+	batches map[string]*deviceBatch
+
+	lock sync.Mutex
+}
+
+// deviceBatch is a set of devices to be added to an instance at once.
+type deviceBatch struct {
+	devices map[string]batchedDevice
+
+	// Closed once the instance is updated. Errors are reported per
+	// device, so that each request receives the error of its own device.
+	done chan struct{}
+	errs map[string]error
+}
+
+// batchedDevice is a device added to a batch, along with the client of the
+// request that added it.
+type batchedDevice struct {
+	client lxdClient.DevLXDServer
+	device map[string]string
+}
+
+// newDeviceBatcher returns a new device batcher that coalesces device
+// additions requested within the given window.
+func newDeviceBatcher(window time.Duration) *deviceBatcher {
+	return &deviceBatcher{
+		window:  window,
+		batches: make(map[string]*deviceBatch),
+	}
+}
+
+// AddDevice adds the given device to the instance and waits until the
+// instance is updated.
+//
+// If batching is disabled, the instance is updated immediately using the
+// given ETag. Otherwise, the device is added to the pending batch of the
+// instance, which is applied with a freshly retrieved ETag once the batch
+// window elapses. Devices of different targets are never batched together.
+//
+// If the context is done before the batch is applied, the device may still
+// be added. This is fine, as publishing an attached volume is a no-op.
+func (b *deviceBatcher) AddDevice(ctx context.Context, client lxdClient.DevLXDServer, target string, instName string, etag string, devName string, device map[string]string) error {
+	if b == nil || b.window <= 0 {
+		reqInst := api.DevLXDInstancePut{
+			Devices: map[string]map[string]string{
+				devName: device,
+			},
+		}
+
+		return client.UpdateInstance(instName, reqInst, etag)
+	}
+
+	key := target + "/" + instName
+
+	b.lock.Lock()
+	batch, ok := b.batches[key]
+	if !ok {
+		batch = &deviceBatch{
+			devices: make(map[string]batchedDevice),
+			done:    make(chan struct{}),
+			errs:    make(map[string]error),
+		}
+
+		b.batches[key] = batch
+		time.AfterFunc(b.window, func() { b.apply(client, key, instName, batch) })
+	}
+
+	batch.devices[devName] = batchedDevice{
+		client: client,
+		device: maps.Clone(device),
+	}
+
+	b.lock.Unlock()
+
+	select {
+	case <-batch.done:
+		return batch.errs[devName]
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// apply updates the instance with all devices of the given batch. If the
+// combined update fails, the devices are added one by one, so that a single
+// rejected device does not fail the requests of the other devices.
+func (b *deviceBatcher) apply(client lxdClient.DevLXDServer, key string, instName string, batch *deviceBatch) {
+	// Remove the batch first, so that further device additions
+	// start a new batch.
+	b.lock.Lock()
+	delete(b.batches, key)
+	b.lock.Unlock()
+
+	defer close(batch.done)
+
+	devices := make(map[string]map[string]string, len(batch.devices))
+	for devName, dev := range batch.devices {
+		devices[devName] = dev.device
+	}
+
+	err := updateInstanceDevices(client, instName, devices)
+	if err == nil {
+		return
+	}
+
+	if len(devices) == 1 {
+		for devName := range devices {
+			batch.errs[devName] = err
+		}
+
+		return
+	}
+
+	// Add devices in a predictable order, each using the client of
+	// the request that added it.
+	for _, devName := range slices.Sorted(maps.Keys(batch.devices)) {
+		dev := batch.devices[devName]
+		batch.errs[devName] = updateInstanceDevices(dev.client, instName, map[string]map[string]string{devName: dev.device})
+	}
+}
+
+// updateInstanceDevices adds the given devices to the instance.
+func updateInstanceDevices(client lxdClient.DevLXDServer, instName string, devices map[string]map[string]string) error {
+	// The ETag retrieved by the individual requests may be outdated by now.
+	_, etag, err := client.GetInstance(instName)
+	if err != nil {
+		return err
+	}
+
+	return client.UpdateInstance(instName, api.DevLXDInstancePut{Devices: devices}, etag)
+}
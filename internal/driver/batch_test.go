@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestControllerPublishVolumeBatching(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Window         time.Duration
+		UpdateErr      error
+		RejectedVolume string
+		expectUpdates  int
+		expectCode     codes.Code
+	}{
+		{
+			Name:          "Ensure simultaneous publishes to one node are coalesced",
+			Window:        100 * time.Millisecond,
+			expectUpdates: 1,
+			expectCode:    codes.OK,
+		},
+		{
+			Name:          "Ensure batch update error is reported for each volume",
+			Window:        100 * time.Millisecond,
+			UpdateErr:     api.NewStatusError(http.StatusForbidden, "Not authorized"),
+			expectUpdates: 4,
+			expectCode:    codes.PermissionDenied,
+		},
+		{
+			Name:           "Ensure rejected device does not fail other volumes in the batch",
+			Window:         100 * time.Millisecond,
+			RejectedVolume: "vol-2",
+			expectUpdates:  4,
+			expectCode:     codes.OK,
+		},
+		{
+			Name:          "Ensure publishes are not coalesced when batching is disabled",
+			Window:        0,
+			expectUpdates: 3,
+			expectCode:    codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var lock sync.Mutex
+			var updates int
+			devices := make(map[string]map[string]string)
+
+			d := &Driver{
+				name:               "lxd.csi.canonical.com",
+				nodeID:             "test-node",
+				publishBatchWindow: test.Window,
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: getManagedVolume,
+					updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+						lock.Lock()
+						defer lock.Unlock()
+
+						updates++
+						if test.UpdateErr != nil {
+							return test.UpdateErr
+						}
+
+						_, ok := inst.Devices[test.RejectedVolume]
+						if ok {
+							return api.NewStatusError(http.StatusBadRequest, "Invalid device")
+						}
+
+						for devName, dev := range inst.Devices {
+							devices[devName] = dev
+						}
+
+						return nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			volNames := []string{"vol-1", "vol-2", "vol-3"}
+			errs := make([]error, len(volNames))
+
+			var wg sync.WaitGroup
+			for i, volName := range volNames {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, errs[i] = controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+						VolumeId: "remote/" + volName,
+						NodeId:   "node-1",
+						VolumeCapability: &csi.VolumeCapability{
+							AccessType: &csi.VolumeCapability_Block{
+								Block: &csi.VolumeCapability_BlockVolume{},
+							},
+						},
+					})
+				}()
+			}
+
+			wg.Wait()
+
+			for i, err := range errs {
+				expectCode := test.expectCode
+				if volNames[i] == test.RejectedVolume {
+					expectCode = codes.InvalidArgument
+				}
+
+				require.Equal(t, expectCode, status.Code(err), "Unexpected result for volume %q: %v", volNames[i], err)
+			}
+
+			require.Equal(t, test.expectUpdates, updates)
+
+			if test.expectCode == codes.OK {
+				for _, volName := range volNames {
+					if volName == test.RejectedVolume {
+						require.NotContains(t, devices, volName)
+						continue
+					}
+
+					require.Equal(t, "disk", devices[volName]["type"], "Device %q should have been added", volName)
+					require.Equal(t, volName, devices[volName]["source"])
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceBatcherContextDone(t *testing.T) {
+	b := newDeviceBatcher(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := b.AddDevice(ctx, &fakeDevLXDServer{}, "", "node-1", "", "vol-1", map[string]string{"type": "disk"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
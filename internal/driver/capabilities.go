@@ -2,6 +2,8 @@ package driver
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 )
@@ -28,6 +30,17 @@ func NewNodeServiceCapability(c csi.NodeServiceCapability_RPC_Type) *csi.NodeSer
 	}
 }
 
+// supportedAccessModes contains the volume access modes supported by the driver.
+// LXD custom volumes are attached to a single instance (node), therefore only
+// single node access modes are supported. Multiple pods on the same node can
+// share the volume using the SINGLE_NODE_MULTI_WRITER access mode.
+var supportedAccessModes = []csi.VolumeCapability_AccessMode_Mode{
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+	csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+}
+
 // ValidateVolumeCapabilities validates the provided volume capabilities.
 func ValidateVolumeCapabilities(volCaps ...*csi.VolumeCapability) error {
 	if len(volCaps) == 0 {
@@ -45,6 +58,11 @@ func ValidateVolumeCapabilities(volCaps ...*csi.VolumeCapability) error {
 		if c.GetMount() != nil {
 			accessTypeMount = true
 		}
+
+		accessMode := c.GetAccessMode()
+		if accessMode != nil && !slices.Contains(supportedAccessModes, accessMode.Mode) {
+			return fmt.Errorf("Unsupported volume access mode %q", accessMode.Mode)
+		}
 	}
 
 	if !accessTypeBlock && !accessTypeMount {
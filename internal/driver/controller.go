@@ -2,26 +2,83 @@ package driver
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"maps"
 	"net/http"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	k8sValidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
 
 	"github.com/canonical/lxd-csi-driver/internal/lxderrors"
+	lxdClient "github.com/canonical/lxd/client"
 	"github.com/canonical/lxd/lxd/locking"
 	"github.com/canonical/lxd/shared/api"
 	"github.com/canonical/lxd/shared/units"
 )
 
+// volumeDescriptionPrefix is the prefix of the description of LXD volumes
+// created by the driver.
+const volumeDescriptionPrefix = "Managed by Kubernetes PVC"
+
+// snapshotRetainDescriptionSuffix is appended to the description of LXD snapshots
+// created with the "Retain" deletion policy.
+const snapshotRetainDescriptionSuffix = " (deletionPolicy=Retain)"
+
+// volumeReadyPollInterval is the interval between checks whether a newly
+// created volume is ready to be used.
+var volumeReadyPollInterval = time.Second
+
 type controllerServer struct {
 	driver *Driver
 
+	// Limits concurrent operations per storage pool.
+	poolLimiter *poolLimiter
+
+	// Caches the health of LXD cluster members.
+	memberHealth *memberHealthCache
+
+	// Volume deletions that are still in progress.
+	pendingDeletes *pendingOperations
+
+	// Retrieves PVC annotations and labels from the Kubernetes API.
+	pvcMetadata pvcMetadataFunc
+
+	// Retrieves node labels from the Kubernetes API.
+	nodeLabels nodeLabelsFunc
+
+	// Coalesces device additions to the same instance.
+	publishBatcher *deviceBatcher
+
+	// Storage pools that may contain soft-deleted volumes.
+	softDeletePools *poolSet
+
+	// Storage pools that may contain volumes of the driver, which are
+	// listed by ListVolumes, and the function discovering them from the
+	// persistent volumes of the driver.
+	volumePools         *poolSet
+	persistentVolumeIDs persistentVolumeIDsFunc
+
+	// Retrieves the nodes the volumes are attached to from the Kubernetes
+	// API, to backfill the attachment records of the volumes.
+	volumeAttachments volumeAttachmentsFunc
+
+	// Clones that are in progress, and whether the controller is
+	// shutting down and cancelling them.
+	activeClones *pendingOperations
+	shuttingDown atomic.Bool
+
 	// Must be embedded for forward compatibility.
 	csi.UnimplementedControllerServer
 }
@@ -29,7 +86,20 @@ type controllerServer struct {
 // NewControllerServer returns a new instance of the CSI controller server.
 func NewControllerServer(driver *Driver) *controllerServer {
 	return &controllerServer{
-		driver: driver,
+		driver:       driver,
+		poolLimiter:  newPoolLimiter(driver.maxConcurrentOperationsPerPool),
+		memberHealth: newMemberHealthCache(memberHealthCacheTTL),
+
+		pendingDeletes: newPendingOperations(),
+		pvcMetadata:    getPVCMetadata,
+		nodeLabels:     getNodeLabels,
+		publishBatcher: newDeviceBatcher(driver.publishBatchWindow),
+
+		softDeletePools:     newPoolSet(),
+		volumePools:         newPoolSet(),
+		persistentVolumeIDs: getPersistentVolumeIDs,
+		volumeAttachments:   getVolumeAttachments,
+		activeClones:        newPendingOperations(),
 	}
 }
 
@@ -43,6 +113,8 @@ func (c *controllerServer) ControllerGetCapabilities(_ context.Context, _ *csi.C
 // CreateVolume creates a new volume in the LXD storage pool.
 // If a volume source is specified, the new volume is created from an existing volume or snapshot.
 func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	start := time.Now()
+
 	client, err := c.driver.DevLXDClient()
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: %v", err)
@@ -57,13 +129,6 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Unexpected volume name format: %q", req.Name)
 	}
 
-	// Override volume prefix if configured.
-	if c.driver.volumeNamePrefix != "" {
-		volPrefix = c.driver.volumeNamePrefix
-	}
-
-	volName := volPrefix + "-" + strings.ReplaceAll(volUUID, "-", "")
-
 	contentSource := req.VolumeContentSource
 
 	err = ValidateVolumeCapabilities(req.VolumeCapabilities...)
@@ -71,15 +136,36 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
 	}
 
+	if c.driver.MaintenanceMode() {
+		return nil, status.Error(codes.Unavailable, "CreateVolume: Volume provisioning is paused for maintenance")
+	}
+
 	contentType := ParseContentType(req.VolumeCapabilities...)
 	if contentType == "" {
 		return nil, status.Error(codes.InvalidArgument, "CreateVolume: Volume capability must specify either block or filesystem access type")
 	}
 
-	// Validate volume size.
-	sizeBytes := req.CapacityRange.RequiredBytes
-	if sizeBytes < 1 {
-		return nil, status.Error(codes.InvalidArgument, "CreateVolume: Volume size cannot be zero or negative")
+	// Validate volume capacity range.
+	sizeBytes := req.GetCapacityRange().GetRequiredBytes()
+	limitBytes := req.GetCapacityRange().GetLimitBytes()
+	if sizeBytes < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Required volume size %d cannot be negative", sizeBytes)
+	}
+
+	// Zero required size means the size is not specified. A new volume then
+	// gets the default volume size of the storage pool, while a volume created
+	// from a source needs the size to be compared with the source size.
+	if sizeBytes == 0 && contentSource != nil {
+		return nil, status.Error(codes.InvalidArgument, "CreateVolume: Required volume size cannot be zero")
+	}
+
+	if limitBytes < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Volume size limit %d cannot be negative", limitBytes)
+	}
+
+	// Zero limit means the volume size is not limited.
+	if limitBytes > 0 && sizeBytes > limitBytes {
+		return nil, status.Errorf(codes.OutOfRange, "CreateVolume: Required volume size %d exceeds the volume size limit %d", sizeBytes, limitBytes)
 	}
 
 	// Validate storage class parameters.
@@ -88,25 +174,36 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		parameters = make(map[string]string)
 	}
 
-	for k, v := range parameters {
-		if strings.HasPrefix(k, "csi.storage.k8s.io/") {
-			// Skip standard CSI parameters.
-			continue
-		}
+	poolName, err := validateStorageClassParameters(parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+	}
 
-		switch k {
-		case ParameterStoragePool:
-			parameters[k] = v
-		default:
-			return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Invalid parameter %q in storage class", k)
+	// Round up the requested size to the storage class minimum for the
+	// volume content type. The parameter is already validated.
+	minSize := parameters[minSizeParameters[contentType]]
+	if minSize != "" {
+		minSizeBytes, _ := units.ParseByteSizeString(minSize)
+		if sizeBytes < minSizeBytes {
+			if limitBytes > 0 && minSizeBytes > limitBytes {
+				return nil, status.Errorf(codes.OutOfRange, "CreateVolume: Minimum %s volume size %d exceeds the volume size limit %d", contentType, minSizeBytes, limitBytes)
+			}
+
+			klog.InfoS("Rounding up volume size to storage class minimum", "volumeName", req.Name, "contentType", contentType, "requestedBytes", sizeBytes, "minSizeBytes", minSizeBytes)
+			sizeBytes = minSizeBytes
 		}
 	}
 
-	poolName := req.Parameters[ParameterStoragePool]
-	if poolName == "" {
-		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Storage class parameter %q is required and cannot be empty", ParameterStoragePool)
+	// Override volume prefix if configured. The storage class prefix
+	// takes precedence over the driver-wide prefix.
+	if parameters[ParameterVolumeNamePrefix] != "" {
+		volPrefix = parameters[ParameterVolumeNamePrefix]
+	} else if c.driver.volumeNamePrefix != "" {
+		volPrefix = c.driver.volumeNamePrefix
 	}
 
+	volName := volPrefix + "-" + strings.ReplaceAll(volUUID, "-", "")
+
 	pool, _, err := client.GetStoragePool(poolName)
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: Failed to retrieve storage pool %q: %v", poolName, err)
@@ -119,16 +216,27 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: %v", err)
 	}
 
-	var driver *api.DevLXDServerStorageDriverInfo
-	for _, d := range state.SupportedStorageDrivers {
-		if d.Name == pool.Driver {
-			driver = &d
-			break
+	driver, err := getSupportedStorageDriver(state, pool.Driver)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+	}
+
+	err = validateStorageDriverContentType(driver, contentType)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+	}
+
+	// Device tuning parameters apply only to block devices.
+	if contentType != "block" {
+		for _, param := range []string{ParameterIOCache, ParameterReadAheadKB} {
+			if parameters[param] != "" {
+				return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Storage class parameter %q is supported only for block volumes", param)
+			}
 		}
 	}
 
-	if driver == nil || driver.Name == "cephobject" {
-		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: CSI does not support storage driver %q", pool.Driver)
+	if parameters[ParameterColocateWith] != "" && driver.Remote {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Storage class parameter %q is supported only for local storage pools", ParameterColocateWith)
 	}
 
 	// Reject request for immediate binding of local volumes.
@@ -149,7 +257,7 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		// to support storage  systems that span across multiple topologies.
 		if req.GetAccessibilityRequirements() != nil {
 			for _, topology := range req.GetAccessibilityRequirements().GetPreferred() {
-				clusterMember, ok := topology.Segments[AnnotationLXDClusterMember]
+				clusterMember, ok := topology.Segments[c.driver.topologyKey]
 				if ok {
 					target = clusterMember
 					break
@@ -157,6 +265,17 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 			}
 		}
 
+		// Co-locate the volume with the referenced volume. This takes
+		// precedence over the preferred topology, as the volume must
+		// be created on the member where the referenced volume is.
+		colocateWith := parameters[ParameterColocateWith]
+		if colocateWith != "" && c.driver.isClustered {
+			target, err = colocatedVolumeTarget(client, poolName, colocateWith, parameters[ParameterPVCNamespace])
+			if err != nil {
+				return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: %v", err)
+			}
+		}
+
 		// For storage backends that are topology-constrained and not globally
 		// accessible from all Nodes in the cluster (e.g. local volumes), the
 		// PersistentVolume may be bound or provisioned without the knowledge
@@ -169,7 +288,7 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 			accessibleTopology = []*csi.Topology{
 				{
 					Segments: map[string]string{
-						AnnotationLXDClusterMember: target,
+						c.driver.topologyKey: target,
 					},
 				},
 			}
@@ -177,33 +296,61 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 			// Only set the target when LXD is clustered.
 			if c.driver.isClustered {
 				client = client.UseTarget(target)
+
+				// Fail fast if the target cluster member is offline, so that
+				// the volume can be rescheduled on another node.
+				if !c.isMemberOnline(client, target, poolName) {
+					return nil, status.Errorf(codes.Unavailable, "CreateVolume: Cluster member %q is offline", target)
+				}
 			}
 		}
 	}
 
 	volumeID := getVolumeID(target, poolName, volName)
 
-	unlock := locking.TryLock(volumeID)
+	lockKey := c.volumeLockKey(target, poolName, volName)
+	unlock := locking.TryLock(lockKey)
 	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "CreateVolume: Failed to obtain lock %q", volumeID)
+		return nil, status.Errorf(codes.Aborted, "CreateVolume: Failed to obtain lock %q", lockKey)
 	}
 
 	defer unlock()
 
+	release, err := c.poolLimiter.Acquire(ctx, poolName)
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: Failed to acquire operation slot for storage pool %q: %v", poolName, err)
+	}
+
+	defer release()
+
 	vol, _, err := client.GetStoragePoolVolume(poolName, "custom", volName)
 	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: Failed to retrieve storage volume %q from pool %q: %v", volName, poolName, err)
 	}
 
-	if vol != nil {
+	// A retried restore is checked against the snapshot the existing volume
+	// was restored from once the volume creation conflicts.
+	if vol != nil && contentSource.GetSnapshot() == nil {
 		return nil, status.Errorf(codes.AlreadyExists, "CreateVolume: Volume with the same name %q already exists", volName)
 	}
 
 	// If PVC name was passed to the driver, use it as the volume description.
 	// Otherwise, use a generic description to clearly indicate the volume is managed by Kubernetes.
-	volumeDescription := "Managed by Kubernetes PVC"
+	// The storage class can override the description using a template.
+	volumeDescription := volumeDescriptionPrefix
 	pvcName := parameters[ParameterPVCName]
-	if pvcName != "" {
+	if parameters[ParameterDescriptionTemplate] != "" {
+		volumeDescription, err = renderVolumeDescription(parameters[ParameterDescriptionTemplate], volumeDescriptionData{
+			PVCName:      pvcName,
+			PVCNamespace: parameters[ParameterPVCNamespace],
+			PVName:       parameters[ParameterPVName],
+			StoragePool:  poolName,
+			VolumeName:   volName,
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+		}
+	} else if pvcName != "" {
 		pvcIdentifier := pvcName
 
 		pvcNamespace := parameters[ParameterPVCNamespace]
@@ -214,6 +361,92 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		volumeDescription = volumeDescription + " " + pvcIdentifier
 	}
 
+	volumeConfig := map[string]string{
+		VolumeConfigManagedBy:     VolumeManagedByValue,
+		VolumeConfigStorageDriver: driver.Name,
+	}
+
+	// Omit the size to let LXD apply the default volume size of the pool.
+	if sizeBytes > 0 {
+		volumeConfig["size"] = strconv.FormatInt(sizeBytes, 10)
+	}
+
+	// Pass through the LXD volume configuration from the storage class.
+	// The keys are already validated. Options applied below take precedence.
+	for k, v := range parameters {
+		key, found := strings.CutPrefix(k, ParameterLXDConfigPrefix)
+		if found {
+			volumeConfig[key] = v
+		}
+	}
+
+	// Format filesystem volumes with the requested filesystem.
+	fsType, err := volumeFSType(parameters, req.VolumeCapabilities)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
+	}
+
+	if fsType != "" && contentType == "filesystem" {
+		volumeConfig["block.filesystem"] = fsType
+	}
+
+	// Mount filesystem volumes with the "discard" option to reclaim
+	// unused blocks immediately.
+	if parameters[ParameterDiscard] == DiscardOnline && contentType == "filesystem" {
+		volumeConfig["block.mount_options"] = "discard"
+	}
+
+	// Record the Kubernetes objects the volume belongs to, so the volume
+	// can be traced back to them from LXD.
+	for param, key := range map[string]string{
+		ParameterPVCName:      VolumeConfigPVCName,
+		ParameterPVCNamespace: VolumeConfigPVCNamespace,
+		ParameterPVName:       VolumeConfigPVName,
+	} {
+		value := parameters[param]
+		if value != "" {
+			volumeConfig[key] = value
+		}
+	}
+
+	// Retrieve the PVC metadata that is mirrored into the volume configuration,
+	// unless the storage class mirrors none of it. The parameter is already
+	// validated.
+	var pvcAnnotations map[string]string
+	var pvcLabels map[string]string
+
+	deletionProtection, _ := strconv.ParseBool(parameters[ParameterDeletionProtection])
+	pvcLabelKeys := parsePVCLabelKeys(parameters[ParameterPVCLabels])
+
+	pvcNamespace := parameters[ParameterPVCNamespace]
+	if pvcName != "" && pvcNamespace != "" && (deletionProtection || len(pvcLabelKeys) > 0) {
+		pvcAnnotations, pvcLabels, err = c.pvcMetadata(ctx, pvcNamespace, pvcName)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "CreateVolume: %v", err)
+		}
+	}
+
+	// Mirror the deletion protection requested on the PVC into the volume
+	// configuration, so that DeleteVolume can enforce it.
+	if deletionProtection && pvcAnnotations[AnnotationDeletionProtection] == "true" {
+		volumeConfig[VolumeConfigDeletionProtection] = "true"
+	}
+
+	// Copy the allowlisted PVC labels into the volume configuration.
+	for _, key := range pvcLabelKeys {
+		value, ok := pvcLabels[key]
+		if !ok {
+			continue
+		}
+
+		errs := k8sValidation.IsValidLabelValue(value)
+		if len(errs) > 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Invalid value %q of PVC label %q: %s", value, key, strings.Join(errs, "; "))
+		}
+
+		volumeConfig[VolumeConfigLabelPrefix+key] = value
+	}
+
 	if contentSource != nil {
 		var sourcePoolName string
 		var sourceVolName string
@@ -229,6 +462,8 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 				return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
 			}
 
+			sourcePoolName = c.driver.resolvePoolName(sourcePoolName)
+
 			sourceClient := client
 			if c.driver.isClustered {
 				// Ensure source volume target is respected when LXD is clustered.
@@ -266,6 +501,10 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 			// Use "<volume>/<snapshot>" as the source volume name.
 			// LXD will figure out this is a snapshot reference and handle it accordingly.
 			sourceVolName = sourceVolName + "/" + sourceSnapshot.Name
+
+			// Record the snapshot the volume is restored from, so that a
+			// retried request can recognize the volume it already restored.
+			volumeConfig[VolumeConfigRestoredFrom] = sourceSnapshotID
 		case *csi.VolumeContentSource_Volume:
 			sourceVolID := contentSource.GetVolume().VolumeId
 			sourceTarget, sourcePoolName, sourceVolName, err = splitVolumeID(sourceVolID)
@@ -273,6 +512,8 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 				return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: %v", err)
 			}
 
+			sourcePoolName = c.driver.resolvePoolName(sourcePoolName)
+
 			sourceClient := client
 			if c.driver.isClustered {
 				// Ensure source volume target is respected when LXD is clustered.
@@ -323,18 +564,54 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 			},
 			DevLXDStorageVolumePut: api.DevLXDStorageVolumePut{
 				Description: volumeDescription,
-				Config: map[string]string{
-					"size": strconv.FormatInt(sizeBytes, 10),
-				},
+				Config:      volumeConfig,
 			},
 		}
 
-		op, err := client.CreateStoragePoolVolume(poolName, poolReq)
+		var op lxdClient.DevLXDOperation
+		err := retry(ctx, "CreateVolume", c.driver.maxRetries, func() error {
+			var err error
+			op, err = client.CreateStoragePoolVolume(poolName, poolReq)
+			return err
+		})
+
 		if err == nil {
-			err = op.WaitContext(ctx)
+			c.activeClones.Set(volumeID, op)
+			err = waitOperation(ctx, "CreateVolume", op)
+			c.activeClones.Delete(volumeID)
+
+			// The clone may have been cancelled on shutdown. Remove the
+			// partially cloned volume, so that it does not block the
+			// retried request.
+			if err != nil && c.shuttingDown.Load() {
+				deletePartialVolume(client, poolName, volName)
+			}
+		}
+
+		// A retried restore may find the volume already restored by a
+		// previous attempt. Accept it only if it was restored from the
+		// same snapshot.
+		restoredFrom := volumeConfig[VolumeConfigRestoredFrom]
+		if restoredFrom != "" && api.StatusErrorCheck(err, http.StatusConflict) {
+			existingVol, _, getErr := client.GetStoragePoolVolume(poolName, "custom", volName)
+			if getErr != nil {
+				return nil, status.Errorf(lxderrors.ToGRPCCode(getErr), "CreateVolume: Failed to retrieve existing volume %q: %v", volName, getErr)
+			}
+
+			if !isManagedVolume(existingVol) || existingVol.Config[VolumeConfigRestoredFrom] != restoredFrom {
+				return nil, status.Errorf(codes.AlreadyExists, "CreateVolume: Volume %q already exists and was not restored from snapshot %q", volName, restoredFrom)
+			}
+
+			klog.InfoS("Volume was already restored from snapshot", "volumeID", volumeID, "snapshotID", restoredFrom)
+			err = nil
 		}
 
 		if err != nil {
+			if target != "" && lxderrors.IsMemberOffline(err) {
+				c.memberHealth.Set(target, false)
+				return nil, status.Errorf(codes.Unavailable, "CreateVolume: Cluster member %q is offline: %v", target, err)
+			}
+
 			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: Failed to create volume %q in storage pool %q from volume %q in storage pool %q: %v", volName, poolName, sourceVolName, sourcePoolName, err)
 		}
 	} else {
@@ -345,18 +622,27 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 			ContentType: contentType,
 			DevLXDStorageVolumePut: api.DevLXDStorageVolumePut{
 				Description: volumeDescription,
-				Config: map[string]string{
-					"size": strconv.FormatInt(sizeBytes, 10),
-				},
+				Config:      volumeConfig,
 			},
 		}
 
-		op, err := client.CreateStoragePoolVolume(poolName, poolReq)
+		var op lxdClient.DevLXDOperation
+		err := retry(ctx, "CreateVolume", c.driver.maxRetries, func() error {
+			var err error
+			op, err = client.CreateStoragePoolVolume(poolName, poolReq)
+			return err
+		})
+
 		if err == nil {
-			err = op.WaitContext(ctx)
+			err = waitOperation(ctx, "CreateVolume", op)
 		}
 
 		if err != nil {
+			if target != "" && lxderrors.IsMemberOffline(err) {
+				c.memberHealth.Set(target, false)
+				return nil, status.Errorf(codes.Unavailable, "CreateVolume: Cluster member %q is offline: %v", target, err)
+			}
+
 			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: Failed to create volume %q in storage pool %q: %v", volName, poolName, err)
 		}
 	}
@@ -364,6 +650,87 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 	// Set additional parameters to the volume for later use.
 	parameters[ParameterStorageDriver] = driver.Name
 
+	// Optionally wait until the volume can be retrieved with the expected
+	// content type, as some storage drivers report the volume as created
+	// before it can be attached.
+	if c.driver.waitForVolumeReady {
+		vol, err = waitVolumeReady(ctx, client, poolName, volName, contentType)
+		if err != nil {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateVolume: Volume %q in storage pool %q is not ready: %v", volName, poolName, err)
+		}
+	} else {
+		vol, _, err = client.GetStoragePoolVolume(poolName, "custom", volName)
+	}
+
+	// Resolve the size of the volume created with the default volume size
+	// of the storage pool, as it must be reported to the provisioner.
+	if sizeBytes == 0 {
+		code := lxderrors.ToGRPCCode(err)
+		if err == nil {
+			sizeBytes, code, err = poolDefaultVolumeSize(vol, poolName, limitBytes)
+		}
+
+		if err != nil {
+			// Remove the volume, so that it does not block subsequent
+			// attempts to provision the volume.
+			deletePartialVolume(client, poolName, volName)
+			return nil, status.Errorf(code, "CreateVolume: Failed to resolve size of volume %q: %v", volName, err)
+		}
+
+		klog.InfoS("Using default volume size of storage pool", "volumeID", volumeID, "pool", poolName, "sizeBytes", sizeBytes)
+	}
+
+	// Record the volume configuration as applied by LXD. The volume is already
+	// created at this point, so failing to retrieve it is not fatal.
+	if err != nil {
+		klog.ErrorS(err, "Failed to retrieve created volume configuration", "volumeID", volumeID)
+	} else {
+		// Ensure the volume restored from a snapshot matches the request,
+		// rather than returning a volume of different content type or size.
+		if contentSource.GetSnapshot() != nil {
+			code, err := validateRestoredVolume(vol, contentType, sizeBytes, limitBytes)
+			if err != nil {
+				// Remove the mismatched volume, so that it does not block
+				// subsequent attempts to provision the volume.
+				deletePartialVolume(client, poolName, volName)
+
+				return nil, status.Errorf(code, "CreateVolume: Volume %q restored from snapshot does not match the request: %v", volName, err)
+			}
+		}
+
+		for _, key := range VolumeContextConfigKeys {
+			value, ok := vol.Config[key]
+			if ok {
+				parameters[ParameterVolumeConfigPrefix+key] = value
+			}
+		}
+
+		// Report the actual volume size, which may be rounded up by LXD.
+		actualSize, err := units.ParseByteSizeString(vol.Config["size"])
+		if err == nil && actualSize > sizeBytes {
+			// LXD rounds the size up to the allocation granularity of the
+			// storage driver, therefore no smaller size can be allocated for
+			// the required size. Remove the volume exceeding the limit, so
+			// that it does not block subsequent attempts to provision it.
+			if limitBytes > 0 && actualSize > limitBytes {
+				deletePartialVolume(client, poolName, volName)
+				return nil, status.Errorf(codes.OutOfRange, "CreateVolume: Allocated size %d of volume %q exceeds the volume size limit %d", actualSize, volName, limitBytes)
+			}
+
+			klog.InfoS("Allocated volume size differs from the requested size", "volumeID", volumeID, "requestedBytes", sizeBytes, "allocatedBytes", actualSize)
+			c.checkSizeRounding(volumeID, poolName, sizeBytes, actualSize)
+			sizeBytes = actualSize
+		}
+	}
+
+	// Record how long it took to provision the volume, including
+	// waiting for the LXD operation and for the volume to be ready.
+	volumeProvisionSecondsTotal.Add(time.Since(start).Seconds(), poolName, driver.Name)
+	volumeProvisionTotal.Inc(poolName, driver.Name)
+
+	// Track the storage pool, so that the volume is listed by ListVolumes.
+	c.volumePools.Track(poolName)
+
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:           volumeID,
@@ -375,159 +742,803 @@ func (c *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 	}, nil
 }
 
-// DeleteVolume deletes a volume from the LXD storage pool.
-func (c *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
-	client, err := c.driver.DevLXDClient()
-	if err != nil {
-		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: %v", err)
-	}
-
-	target, poolName, volName, err := splitVolumeID(req.VolumeId)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "DeleteVolume: %v", err)
+// parsePVCLabelKeys returns the label keys from the comma-separated list.
+// Surrounding whitespace and empty entries are ignored.
+func parsePVCLabelKeys(value string) []string {
+	var keys []string
+	for key := range strings.SplitSeq(value, ",") {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			keys = append(keys, key)
+		}
 	}
 
-	// Set target if provided and LXD is clustered.
-	if target != "" && c.driver.isClustered {
-		client = client.UseTarget(target)
-	}
+	return keys
+}
 
-	unlock := locking.TryLock(req.VolumeId)
-	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "DeleteVolume: Failed to obtain lock %q", req.VolumeId)
+// poolDefaultVolumeSize returns the size of the given volume created without
+// a size, which LXD sets to the default volume size of the storage pool. It
+// also returns the gRPC code to report if the size cannot be used.
+func poolDefaultVolumeSize(vol *api.DevLXDStorageVolume, poolName string, limitBytes int64) (int64, codes.Code, error) {
+	size := vol.Config["size"]
+	if size == "" {
+		return 0, codes.InvalidArgument, fmt.Errorf("Required volume size not provided and storage pool %q has no default volume size", poolName)
 	}
 
-	defer unlock()
-
-	// Delete storage volume. If volume does not exist, we consider
-	// the operation successful.
-	op, err := client.DeleteStoragePoolVolume(poolName, "custom", volName)
-	if err == nil {
-		err = op.WaitContext(ctx)
+	sizeBytes, err := units.ParseByteSizeString(size)
+	if err != nil {
+		return 0, codes.Internal, fmt.Errorf("Failed to parse default volume size %q of storage pool %q: %w", size, poolName, err)
 	}
 
-	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
-		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: Failed to delete volume %q from storage pool %q: %v", volName, poolName, err)
+	if limitBytes > 0 && sizeBytes > limitBytes {
+		return 0, codes.OutOfRange, fmt.Errorf("Default volume size %d of storage pool %q exceeds the volume size limit %d", sizeBytes, poolName, limitBytes)
 	}
 
-	return &csi.DeleteVolumeResponse{}, nil
+	return sizeBytes, codes.OK, nil
 }
 
-// CreateSnapshot creates a snapshot of a PVC that references an existing LXD custom volume.
-func (c *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
-	client, err := c.driver.DevLXDClient()
-	if err != nil {
-		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: %v", err)
-	}
-
-	if req.Name == "" {
-		return nil, status.Error(codes.InvalidArgument, "CreateSnapshot: Snapshot name cannot be empty")
-	}
-
-	if req.SourceVolumeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "CreateSnapshot: Source volume ID cannot be empty")
+// validateRestoredVolume ensures the volume restored from a snapshot has the
+// requested content type and that its size satisfies the requested capacity
+// range. A zero limit means the size is not limited. It also returns the gRPC
+// code to report if the volume does not match the request.
+func validateRestoredVolume(vol *api.DevLXDStorageVolume, contentType string, requiredBytes int64, limitBytes int64) (codes.Code, error) {
+	if vol.ContentType != contentType {
+		return codes.Internal, fmt.Errorf("Content type %q does not match the requested content type %q", vol.ContentType, contentType)
 	}
 
-	// Generate snapshot name and ID.
-	// Snapshot name is constructed from the requested snapshot name by removing dashes
-	// from the UUID portion. This shortens the snapshot name while keeping it unique.
-	snapshotPrefix, snapshotUUID, found := strings.Cut(req.Name, "-")
-	if !found {
-		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Unexpected volume name format: %q", req.Name)
+	size := vol.Config["size"]
+	if size == "" {
+		return codes.Internal, errors.New("Size is not configured")
 	}
 
-	snapshotName := snapshotPrefix + "-" + strings.ReplaceAll(snapshotUUID, "-", "")
-	snapshotID := req.SourceVolumeId + "/" + snapshotName
-
-	target, poolName, volName, err := splitVolumeID(req.SourceVolumeId)
+	sizeBytes, err := units.ParseByteSizeString(size)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "CreateSnapshot: %v", err)
+		return codes.Internal, fmt.Errorf("Failed to parse size %q: %w", size, err)
 	}
 
-	// Set target if provided and LXD is clustered.
-	if target != "" && c.driver.isClustered {
-		client = client.UseTarget(target)
+	if sizeBytes < requiredBytes {
+		return codes.Internal, fmt.Errorf("Size %d is smaller than the requested size %d", sizeBytes, requiredBytes)
 	}
 
-	unlock := locking.TryLock(snapshotID)
-	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "CreateSnapshot: Failed to obtain lock %q", snapshotID)
+	if limitBytes > 0 && sizeBytes > limitBytes {
+		return codes.OutOfRange, fmt.Errorf("Size %d exceeds the volume size limit %d", sizeBytes, limitBytes)
 	}
 
-	defer unlock()
+	return codes.OK, nil
+}
 
-	_, _, err = client.GetStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotName)
-	if err != nil {
-		if !api.StatusErrorCheck(err, http.StatusNotFound) {
-			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: Failed to retrieve snapshot %q of volume %q from pool %q: %v", snapshotName, volName, poolName, err)
-		}
+// volumeDescriptionData contains the values available in the volume
+// description template.
+type volumeDescriptionData struct {
+	// Name of the PVC the volume is created for.
+	PVCName string
 
-		// Create snapshot of storage volume.
-		snapshotReq := api.DevLXDStorageVolumeSnapshotsPost{
-			Name:        snapshotName,
-			Description: "Managed by Kubernetes VolumeSnapshot " + snapshotName,
-		}
+	// Namespace of the PVC the volume is created for.
+	PVCNamespace string
 
-		// Snapshot does not exist yet. Create it.
-		op, err := client.CreateStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotReq)
-		if err == nil {
-			err = op.WaitContext(ctx)
-		}
+	// Name of the PV that represents the volume.
+	PVName string
 
-		if err != nil {
-			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: %v", err)
-		}
-	}
+	// Name of the LXD storage pool the volume is created in.
+	StoragePool string
 
-	return &csi.CreateSnapshotResponse{
-		Snapshot: &csi.Snapshot{
-			SnapshotId:     snapshotID,
-			SourceVolumeId: req.SourceVolumeId,
-			CreationTime:   timestamppb.Now(),
-			ReadyToUse:     true,
-		},
-	}, nil
+	// Name of the LXD volume.
+	VolumeName string
 }
 
-// DeleteSnapshot deletes a snapshot of an LXD custom volume.
-// Missing snapshots are treated as successfully deleted.
-func (c *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
-	client, err := c.driver.DevLXDClient()
+// renderVolumeDescription renders the volume description from the given
+// template. The PVC and PV names are available only if the provisioner
+// passes them to the driver, otherwise they are empty.
+func renderVolumeDescription(descriptionTemplate string, data volumeDescriptionData) (string, error) {
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(descriptionTemplate)
 	if err != nil {
-		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteSnapshot: %v", err)
+		return "", fmt.Errorf("Failed to parse description template: %w", err)
 	}
 
-	target, poolName, volName, snapshotName, err := splitSnapshotID(req.SnapshotId)
+	var description strings.Builder
+	err = tmpl.Execute(&description, data)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "DeleteSnapshot: %v", err)
+		return "", fmt.Errorf("Failed to render description template: %w", err)
 	}
 
-	// Set target if provided and LXD is clustered.
-	if target != "" && c.driver.isClustered {
-		client = client.UseTarget(target)
-	}
+	return description.String(), nil
+}
 
-	unlock := locking.TryLock(req.SnapshotId)
-	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "DeleteSnapshot: Failed to obtain lock %q", req.SnapshotId)
+// isManagedVolume reports whether the given volume was created by the CSI driver.
+// Volumes created before the managed marker was introduced are recognized by
+// their description.
+func isManagedVolume(vol *api.DevLXDStorageVolume) bool {
+	if vol.Config[VolumeConfigManagedBy] == VolumeManagedByValue {
+		return true
 	}
 
-	defer unlock()
+	return strings.HasPrefix(vol.Description, volumeDescriptionPrefix)
+}
 
-	op, err := client.DeleteStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotName)
-	if err == nil {
-		err = op.WaitContext(ctx)
+// volumeLockKey returns the key used to serialize operations on the given
+// volume. The key identifies the underlying LXD volume, so that requests
+// referring to the same volume using different volume IDs share the same lock.
+// For example, when the volume ID contains a storage pool alias, or a target
+// that is ignored because LXD is not clustered.
+//
+// The storage pool name must already be resolved using [Driver.resolvePoolName].
+func (c *controllerServer) volumeLockKey(target string, poolName string, volName string) string {
+	if !c.driver.isClustered {
+		target = ""
 	}
 
-	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
-		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteSnapshot: %v", err)
-	}
+	return getVolumeID(target, poolName, volName)
+}
 
-	return &csi.DeleteSnapshotResponse{}, nil
+// snapshotLockKey returns the key used to serialize operations on the given
+// volume snapshot. Like [controllerServer.volumeLockKey], the key identifies
+// the underlying LXD snapshot regardless of the snapshot ID used.
+//
+// The storage pool name must already be resolved using [Driver.resolvePoolName].
+func (c *controllerServer) snapshotLockKey(target string, poolName string, volName string, snapshotName string) string {
+	return c.volumeLockKey(target, poolName, volName) + "/" + snapshotName
 }
 
-// ControllerPublishVolume attaches an existing LXD custom volume to a node.
-// If the volume is already attached, the operation is considered successful.
+// deprecatedParameters maps deprecated storage class parameter names to the
+// names that replaced them. Deprecated parameters are still accepted, so that
+// existing storage classes keep working after a parameter is renamed.
+var deprecatedParameters = map[string]string{}
+
+// applyDeprecatedParameters replaces deprecated parameters with the parameters
+// that replaced them and logs a warning for each. If both names are set, the
+// current name takes precedence and the deprecated parameter is ignored.
+// It returns the names of the deprecated parameters found.
+func applyDeprecatedParameters(parameters map[string]string) []string {
+	var found []string
+
+	for oldName, newName := range deprecatedParameters {
+		value, ok := parameters[oldName]
+		if !ok {
+			continue
+		}
+
+		found = append(found, oldName)
+		delete(parameters, oldName)
+
+		_, ok = parameters[newName]
+		if ok {
+			klog.InfoS("Warning: Ignoring deprecated storage class parameter, as its replacement is also set", "parameter", oldName, "replacement", newName)
+			continue
+		}
+
+		klog.InfoS("Warning: Storage class parameter is deprecated", "parameter", oldName, "replacement", newName)
+		parameters[newName] = value
+	}
+
+	slices.Sort(found)
+	return found
+}
+
+// validateStorageClassParameters validates the storage class parameters
+// and returns the name of the storage pool. Deprecated parameters are
+// replaced with their current names before validation.
+func validateStorageClassParameters(parameters map[string]string) (string, error) {
+	applyDeprecatedParameters(parameters)
+
+	for k := range parameters {
+		if k != ParameterCSIFSType && strings.HasPrefix(k, "csi.storage.k8s.io/") {
+			// Skip standard CSI parameters.
+			continue
+		}
+
+		key, found := strings.CutPrefix(k, ParameterLXDConfigPrefix)
+		if found {
+			if key == "" {
+				return "", fmt.Errorf("Invalid parameter %q: LXD volume configuration key is empty", k)
+			}
+
+			if isReservedVolumeConfigKey(key) {
+				return "", fmt.Errorf("Invalid parameter %q: LXD volume configuration key %q is managed by the CSI driver", k, key)
+			}
+
+			continue
+		}
+
+		switch k {
+		case ParameterStoragePool:
+		case ParameterFSType, ParameterCSIFSType:
+			if !slices.Contains(fsTypes, parameters[k]) {
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be one of %s", k, parameters[k], strings.Join(fsTypes, ", "))
+			}
+		case ParameterVolumeNamePrefix:
+			err := validateVolumeNamePrefix(parameters[k])
+			if err != nil {
+				return "", fmt.Errorf("Invalid parameter %q value %q: %w", k, parameters[k], err)
+			}
+		case ParameterIOCache:
+			if !slices.Contains(ioCacheModes, parameters[k]) {
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be one of %s", k, parameters[k], strings.Join(ioCacheModes, ", "))
+			}
+		case ParameterReadAheadKB:
+			_, err := strconv.ParseUint(parameters[k], 10, 32)
+			if err != nil {
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be a non-negative integer", k, parameters[k])
+			}
+		case ParameterColocateWith:
+			namespace, name, found := strings.Cut(parameters[k], "/")
+			if !found {
+				name = namespace
+				namespace = ""
+			}
+
+			if name == "" || (found && namespace == "") || strings.Contains(name, "/") {
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be in format \"<name>\" or \"<namespace>/<name>\"", k, parameters[k])
+			}
+		case ParameterMinSizeBlock, ParameterMinSizeFilesystem:
+			size, err := units.ParseByteSizeString(parameters[k])
+			if err != nil || size < 1 {
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be a positive size (for example, \"1GiB\")", k, parameters[k])
+			}
+		case ParameterDescriptionTemplate:
+			// Render the template with empty values to detect both syntax
+			// errors and references to unknown values.
+			_, err := renderVolumeDescription(parameters[k], volumeDescriptionData{})
+			if err != nil {
+				return "", fmt.Errorf("Invalid parameter %q value %q: %w", k, parameters[k], err)
+			}
+		case ParameterPVCLabels:
+			for _, key := range parsePVCLabelKeys(parameters[k]) {
+				errs := k8sValidation.IsQualifiedName(key)
+				if len(errs) > 0 {
+					return "", fmt.Errorf("Invalid parameter %q value %q: Invalid label key %q: %s", k, parameters[k], key, strings.Join(errs, "; "))
+				}
+			}
+		case ParameterDeletionProtection:
+			_, err := strconv.ParseBool(parameters[k])
+			if err != nil {
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be \"true\" or \"false\"", k, parameters[k])
+			}
+		case ParameterDiscard:
+			switch parameters[k] {
+			case DiscardOnline, DiscardPeriodic:
+			default:
+				return "", fmt.Errorf("Invalid parameter %q value %q: Must be one of %q or %q", k, parameters[k], DiscardOnline, DiscardPeriodic)
+			}
+		default:
+			return "", fmt.Errorf("Invalid parameter %q in storage class", k)
+		}
+	}
+
+	poolName := parameters[ParameterStoragePool]
+	if poolName == "" {
+		return "", fmt.Errorf("Storage class parameter %q is required and cannot be empty", ParameterStoragePool)
+	}
+
+	return poolName, nil
+}
+
+// volumeFSType returns the filesystem type requested for the volume. The
+// storage class parameter takes precedence over the standard CSI parameter,
+// which takes precedence over the filesystem type of the volume capabilities.
+// The storage class parameters must already be validated.
+func volumeFSType(parameters map[string]string, volCaps []*csi.VolumeCapability) (string, error) {
+	fsType := parameters[ParameterFSType]
+	if fsType == "" {
+		fsType = parameters[ParameterCSIFSType]
+	}
+
+	if fsType != "" {
+		return fsType, nil
+	}
+
+	for _, c := range volCaps {
+		fsType = c.GetMount().GetFsType()
+		if fsType == "" {
+			continue
+		}
+
+		if !slices.Contains(fsTypes, fsType) {
+			return "", fmt.Errorf("Unsupported filesystem type %q: Must be one of %s", fsType, strings.Join(fsTypes, ", "))
+		}
+
+		return fsType, nil
+	}
+
+	return "", nil
+}
+
+// getSupportedStorageDriver returns the information about the given LXD storage
+// driver, or an error if the driver is not supported by the CSI.
+func getSupportedStorageDriver(state *api.DevLXDGet, driverName string) (*api.DevLXDServerStorageDriverInfo, error) {
+	for _, d := range state.SupportedStorageDrivers {
+		if d.Name == driverName && d.Name != "cephobject" {
+			return &d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("CSI does not support storage driver %q", driverName)
+}
+
+// storageDriverContentTypes contains the content types of custom volumes that
+// can be created on storage drivers that do not support both block and
+// filesystem volumes. Drivers not listed here support both content types.
+var storageDriverContentTypes = map[string][]string{
+	// CephFS is a native shared filesystem and cannot provide block volumes.
+	"cephfs": {"filesystem"},
+}
+
+// validateStorageDriverContentType returns an error if volumes of the given
+// content type cannot be created on the given storage driver. The devLXD
+// storage driver information does not report the supported content types,
+// therefore they are looked up in storageDriverContentTypes.
+func validateStorageDriverContentType(driver *api.DevLXDServerStorageDriverInfo, contentType string) error {
+	contentTypes, ok := storageDriverContentTypes[driver.Name]
+	if !ok || slices.Contains(contentTypes, contentType) {
+		return nil
+	}
+
+	return fmt.Errorf("Storage driver %q does not support volumes with content type %q (supported content types: %s)", driver.Name, contentType, strings.Join(contentTypes, ", "))
+}
+
+// checkSizeRounding reports a warning if the allocated volume size exceeds
+// the requested size by more than the configured threshold.
+func (c *controllerServer) checkSizeRounding(volumeID string, poolName string, requestedBytes int64, allocatedBytes int64) {
+	threshold := c.driver.sizeRoundingWarningThreshold
+	if threshold <= 0 || requestedBytes <= 0 {
+		return
+	}
+
+	// Compare using integers to avoid rounding errors.
+	if (allocatedBytes-requestedBytes)*100 <= requestedBytes*int64(threshold) {
+		return
+	}
+
+	klog.InfoS("Warning: Allocated volume size significantly exceeds the requested size",
+		"volumeID", volumeID,
+		"pool", poolName,
+		"requestedBytes", requestedBytes,
+		"allocatedBytes", allocatedBytes,
+		"thresholdPercent", threshold,
+	)
+
+	volumeSizeRoundedTotal.Inc(poolName)
+}
+
+// waitVolumeReady polls the storage volume until it exists and has the
+// expected content type, or until the context is done.
+func waitVolumeReady(ctx context.Context, client lxdClient.DevLXDServer, poolName string, volName string, contentType string) (*api.DevLXDStorageVolume, error) {
+	ticker := time.NewTicker(volumeReadyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		vol, _, err := client.GetStoragePoolVolume(poolName, "custom", volName)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil, err
+		}
+
+		if vol != nil && vol.ContentType == contentType {
+			return vol, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitOperation waits for the given LXD operation to complete. If the
+// operation fails, the returned error includes the LXD operation ID (when
+// available), which can be used to inspect the operation on the LXD side.
+func waitOperation(ctx context.Context, action string, op lxdClient.DevLXDOperation) error {
+	err := op.WaitContext(ctx)
+	if err == nil {
+		return nil
+	}
+
+	opID := op.Get().ID
+	if opID == "" {
+		return err
+	}
+
+	klog.ErrorS(err, action+": LXD operation failed", "operationID", opID)
+	return fmt.Errorf("LXD operation %q failed: %w", opID, err)
+}
+
+// colocatedVolumeTarget returns the LXD cluster member of the volume that
+// belongs to the PVC referenced by colocateWith. The volume is looked up in
+// the given storage pool using the PVC recorded in the volume configuration.
+// If the reference does not contain a namespace, defaultNamespace is used.
+func colocatedVolumeTarget(client lxdClient.DevLXDServer, poolName string, colocateWith string, defaultNamespace string) (string, error) {
+	namespace, name, found := strings.Cut(colocateWith, "/")
+	if !found {
+		name = namespace
+		namespace = defaultNamespace
+	}
+
+	vols, err := client.GetStoragePoolVolumes(poolName)
+	if err != nil {
+		return "", fmt.Errorf("Failed to retrieve storage volumes from pool %q: %w", poolName, err)
+	}
+
+	for _, vol := range vols {
+		if vol.Type != "custom" || vol.Config[VolumeConfigManagedBy] != VolumeManagedByValue {
+			continue
+		}
+
+		if vol.Config[VolumeConfigPVCName] != name || vol.Config[VolumeConfigPVCNamespace] != namespace {
+			continue
+		}
+
+		if vol.Location == "" || vol.Location == "none" {
+			return "", fmt.Errorf("Cannot co-locate with volume %q of PVC %q: Volume location is unknown", vol.Name, colocateWith)
+		}
+
+		return vol.Location, nil
+	}
+
+	return "", api.StatusErrorf(http.StatusNotFound, "Volume of PVC %q to co-locate with not found in storage pool %q", colocateWith, poolName)
+}
+
+// isMemberOnline reports whether the given LXD cluster member is online.
+// The member is probed by retrieving the storage pool through the client
+// targeting that member. The result is cached to avoid probing the member
+// on every request.
+func (c *controllerServer) isMemberOnline(client lxdClient.DevLXDServer, member string, poolName string) bool {
+	online, ok := c.memberHealth.Get(member)
+	if ok {
+		return online
+	}
+
+	_, _, err := client.GetStoragePool(poolName)
+	if err != nil && lxderrors.IsMemberOffline(err) {
+		c.memberHealth.Set(member, false)
+		return false
+	}
+
+	// Treat other errors as online and let the subsequent request surface them.
+	if err == nil {
+		c.memberHealth.Set(member, true)
+	}
+
+	return true
+}
+
+// isPoolMissing reports whether the given storage pool no longer exists.
+// LXD reports volumes in a missing storage pool as not found, therefore the
+// storage pool is checked to distinguish a missing pool from a missing volume.
+func isPoolMissing(client lxdClient.DevLXDServer, poolName string) bool {
+	_, _, err := client.GetStoragePool(poolName)
+	return api.StatusErrorCheck(err, http.StatusNotFound)
+}
+
+// DeleteVolume deletes a volume from the LXD storage pool.
+func (c *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: %v", err)
+	}
+
+	target, poolName, volName, err := splitVolumeID(req.VolumeId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "DeleteVolume: %v", err)
+	}
+
+	if c.driver.MaintenanceMode() {
+		return nil, status.Error(codes.Unavailable, "DeleteVolume: Volume deletion is paused for maintenance")
+	}
+
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// Set target if provided and LXD is clustered.
+	if target != "" && c.driver.isClustered {
+		client = client.UseTarget(target)
+	}
+
+	lockKey := c.volumeLockKey(target, poolName, volName)
+	unlock := locking.TryLock(lockKey)
+	if unlock == nil {
+		return nil, status.Errorf(codes.Aborted, "DeleteVolume: Failed to obtain lock %q", lockKey)
+	}
+
+	defer unlock()
+
+	release, err := c.poolLimiter.Acquire(ctx, poolName)
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: Failed to acquire operation slot for storage pool %q: %v", poolName, err)
+	}
+
+	defer release()
+
+	// If a previous request has started the deletion that did not complete
+	// in time, wait for the existing operation instead of starting a new one.
+	op := c.pendingDeletes.Get(lockKey)
+	if op != nil {
+		err = op.WaitContext(ctx)
+		if ctx.Err() != nil {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(ctx.Err()), "DeleteVolume: Deletion of volume %q from storage pool %q is still in progress", volName, poolName)
+		}
+
+		c.pendingDeletes.Delete(lockKey)
+
+		if err == nil {
+			return &csi.DeleteVolumeResponse{}, nil
+		}
+
+		// The previous deletion has failed or its operation is no longer
+		// known to LXD. Retry the deletion.
+	}
+
+	// Ensure the volume was created by the driver before deleting it.
+	// If volume or its storage pool does not exist, we consider the
+	// operation successful, as there is nothing to delete.
+	vol, etag, err := client.GetStoragePoolVolume(poolName, "custom", volName)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			if isPoolMissing(client, poolName) {
+				klog.InfoS("DeleteVolume: Storage pool no longer exists, nothing to delete", "volumeID", req.VolumeId, "pool", poolName, "volume", volName)
+			}
+
+			return &csi.DeleteVolumeResponse{}, nil
+		}
+
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: Failed to retrieve volume %q from storage pool %q: %v", volName, poolName, err)
+	}
+
+	if !isManagedVolume(vol) {
+		return nil, status.Errorf(codes.FailedPrecondition, "DeleteVolume: Refusing to delete volume %q from storage pool %q: Volume is not managed by the CSI driver", volName, poolName)
+	}
+
+	if isDeletionProtected(vol) {
+		err := checkDeletionConfirmation(volName, req.Secrets)
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "DeleteVolume: %v", err)
+		}
+
+		klog.InfoS("DeleteVolume: Deleting protected volume with confirmed deletion", "volumeID", req.VolumeId, "pool", poolName, "volume", volName)
+	}
+
+	// In dry-run mode, report the volume that would be deleted and leave
+	// it in place. The request fails, so that the persistent volume is
+	// kept and the deletion is retried once dry-run mode is disabled.
+	if c.driver.deleteVolumeDryRun {
+		klog.InfoS("DeleteVolume: Dry run, skipping volume deletion", "volumeID", req.VolumeId, "pool", poolName, "volume", volName, "size", vol.Config["size"])
+		return nil, status.Errorf(codes.FailedPrecondition, "DeleteVolume: Dry run: Would delete volume %q from storage pool %q", volName, poolName)
+	}
+
+	// With a delete grace period, only mark the volume as deleted and
+	// leave the deletion to the soft-deleted volume sweeper.
+	if c.driver.deleteVolumeGracePeriod > 0 {
+		expiry, err := softDeleteVolume(ctx, client, poolName, vol, etag, c.driver.deleteVolumeGracePeriod)
+		if err != nil {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: Failed to mark volume %q in storage pool %q as deleted: %v", volName, poolName, err)
+		}
+
+		c.softDeletePools.Track(poolName)
+		klog.InfoS("DeleteVolume: Volume marked as deleted", "volumeID", req.VolumeId, "pool", poolName, "volume", volName, "deleteAfter", expiry)
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
+	// Delete storage volume. If volume does not exist, we consider
+	// the operation successful.
+	err = retry(ctx, "DeleteVolume", c.driver.maxRetries, func() error {
+		var err error
+		op, err = client.DeleteStoragePoolVolume(poolName, "custom", volName)
+		return err
+	})
+
+	if err == nil {
+		err = waitOperation(ctx, "DeleteVolume", op)
+
+		// Keep track of the operation if the request has timed out or was
+		// cancelled while the deletion is still in progress.
+		if ctx.Err() != nil {
+			c.pendingDeletes.Set(lockKey, op)
+			return nil, status.Errorf(lxderrors.ToGRPCCode(ctx.Err()), "DeleteVolume: Deletion of volume %q from storage pool %q is still in progress", volName, poolName)
+		}
+	}
+
+	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteVolume: Failed to delete volume %q from storage pool %q: %v", volName, poolName, err)
+	}
+
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+// CreateSnapshot creates a snapshot of a PVC that references an existing LXD custom volume.
+func (c *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: %v", err)
+	}
+
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "CreateSnapshot: Snapshot name cannot be empty")
+	}
+
+	if req.SourceVolumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "CreateSnapshot: Source volume ID cannot be empty")
+	}
+
+	// Generate snapshot name and ID.
+	// Snapshot name is constructed from the requested snapshot name by removing dashes
+	// from the UUID portion. This shortens the snapshot name while keeping it unique.
+	snapshotPrefix, snapshotUUID, found := strings.Cut(req.Name, "-")
+	if !found {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateVolume: Unexpected volume name format: %q", req.Name)
+	}
+
+	deletionPolicy := req.Parameters[ParameterSnapshotDeletionPolicy]
+	switch deletionPolicy {
+	case "", SnapshotDeletionPolicyDelete, SnapshotDeletionPolicyRetain:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "CreateSnapshot: Invalid snapshot deletion policy %q: Must be one of %q or %q", deletionPolicy, SnapshotDeletionPolicyDelete, SnapshotDeletionPolicyRetain)
+	}
+
+	var maxSnapshots int
+	maxSnapshotsStr := req.Parameters[ParameterSnapshotsMaxCount]
+	if maxSnapshotsStr != "" {
+		maxSnapshots, err = strconv.Atoi(maxSnapshotsStr)
+		if err != nil || maxSnapshots < 1 {
+			return nil, status.Errorf(codes.InvalidArgument, "CreateSnapshot: Invalid parameter %q value %q: Must be a positive integer", ParameterSnapshotsMaxCount, maxSnapshotsStr)
+		}
+	}
+
+	snapshotName := snapshotPrefix + "-" + strings.ReplaceAll(snapshotUUID, "-", "")
+	snapshotID := req.SourceVolumeId + "/" + snapshotName
+
+	target, poolName, volName, err := splitVolumeID(req.SourceVolumeId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "CreateSnapshot: %v", err)
+	}
+
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// Set target if provided and LXD is clustered.
+	if target != "" && c.driver.isClustered {
+		client = client.UseTarget(target)
+	}
+
+	lockKey := c.snapshotLockKey(target, poolName, volName, snapshotName)
+	unlock := locking.TryLock(lockKey)
+	if unlock == nil {
+		return nil, status.Errorf(codes.Aborted, "CreateSnapshot: Failed to obtain lock %q", lockKey)
+	}
+
+	defer unlock()
+
+	release, err := c.poolLimiter.Acquire(ctx, poolName)
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: Failed to acquire operation slot for storage pool %q: %v", poolName, err)
+	}
+
+	defer release()
+
+	_, _, err = client.GetStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotName)
+	if err != nil {
+		if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: Failed to retrieve snapshot %q of volume %q from pool %q: %v", snapshotName, volName, poolName, err)
+		}
+
+		// Ensure the volume has not reached the maximum number of snapshots.
+		if maxSnapshots > 0 {
+			snapshots, err := client.GetStoragePoolVolumeSnapshots(poolName, "custom", volName)
+			if err != nil {
+				return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: Failed to retrieve snapshots of volume %q from pool %q: %v", volName, poolName, err)
+			}
+
+			if len(snapshots) >= maxSnapshots {
+				return nil, status.Errorf(codes.ResourceExhausted, "CreateSnapshot: Volume %q in storage pool %q has reached the maximum number of snapshots (%d)", volName, poolName, maxSnapshots)
+			}
+		}
+
+		// If VolumeSnapshot name was passed to the driver, use it in the snapshot
+		// description, so that the snapshot can be traced back to Kubernetes.
+		// Otherwise, use the snapshot name.
+		snapshotIdentifier := snapshotName
+		volumeSnapshotName := req.Parameters[ParameterVolumeSnapshotName]
+		if volumeSnapshotName != "" {
+			snapshotIdentifier = volumeSnapshotName
+
+			volumeSnapshotNamespace := req.Parameters[ParameterVolumeSnapshotNamespace]
+			if volumeSnapshotNamespace != "" {
+				snapshotIdentifier = volumeSnapshotNamespace + "/" + volumeSnapshotName
+			}
+		}
+
+		// Create snapshot of storage volume.
+		snapshotReq := api.DevLXDStorageVolumeSnapshotsPost{
+			Name:        snapshotName,
+			Description: "Managed by Kubernetes VolumeSnapshot " + snapshotIdentifier,
+		}
+
+		// Devlxd does not allow setting snapshot configuration, therefore
+		// the deletion policy is recorded in the snapshot description.
+		if deletionPolicy == SnapshotDeletionPolicyRetain {
+			snapshotReq.Description += snapshotRetainDescriptionSuffix
+		}
+
+		// Snapshot does not exist yet. Create it.
+		op, err := client.CreateStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotReq)
+		if err == nil {
+			err = waitOperation(ctx, "CreateSnapshot", op)
+		}
+
+		if err != nil {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "CreateSnapshot: %v", err)
+		}
+	}
+
+	return &csi.CreateSnapshotResponse{
+		Snapshot: &csi.Snapshot{
+			SnapshotId:     snapshotID,
+			SourceVolumeId: req.SourceVolumeId,
+			CreationTime:   timestamppb.Now(),
+			ReadyToUse:     true,
+		},
+	}, nil
+}
+
+// DeleteSnapshot deletes a snapshot of an LXD custom volume.
+// Missing snapshots are treated as successfully deleted.
+func (c *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteSnapshot: %v", err)
+	}
+
+	target, poolName, volName, snapshotName, err := splitSnapshotID(req.SnapshotId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "DeleteSnapshot: %v", err)
+	}
+
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// Set target if provided and LXD is clustered.
+	if target != "" && c.driver.isClustered {
+		client = client.UseTarget(target)
+	}
+
+	lockKey := c.snapshotLockKey(target, poolName, volName, snapshotName)
+	unlock := locking.TryLock(lockKey)
+	if unlock == nil {
+		return nil, status.Errorf(codes.Aborted, "DeleteSnapshot: Failed to obtain lock %q", lockKey)
+	}
+
+	defer unlock()
+
+	release, err := c.poolLimiter.Acquire(ctx, poolName)
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteSnapshot: Failed to acquire operation slot for storage pool %q: %v", poolName, err)
+	}
+
+	defer release()
+
+	snapshot, _, err := client.GetStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotName)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return &csi.DeleteSnapshotResponse{}, nil
+		}
+
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteSnapshot: Failed to retrieve snapshot %q of volume %q from pool %q: %v", snapshotName, volName, poolName, err)
+	}
+
+	// Keep the LXD snapshot if it was created with the "Retain" deletion policy.
+	if strings.HasSuffix(snapshot.Description, snapshotRetainDescriptionSuffix) {
+		klog.InfoS("Retaining LXD snapshot due to snapshot deletion policy", "snapshotID", req.SnapshotId, "deletionPolicy", SnapshotDeletionPolicyRetain)
+		return &csi.DeleteSnapshotResponse{}, nil
+	}
+
+	op, err := client.DeleteStoragePoolVolumeSnapshot(poolName, "custom", volName, snapshotName)
+	if err == nil {
+		err = waitOperation(ctx, "DeleteSnapshot", op)
+	}
+
+	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "DeleteSnapshot: %v", err)
+	}
+
+	return &csi.DeleteSnapshotResponse{}, nil
+}
+
+// ControllerPublishVolume attaches an existing LXD custom volume to a node.
+// If the volume is already attached, the operation is considered successful.
 func (c *controllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
 	client, err := c.driver.DevLXDClient()
 	if err != nil {
@@ -539,6 +1550,17 @@ func (c *controllerServer) ControllerPublishVolume(ctx context.Context, req *csi
 		return nil, status.Errorf(codes.InvalidArgument, "ControllerPublishVolume: %v", err)
 	}
 
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// The volume is always located using the pool from the volume ID. The
+	// storage pool in the volume context reflects the storage class at the
+	// time of provisioning and may no longer match if the volume was moved
+	// or the pool was renamed.
+	contextPoolName := req.VolumeContext[ParameterStoragePool]
+	if contextPoolName != "" && c.driver.resolvePoolName(contextPoolName) != poolName {
+		klog.InfoS("Storage pool in volume context differs from the volume ID, using the pool from the volume ID", "volumeID", req.VolumeId, "pool", poolName, "contextPool", contextPoolName)
+	}
+
 	// Set target if provided and LXD is clustered.
 	if target != "" && c.driver.isClustered {
 		client = client.UseTarget(target)
@@ -549,88 +1571,200 @@ func (c *controllerServer) ControllerPublishVolume(ctx context.Context, req *csi
 		return nil, status.Error(codes.InvalidArgument, "ControllerPublishVolume: Volume capability must specify either block or filesystem access type")
 	}
 
-	unlock := locking.TryLock(req.VolumeId)
+	lockKey := c.volumeLockKey(target, poolName, volName)
+	unlock := locking.TryLock(lockKey)
 	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "ControllerPublishVolume: Failed to obtain lock %q", req.VolumeId)
+		return nil, status.Errorf(codes.Aborted, "ControllerPublishVolume: Failed to obtain lock %q", lockKey)
 	}
 
 	defer unlock()
 
 	// Get existing storage pool volume.
-	_, _, err = client.GetStoragePoolVolume(poolName, "custom", volName)
+	vol, volETag, err := client.GetStoragePoolVolume(poolName, "custom", volName)
 	if err != nil {
 		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			if isPoolMissing(client, poolName) {
+				return nil, status.Errorf(codes.FailedPrecondition, "ControllerPublishVolume: Storage pool %q of volume %q no longer exists", poolName, volName)
+			}
+
 			return nil, status.Errorf(codes.NotFound, "ControllerPublishVolume: Volume %q not found in storage pool %q", volName, poolName)
 		}
 
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerPublishVolume: Failed to retrieve volume %q from storage pool %q: %v", volName, poolName, err)
 	}
 
+	// Local volumes can only be attached to instances running on the cluster
+	// member that hosts the volume. Reject the request if the node runs on a
+	// different member, instead of failing later when the volume is mounted.
+	if target != "" && c.driver.isClustered {
+		labels, err := c.nodeLabels(ctx, req.NodeId)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "ControllerPublishVolume: %v", err)
+		}
+
+		nodeMember := labels[c.driver.topologyKey]
+		if nodeMember != "" && nodeMember != target {
+			return nil, status.Errorf(codes.FailedPrecondition, "ControllerPublishVolume: Volume %q is located on cluster member %q, but node %q runs on cluster member %q", volName, target, req.NodeId, nodeMember)
+		}
+	}
+
+	// Prevent attaching a single-writer volume to a second node, which
+	// could corrupt the data. The recorded attachment is verified, as it
+	// may be stale if the volume was detached outside of the driver.
+	singleWriter := isSingleWriter(req.VolumeCapability)
+	attachedTo := vol.Config[VolumeConfigAttachedTo]
+	if singleWriter && attachedTo != "" && attachedTo != req.NodeId {
+		attached, err := isAttachedTo(client, attachedTo, poolName, volName)
+		if err != nil {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerPublishVolume: Failed to check attachment of volume %q to node %q: %v", volName, attachedTo, err)
+		}
+
+		if attached {
+			return nil, status.Errorf(codes.FailedPrecondition, "ControllerPublishVolume: Volume %q is already attached to node %q", volName, attachedTo)
+		}
+
+		klog.InfoS("Ignoring stale volume attachment", "volumeID", req.VolumeId, "node", attachedTo)
+	}
+
+	// Record the node the volume is attached to, so that a single-writer
+	// volume is not attached to a second node, and so that ListVolumes reports
+	// the published node. All supported access modes are single-node, so the
+	// recorded node of a volume published with a read-only access mode is
+	// also the only node it is attached to. Such a publish does not replace
+	// an existing record, which may belong to a single-writer attachment on
+	// another node, as only single-writer publishes verify the record above.
+	recordAttachment := singleWriter || attachedTo == ""
+
 	inst, etag, err := client.GetInstance(req.NodeId)
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerPublishVolume: %v", err)
 	}
 
+	// The LXD device is named after the volume. Device tuning parameters
+	// are passed to the node as well.
+	publishContext := map[string]string{
+		PublishContextDeviceName: volName,
+	}
+
+	for _, param := range []string{ParameterIOCache, ParameterReadAheadKB} {
+		value := req.VolumeContext[param]
+		if value != "" && contentType == "block" {
+			publishContext[param] = value
+		}
+	}
+
 	dev, ok := inst.Devices[volName]
 	if ok {
 		// If the device already exists, ensure it matches the expected parameters.
-		if dev["type"] != "disk" || dev["source"] != volName || dev["pool"] != poolName {
+		if dev["type"] == "disk" && dev["source"] == volName && dev["pool"] == poolName {
+			klog.InfoS("Volume is already attached to node", "volumeID", req.VolumeId, "node", req.NodeId)
+
+			if recordAttachment {
+				recordVolumeAttachment(ctx, client, req.VolumeId, poolName, vol, volETag, req.NodeId)
+			}
+
+			volumePublishTotal.Inc(publishOutcomeAlreadyAttached)
+			attachedVolumes.Set(float64(countAttachedVolumes(inst.Devices)), req.NodeId)
+			return &csi.ControllerPublishVolumeResponse{PublishContext: publishContext}, nil
+		}
+
+		if !c.driver.reconcilePublishDevices {
 			return nil, status.Errorf(codes.AlreadyExists, "ControllerPublishVolume: Device %q already exists on node %q but does not match expected parameters", volName, req.NodeId)
 		}
 
-		return &csi.ControllerPublishVolumeResponse{}, nil
+		// Replace the mismatched device with the expected one.
+		klog.InfoS("Reconciling mismatched device", "device", volName, "node", req.NodeId, "source", dev["source"], "pool", dev["pool"])
 	}
 
-	reqInst := api.DevLXDInstancePut{
-		Devices: map[string]map[string]string{
-			volName: {
-				"source": volName,
-				"pool":   poolName,
-				"type":   "disk",
-			},
-		},
+	device := map[string]string{
+		"source": volName,
+		"pool":   poolName,
+		"type":   "disk",
 	}
 
 	if contentType == "filesystem" {
 		// For filesystem volumes, provide the path where the volume is mounted.
-		reqInst.Devices[volName]["path"] = filepath.Join(driverFileSystemMountPath, volName)
+		device["path"] = filepath.Join(driverFileSystemMountPath, volName)
 	}
 
-	err = client.UpdateInstance(req.NodeId, reqInst, etag)
+	if publishContext[ParameterIOCache] != "" {
+		device["io.cache"] = publishContext[ParameterIOCache]
+	}
+
+	err = c.publishBatcher.AddDevice(ctx, client, target, req.NodeId, etag, volName, device)
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerPublishVolume: Failed to attach volume %q: %v", volName, err)
 	}
 
-	return &csi.ControllerPublishVolumeResponse{}, nil
+	if recordAttachment {
+		recordVolumeAttachment(ctx, client, req.VolumeId, poolName, vol, volETag, req.NodeId)
+	}
+
+	volumePublishTotal.Inc(publishOutcomeAttached)
+
+	devices := maps.Clone(inst.Devices)
+	if devices == nil {
+		devices = make(map[string]map[string]string)
+	}
+
+	devices[volName] = device
+	attachedVolumes.Set(float64(countAttachedVolumes(devices)), req.NodeId)
+
+	return &csi.ControllerPublishVolumeResponse{PublishContext: publishContext}, nil
+}
+
+// countAttachedVolumes returns the number of volumes attached as the given
+// instance devices. Volumes are attached as disk devices named after the
+// custom volume.
+func countAttachedVolumes(devices map[string]map[string]string) int {
+	count := 0
+	for name, dev := range devices {
+		if dev["type"] == "disk" && dev["pool"] != "" && dev["source"] == name {
+			count++
+		}
+	}
+
+	return count
 }
 
 // ControllerUnpublishVolume detaches LXD custom volume from a node.
 // If the volume is not attached, the operation is considered successful.
+//
+// The devLXD API does not report the instance state, so the device removal
+// is always requested and LXD handles it according to the instance state:
+//
+//   - Running instance: the device is unplugged from the instance.
+//   - Stopped instance: the device is removed from the instance configuration.
+//   - Instance stopping or starting: LXD may fail to unplug the device, in
+//     which case a retryable error is returned.
 func (c *controllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
 	client, err := c.driver.DevLXDClient()
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerUnpublishVolume: %v", err)
 	}
 
-	target, _, volName, err := splitVolumeID(req.VolumeId)
+	target, poolName, volName, err := splitVolumeID(req.VolumeId)
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "ControllerUnpublishVolume: %v", err)
 	}
 
+	poolName = c.driver.resolvePoolName(poolName)
+
 	// Set target if provided and LXD is clustered.
 	if target != "" && c.driver.isClustered {
 		client = client.UseTarget(target)
 	}
 
-	unlock := locking.TryLock(req.VolumeId)
+	lockKey := c.volumeLockKey(target, poolName, volName)
+	unlock := locking.TryLock(lockKey)
 	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "ControllerUnpublishVolume: Failed to obtain lock %q", req.VolumeId)
+		return nil, status.Errorf(codes.Aborted, "ControllerUnpublishVolume: Failed to obtain lock %q", lockKey)
 	}
 
 	defer unlock()
 
 	// Fetch existing instance to retrieve the ETag.
-	_, etag, err := client.GetInstance(req.NodeId)
+	inst, etag, err := client.GetInstance(req.NodeId)
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerUnpublishVolume: Failed to retrieve instance %q: %v", req.NodeId, err)
 	}
@@ -645,9 +1779,41 @@ func (c *controllerServer) ControllerUnpublishVolume(ctx context.Context, req *c
 	// If volume attachment does not exist, consider the operation successful.
 	err = client.UpdateInstance(req.NodeId, reqInst, etag)
 	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+		// Instance is locked by another operation. Return a retryable
+		// error so that the detach is retried with a backoff.
+		if lxderrors.IsInstanceBusy(err) {
+			return nil, status.Errorf(codes.Aborted, "ControllerUnpublishVolume: Instance %q is busy with another operation: %v", req.NodeId, err)
+		}
+
+		// Instance stopped or started while the device was being
+		// unplugged. Return a retryable error, as the device can be
+		// removed once the instance settles in either state.
+		if lxderrors.IsInstanceNotRunning(err) {
+			return nil, status.Errorf(codes.Unavailable, "ControllerUnpublishVolume: Instance %q changed state while detaching volume %q: %v", req.NodeId, volName, err)
+		}
+
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerUnpublishVolume: Failed to detach volume %q: %v", volName, err)
 	}
 
+	devices := maps.Clone(inst.Devices)
+	delete(devices, volName)
+	attachedVolumes.Set(float64(countAttachedVolumes(devices)), req.NodeId)
+
+	// Remove the attachment record of the volume. Failures are not fatal,
+	// as a stale record is detected when the volume is published again.
+	vol, volETag, err := client.GetStoragePoolVolume(poolName, "custom", volName)
+	if err == nil && vol.Config[VolumeConfigAttachedTo] == req.NodeId {
+		err = setVolumeAttachment(ctx, client, "ControllerUnpublishVolume", poolName, vol, volETag, "")
+	}
+
+	if err != nil {
+		if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			klog.ErrorS(err, "Failed to remove volume attachment record", "volumeID", req.VolumeId, "node", req.NodeId)
+		} else if isPoolMissing(client, poolName) {
+			klog.InfoS("ControllerUnpublishVolume: Storage pool of detached volume no longer exists", "volumeID", req.VolumeId, "pool", poolName, "node", req.NodeId)
+		}
+	}
+
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
@@ -663,6 +1829,8 @@ func (c *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.
 		return nil, status.Errorf(codes.InvalidArgument, "ExpandVolume: %v", err)
 	}
 
+	poolName = c.driver.resolvePoolName(poolName)
+
 	// Set target if provided and LXD is clustered.
 	if target != "" && c.driver.isClustered {
 		client = client.UseTarget(target)
@@ -673,15 +1841,27 @@ func (c *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.
 		return nil, status.Errorf(codes.InvalidArgument, "ExpandVolume: %v", err)
 	}
 
-	unlock := locking.TryLock(req.VolumeId)
+	lockKey := c.volumeLockKey(target, poolName, volName)
+	unlock := locking.TryLock(lockKey)
 	if unlock == nil {
-		return nil, status.Errorf(codes.Aborted, "ExpandVolume: Failed to obtain lock %q: %v", req.VolumeId, err)
+		return nil, status.Errorf(codes.Aborted, "ExpandVolume: Failed to obtain lock %q", lockKey)
 	}
 
 	defer unlock()
 
+	release, err := c.poolLimiter.Acquire(ctx, poolName)
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ExpandVolume: Failed to acquire operation slot for storage pool %q: %v", poolName, err)
+	}
+
+	defer release()
+
 	vol, etag, err := client.GetStoragePoolVolume(poolName, "custom", volName)
 	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) && isPoolMissing(client, poolName) {
+			return nil, status.Errorf(codes.FailedPrecondition, "ExpandVolume: Storage pool %q of volume %q no longer exists", poolName, volName)
+		}
+
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ExpandVolume: %v", err)
 	}
 
@@ -690,26 +1870,21 @@ func (c *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.
 		return nil, status.Errorf(codes.Internal, "ExpandVolume: Volume %q in storage pool %q does not have size configured", volName, poolName)
 	}
 
-	oldSizeBytes, err := strconv.ParseInt(oldSize, 10, 64)
+	oldSizeBytes, err := units.ParseByteSizeString(oldSize)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "ExpandVolume: Failed to parse current volume size %q for volume %q in storage pool %q: %v", oldSize, volName, poolName, err)
 	}
 
-	newSizeBytes := req.CapacityRange.RequiredBytes
+	newSizeBytes := req.GetCapacityRange().GetRequiredBytes()
 
-	// Volume shrinking is currently not supported by Kubernetes.
-	// However, to be on the safe side, we double check that the request is
-	// not trying to shrink the volume size.
-	if newSizeBytes < oldSizeBytes {
-		oldSizePretty := units.GetByteSizeStringIEC(oldSizeBytes, 2)
-		newSizePretty := units.GetByteSizeStringIEC(newSizeBytes, 2)
-		return nil, status.Errorf(codes.InvalidArgument, "ExpandVolume: Requested size %q is less than the current size %q", newSizePretty, oldSizePretty)
-	}
-
-	if newSizeBytes == oldSizeBytes {
-		// Nothing to do. New size equals the already configured size.
+	// The external-resizer may request the same expansion multiple times.
+	// If the volume is already at or above the requested size (for example,
+	// because of a previous request or LXD rounding the size up), there is
+	// nothing to do. This also ensures the volume is never shrunk, and that
+	// a request with an unset or zero size only reports the current size.
+	if oldSizeBytes >= newSizeBytes {
 		return &csi.ControllerExpandVolumeResponse{
-			CapacityBytes:         newSizeBytes,
+			CapacityBytes:         oldSizeBytes,
 			NodeExpansionRequired: false,
 		}, nil
 	}
@@ -718,16 +1893,7 @@ func (c *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.
 	config := maps.Clone(vol.Config)
 	config["size"] = strconv.FormatInt(newSizeBytes, 10)
 
-	volReq := api.DevLXDStorageVolumePut{
-		Description: vol.Description,
-		Config:      config,
-	}
-
-	op, err := client.UpdateStoragePoolVolume(poolName, "custom", volName, volReq, etag)
-	if err == nil {
-		err = op.WaitContext(ctx)
-	}
-
+	err = updateVolumeConfig(ctx, client, "ExpandVolume", poolName, vol, etag, config)
 	if err != nil {
 		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ExpandVolume: Failed to expand volume: %v", err)
 	}
@@ -737,3 +1903,57 @@ func (c *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.
 		NodeExpansionRequired: false,
 	}, nil
 }
+
+// ValidateVolumeCapabilities checks whether the volume capabilities requested
+// are supported for the given existing volume.
+func (c *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ValidateVolumeCapabilities: %v", err)
+	}
+
+	target, poolName, volName, err := splitVolumeID(req.VolumeId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "ValidateVolumeCapabilities: %v", err)
+	}
+
+	if len(req.VolumeCapabilities) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "ValidateVolumeCapabilities: Request has no volume capabilities")
+	}
+
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// Set target if provided and LXD is clustered.
+	if target != "" && c.driver.isClustered {
+		client = client.UseTarget(target)
+	}
+
+	vol, _, err := client.GetStoragePoolVolume(poolName, "custom", volName)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil, status.Errorf(codes.NotFound, "ValidateVolumeCapabilities: Volume %q not found in storage pool %q", volName, poolName)
+		}
+
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ValidateVolumeCapabilities: Failed to retrieve volume %q from storage pool %q: %v", volName, poolName, err)
+	}
+
+	err = ValidateVolumeCapabilities(req.VolumeCapabilities...)
+	if err != nil {
+		return &csi.ValidateVolumeCapabilitiesResponse{Message: err.Error()}, nil
+	}
+
+	contentType := ParseContentType(req.VolumeCapabilities...)
+	if contentType != vol.ContentType {
+		return &csi.ValidateVolumeCapabilitiesResponse{
+			Message: fmt.Sprintf("Requested content type %q does not match the volume content type %q", contentType, vol.ContentType),
+		}, nil
+	}
+
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.VolumeContext,
+			VolumeCapabilities: req.VolumeCapabilities,
+			Parameters:         req.Parameters,
+		},
+	}, nil
+}
@@ -2,11 +2,20 @@ package driver
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	lxdClient "github.com/canonical/lxd/client"
 	"github.com/canonical/lxd/shared/api"
@@ -15,34 +24,173 @@ import (
 // fakeDevLXDOperation implements lxdClient.DevLXDOperation for testing.
 type fakeDevLXDOperation struct {
 	lxdClient.DevLXDOperation
+
+	// id is the ID of the operation.
+	id string
+
+	// err is the error returned when waiting for the operation.
+	err error
+}
+
+func (f *fakeDevLXDOperation) Get() api.DevLXDOperation {
+	return api.DevLXDOperation{ID: f.id}
 }
 
 func (f *fakeDevLXDOperation) WaitContext(ctx context.Context) error {
-	return nil
+	return f.err
+}
+
+// fakeAsyncDevLXDOperation implements lxdClient.DevLXDOperation that completes
+// once the done channel is closed.
+type fakeAsyncDevLXDOperation struct {
+	lxdClient.DevLXDOperation
+
+	done chan struct{}
+}
+
+func (f *fakeAsyncDevLXDOperation) Get() api.DevLXDOperation {
+	return api.DevLXDOperation{}
+}
+
+func (f *fakeAsyncDevLXDOperation) WaitContext(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // fakeDevLXDServer mocks lxdClient.DevLXDServer for testing.
 type fakeDevLXDServer struct {
 	lxdClient.DevLXDServer
 
+	// target is set when the client is targeting a specific cluster member.
+	target string
+
+	getVolsFunc   func(pool string) ([]api.DevLXDStorageVolume, error)
 	getVolFunc    func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error)
-	updateVolFunc func(pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error)
+	updateVolFunc func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error)
+
+	getSnapshotsFunc   func(pool string, volType string, volName string) ([]api.DevLXDStorageVolumeSnapshot, error)
+	getSnapshotFunc    func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error)
+	createSnapshotFunc func(pool string, volType string, volName string, snapshot api.DevLXDStorageVolumeSnapshotsPost) (lxdClient.DevLXDOperation, error)
+	deleteSnapshotFunc func(pool string, volType string, volName string, snapshotName string) (lxdClient.DevLXDOperation, error)
+
+	deleteVolFunc  func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error)
+	getInstFunc    func(name string) (*api.DevLXDInstance, string, error)
+	updateInstFunc func(name string, inst api.DevLXDInstancePut, ETag string) error
+	getStateFunc   func() (*api.DevLXDGet, error)
+	getPoolFunc    func(target string, pool string) (*api.DevLXDStoragePool, string, error)
+	createVolFunc  func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error)
+}
+
+func (f *fakeDevLXDServer) DeleteStoragePoolVolume(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+	if f.deleteVolFunc != nil {
+		return f.deleteVolFunc(pool, volType, name)
+	}
+	return &fakeDevLXDOperation{}, nil
+}
+
+func (f *fakeDevLXDServer) GetInstance(name string) (*api.DevLXDInstance, string, error) {
+	if f.getInstFunc != nil {
+		return f.getInstFunc(name)
+	}
+	return &api.DevLXDInstance{Name: name}, "", nil
+}
+
+func (f *fakeDevLXDServer) UpdateInstance(name string, inst api.DevLXDInstancePut, ETag string) error {
+	if f.updateInstFunc != nil {
+		return f.updateInstFunc(name, inst, ETag)
+	}
+	return nil
+}
+
+func (f *fakeDevLXDServer) UseTarget(name string) lxdClient.DevLXDServer {
+	c := *f
+	c.target = name
+	return &c
+}
+
+func (f *fakeDevLXDServer) GetState() (*api.DevLXDGet, error) {
+	if f.getStateFunc != nil {
+		return f.getStateFunc()
+	}
+	return &api.DevLXDGet{}, nil
+}
+
+func (f *fakeDevLXDServer) GetStoragePool(pool string) (*api.DevLXDStoragePool, string, error) {
+	if f.getPoolFunc != nil {
+		return f.getPoolFunc(f.target, pool)
+	}
+	return &api.DevLXDStoragePool{Name: pool}, "", nil
+}
+
+func (f *fakeDevLXDServer) CreateStoragePoolVolume(pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+	if f.createVolFunc != nil {
+		return f.createVolFunc(f.target, pool, volume)
+	}
+	return &fakeDevLXDOperation{}, nil
+}
+
+func (f *fakeDevLXDServer) GetStoragePoolVolumes(pool string) ([]api.DevLXDStorageVolume, error) {
+	if f.getVolsFunc != nil {
+		return f.getVolsFunc(pool)
+	}
+	return nil, nil
 }
 
 func (f *fakeDevLXDServer) GetStoragePoolVolume(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
 	if f.getVolFunc != nil {
 		return f.getVolFunc(pool, volType, name)
 	}
-	return nil, "", nil
+	return &api.DevLXDStorageVolume{Name: name, Pool: pool}, "", nil
 }
 
 func (f *fakeDevLXDServer) UpdateStoragePoolVolume(pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
 	if f.updateVolFunc != nil {
-		return f.updateVolFunc(pool, volType, name, volume, ETag)
+		return f.updateVolFunc(f.target, pool, volType, name, volume, ETag)
+	}
+	return &fakeDevLXDOperation{}, nil
+}
+
+func (f *fakeDevLXDServer) GetStoragePoolVolumeSnapshots(pool string, volType string, volName string) ([]api.DevLXDStorageVolumeSnapshot, error) {
+	if f.getSnapshotsFunc != nil {
+		return f.getSnapshotsFunc(pool, volType, volName)
+	}
+	return nil, nil
+}
+
+func (f *fakeDevLXDServer) GetStoragePoolVolumeSnapshot(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+	if f.getSnapshotFunc != nil {
+		return f.getSnapshotFunc(pool, volType, volName, snapshotName)
+	}
+	return nil, "", api.NewStatusError(http.StatusNotFound, "Snapshot not found")
+}
+
+func (f *fakeDevLXDServer) CreateStoragePoolVolumeSnapshot(pool string, volType string, volName string, snapshot api.DevLXDStorageVolumeSnapshotsPost) (lxdClient.DevLXDOperation, error) {
+	if f.createSnapshotFunc != nil {
+		return f.createSnapshotFunc(pool, volType, volName, snapshot)
+	}
+	return &fakeDevLXDOperation{}, nil
+}
+
+func (f *fakeDevLXDServer) DeleteStoragePoolVolumeSnapshot(pool string, volType string, volName string, snapshotName string) (lxdClient.DevLXDOperation, error) {
+	if f.deleteSnapshotFunc != nil {
+		return f.deleteSnapshotFunc(pool, volType, volName, snapshotName)
 	}
 	return &fakeDevLXDOperation{}, nil
 }
 
+// getManagedVolume returns a volume created by the CSI driver.
+func getManagedVolume(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+	return &api.DevLXDStorageVolume{
+		Name:   name,
+		Pool:   pool,
+		Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue},
+	}, "", nil
+}
+
 func TestControllerExpandVolumePreservesConfig(t *testing.T) {
 	// Initialize driver and controller server
 	d := &Driver{
@@ -55,9 +203,10 @@ func TestControllerExpandVolumePreservesConfig(t *testing.T) {
 	// Create our fake LXD client
 	var calledGet, calledUpdate bool
 	initialConfig := map[string]string{
-		"size":             "21474836480", // 20Gi
-		"block.filesystem": "ext4",
-		"other.custom.key": "some-value",
+		"size":                    "21474836480", // 20Gi
+		"block.filesystem":        "ext4",
+		"other.custom.key":        "some-value",
+		VolumeConfigStorageDriver: "ceph",
 	}
 
 	fakeClient := &fakeDevLXDServer{
@@ -73,7 +222,7 @@ func TestControllerExpandVolumePreservesConfig(t *testing.T) {
 				Config:      maps.Clone(initialConfig),
 			}, "test-etag", nil
 		},
-		updateVolFunc: func(pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+		updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
 			calledUpdate = true
 			require.Equal(t, "remote", pool)
 			require.Equal(t, "custom", volType)
@@ -85,6 +234,7 @@ func TestControllerExpandVolumePreservesConfig(t *testing.T) {
 			require.Equal(t, "32212254720", volume.Config["size"]) // 30Gi
 			require.Equal(t, "ext4", volume.Config["block.filesystem"])
 			require.Equal(t, "some-value", volume.Config["other.custom.key"])
+			require.Equal(t, "ceph", volume.Config[VolumeConfigStorageDriver])
 			return &fakeDevLXDOperation{}, nil
 		},
 	}
@@ -120,3 +270,4130 @@ func TestControllerExpandVolumePreservesConfig(t *testing.T) {
 	require.True(t, calledGet, "GetStoragePoolVolume should have been called")
 	require.True(t, calledUpdate, "UpdateStoragePoolVolume should have been called")
 }
+
+func TestControllerSnapshotDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		Name           string
+		DeletionPolicy string
+		expectDeleted  bool
+		expectError    string
+	}{
+		{
+			Name:           "Ensure snapshot is deleted by default",
+			DeletionPolicy: "",
+			expectDeleted:  true,
+		},
+		{
+			Name:           "Ensure snapshot is deleted with Delete policy",
+			DeletionPolicy: SnapshotDeletionPolicyDelete,
+			expectDeleted:  true,
+		},
+		{
+			Name:           "Ensure snapshot is retained with Retain policy",
+			DeletionPolicy: SnapshotDeletionPolicyRetain,
+			expectDeleted:  false,
+		},
+		{
+			Name:           "Ensure invalid deletion policy is rejected",
+			DeletionPolicy: "Recycle",
+			expectError:    `Invalid snapshot deletion policy "Recycle"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var snapshot *api.DevLXDStorageVolumeSnapshot
+			var deleted bool
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+						if snapshot == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Snapshot not found")
+						}
+
+						return snapshot, "", nil
+					},
+					createSnapshotFunc: func(pool string, volType string, volName string, req api.DevLXDStorageVolumeSnapshotsPost) (lxdClient.DevLXDOperation, error) {
+						snapshot = &api.DevLXDStorageVolumeSnapshot{
+							Name:        req.Name,
+							Description: req.Description,
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+					deleteSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (lxdClient.DevLXDOperation, error) {
+						deleted = true
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			createResp, err := controller.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{
+				Name:           "snapshot-1111-2222",
+				SourceVolumeId: "remote/pvc-volume-name",
+				Parameters: map[string]string{
+					ParameterSnapshotDeletionPolicy: test.DeletionPolicy,
+				},
+			})
+
+			if test.expectError != "" {
+				require.ErrorContains(t, err, test.expectError)
+				require.Nil(t, snapshot, "Snapshot should not have been created")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "remote/pvc-volume-name/snapshot-11112222", createResp.Snapshot.SnapshotId)
+
+			_, err = controller.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{
+				SnapshotId: createResp.Snapshot.SnapshotId,
+			})
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectDeleted, deleted)
+		})
+	}
+}
+
+func TestControllerCreateSnapshotDescription(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Parameters        map[string]string
+		expectDescription string
+	}{
+		{
+			Name:              "Ensure snapshot name is used without VolumeSnapshot identity",
+			expectDescription: "Managed by Kubernetes VolumeSnapshot snapshot-11112222",
+		},
+		{
+			Name: "Ensure VolumeSnapshot identity is recorded",
+			Parameters: map[string]string{
+				ParameterVolumeSnapshotName:      "my-snapshot",
+				ParameterVolumeSnapshotNamespace: "default",
+			},
+			expectDescription: "Managed by Kubernetes VolumeSnapshot default/my-snapshot",
+		},
+		{
+			Name: "Ensure VolumeSnapshot name is recorded without namespace",
+			Parameters: map[string]string{
+				ParameterVolumeSnapshotName: "my-snapshot",
+			},
+			expectDescription: "Managed by Kubernetes VolumeSnapshot my-snapshot",
+		},
+		{
+			Name: "Ensure VolumeSnapshot identity is recorded with Retain policy",
+			Parameters: map[string]string{
+				ParameterVolumeSnapshotName:      "my-snapshot",
+				ParameterVolumeSnapshotNamespace: "default",
+				ParameterSnapshotDeletionPolicy:  SnapshotDeletionPolicyRetain,
+			},
+			expectDescription: "Managed by Kubernetes VolumeSnapshot default/my-snapshot" + snapshotRetainDescriptionSuffix,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var description string
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+						return nil, "", api.NewStatusError(http.StatusNotFound, "Snapshot not found")
+					},
+					createSnapshotFunc: func(pool string, volType string, volName string, req api.DevLXDStorageVolumeSnapshotsPost) (lxdClient.DevLXDOperation, error) {
+						description = req.Description
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{
+				Name:           "snapshot-1111-2222",
+				SourceVolumeId: "remote/pvc-volume-name",
+				Parameters:     test.Parameters,
+			})
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectDescription, description)
+		})
+	}
+}
+
+func TestControllerCreateVolumeOfflineMember(t *testing.T) {
+	var probes int
+	var created bool
+
+	d := &Driver{
+		name:        "lxd.csi.canonical.com",
+		nodeID:      "test-node",
+		isClustered: true,
+		topologyKey: AnnotationLXDClusterMember,
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "zfs", Remote: false},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				if target == "member2" {
+					probes++
+					return nil, "", api.NewStatusError(http.StatusBadRequest, "Target cluster member is offline")
+				}
+
+				return &api.DevLXDStoragePool{Name: pool, Driver: "zfs"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				created = true
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	newRequest := func(member string) *csi.CreateVolumeRequest {
+		return &csi.CreateVolumeRequest{
+			Name: "pvc-1111-2222",
+			CapacityRange: &csi.CapacityRange{
+				RequiredBytes: 1024 * 1024 * 1024,
+			},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterStoragePool: "local",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Preferred: []*csi.Topology{
+					{
+						Segments: map[string]string{
+							AnnotationLXDClusterMember: member,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// Ensure volume creation on an offline member fails with Unavailable.
+	for range 3 {
+		_, err := controller.CreateVolume(context.Background(), newRequest("member2"))
+		require.Error(t, err)
+		require.Equal(t, codes.Unavailable, status.Code(err))
+		require.ErrorContains(t, err, `Cluster member "member2" is offline`)
+	}
+
+	require.False(t, created, "Volume should not have been created on an offline member")
+	require.Equal(t, 1, probes, "Offline member health should have been cached")
+
+	// Ensure volume creation on an online member succeeds.
+	resp, err := controller.CreateVolume(context.Background(), newRequest("member1"))
+	require.NoError(t, err)
+	require.True(t, created, "Volume should have been created on an online member")
+	require.Equal(t, "member1:local/pvc-11112222", resp.Volume.VolumeId)
+}
+
+func TestControllerIsMemberOnline(t *testing.T) {
+	tests := []struct {
+		Name         string
+		ProbeErr     error
+		expectOnline bool
+		expectProbes int
+	}{
+		{
+			Name:         "Ensure member is online when the probe succeeds",
+			expectOnline: true,
+			expectProbes: 1,
+		},
+		{
+			Name:         "Ensure member is offline when LXD reports it offline",
+			ProbeErr:     api.NewStatusError(http.StatusServiceUnavailable, "Target cluster member is offline"),
+			expectOnline: false,
+			expectProbes: 1,
+		},
+		{
+			Name:         "Ensure member is offline when LXD fails to forward the request",
+			ProbeErr:     api.NewStatusError(http.StatusInternalServerError, `Get "https://10.0.0.2:8443/1.0/storage-pools/local": dial tcp 10.0.0.2:8443: connect: no route to host`),
+			expectOnline: false,
+			expectProbes: 1,
+		},
+		{
+			Name:         "Ensure member is online when the devLXD socket is unreachable",
+			ProbeErr:     errors.New(`Get "http://unix.socket/1.0/storage-pools/local": dial unix /dev/lxd/sock: connect: connection refused`),
+			expectOnline: true,
+			expectProbes: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var probes int
+
+			client := &fakeDevLXDServer{
+				getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+					probes++
+					if test.ProbeErr != nil {
+						return nil, "", test.ProbeErr
+					}
+
+					return &api.DevLXDStoragePool{Name: pool}, "", nil
+				},
+			}
+
+			controller := NewControllerServer(&Driver{name: "lxd.csi.canonical.com", nodeID: "test-node"})
+
+			for range 2 {
+				require.Equal(t, test.expectOnline, controller.isMemberOnline(client.UseTarget("member2"), "member2", "local"))
+			}
+
+			// Ensure the member health is cached unless the probe failed
+			// for a reason other than the member being offline.
+			require.Equal(t, test.expectProbes, probes)
+		})
+	}
+}
+
+func TestControllerCreateVolumeEchoesConfig(t *testing.T) {
+	var createdVol *api.DevLXDStorageVolume
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "ceph", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				if createdVol == nil {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				}
+
+				return createdVol, "", nil
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				require.Equal(t, "1000000", volume.Config["size"])
+
+				// Simulate LXD rounding the size up to the block size
+				// and applying the pool defaults.
+				createdVol = &api.DevLXDStorageVolume{
+					Name: volume.Name,
+					Config: map[string]string{
+						"size":             "1048576",
+						"block.filesystem": "xfs",
+						"other.key":        "value",
+					},
+				}
+
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-1111-2222",
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 1000000,
+		},
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+			},
+		},
+		Parameters: map[string]string{
+			ParameterStoragePool: "remote",
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, int64(1048576), resp.Volume.CapacityBytes)
+	require.Equal(t, "1048576", resp.Volume.VolumeContext[ParameterVolumeConfigPrefix+"size"])
+	require.Equal(t, "xfs", resp.Volume.VolumeContext[ParameterVolumeConfigPrefix+"block.filesystem"])
+	require.NotContains(t, resp.Volume.VolumeContext, ParameterVolumeConfigPrefix+"other.key")
+}
+
+func TestControllerCreateVolumeWaitForReady(t *testing.T) {
+	volumeReadyPollInterval = time.Millisecond
+
+	var created bool
+	var neverReady bool
+	var polls int
+
+	d := &Driver{
+		name:               "lxd.csi.canonical.com",
+		nodeID:             "test-node",
+		waitForVolumeReady: true,
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "ceph", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				if !created {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				}
+
+				if neverReady {
+					return &api.DevLXDStorageVolume{Name: name, ContentType: "filesystem"}, "", nil
+				}
+
+				polls++
+
+				// Simulate the volume not being visible at first, and
+				// then being reported with an incomplete content type.
+				switch polls {
+				case 1, 2:
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				case 3:
+					return &api.DevLXDStorageVolume{Name: name}, "", nil
+				}
+
+				return &api.DevLXDStorageVolume{
+					Name:        name,
+					ContentType: "block",
+					Config:      map[string]string{"size": "1073741824"},
+				}, "", nil
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				created = true
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	req := &csi.CreateVolumeRequest{
+		Name: "pvc-1111-2222",
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 1024 * 1024 * 1024,
+		},
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				},
+			},
+		},
+		Parameters: map[string]string{
+			ParameterStoragePool: "remote",
+		},
+	}
+
+	resp, err := controller.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 4, polls, "Volume should have been polled until ready")
+	require.Equal(t, "1073741824", resp.Volume.VolumeContext[ParameterVolumeConfigPrefix+"size"])
+
+	// Ensure waiting is bounded by the request context.
+	created = false
+	neverReady = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req.Name = "pvc-3333-4444"
+	req.Parameters = map[string]string{
+		ParameterStoragePool: "remote",
+	}
+
+	_, err = controller.CreateVolume(ctx, req)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestControllerCreateVolumeCustomTopologyKey(t *testing.T) {
+	var createdVol *api.DevLXDStorageVolume
+
+	d := &Driver{
+		name:        "lxd.csi.canonical.com",
+		nodeID:      "test-node",
+		topologyKey: "topology.example.com/lxd-member",
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "zfs", Remote: false},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "zfs"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				if createdVol == nil {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				}
+
+				return createdVol, "", nil
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				createdVol = &api.DevLXDStorageVolume{Name: volume.Name, Config: volume.Config}
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-1111-2222",
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 1024 * 1024 * 1024,
+		},
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+			},
+		},
+		Parameters: map[string]string{
+			ParameterStoragePool: "local",
+		},
+		AccessibilityRequirements: &csi.TopologyRequirement{
+			Preferred: []*csi.Topology{
+				{
+					// Segment with the default key must be ignored.
+					Segments: map[string]string{
+						AnnotationLXDClusterMember: "member1",
+					},
+				},
+				{
+					Segments: map[string]string{
+						"topology.example.com/lxd-member": "member2",
+					},
+				},
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, resp.Volume.AccessibleTopology, 1)
+	require.Equal(t, map[string]string{"topology.example.com/lxd-member": "member2"}, resp.Volume.AccessibleTopology[0].Segments)
+}
+
+func TestControllerCreateSnapshotMaxCount(t *testing.T) {
+	snapshots := map[string]api.DevLXDStorageVolumeSnapshot{}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getSnapshotsFunc: func(pool string, volType string, volName string) ([]api.DevLXDStorageVolumeSnapshot, error) {
+				return slices.Collect(maps.Values(snapshots)), nil
+			},
+			getSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+				snapshot, ok := snapshots[snapshotName]
+				if !ok {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Snapshot not found")
+				}
+
+				return &snapshot, "", nil
+			},
+			createSnapshotFunc: func(pool string, volType string, volName string, req api.DevLXDStorageVolumeSnapshotsPost) (lxdClient.DevLXDOperation, error) {
+				snapshots[req.Name] = api.DevLXDStorageVolumeSnapshot{Name: req.Name}
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	createSnapshot := func(name string, maxCount string) error {
+		_, err := controller.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{
+			Name:           name,
+			SourceVolumeId: "remote/pvc-volume-name",
+			Parameters: map[string]string{
+				ParameterSnapshotsMaxCount: maxCount,
+			},
+		})
+
+		return err
+	}
+
+	// Ensure invalid limit is rejected.
+	err := createSnapshot("snapshot-0", "0")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Ensure snapshots can be created up to the limit.
+	require.NoError(t, createSnapshot("snapshot-1", "2"))
+	require.NoError(t, createSnapshot("snapshot-2", "2"))
+
+	// Ensure creating an existing snapshot is still idempotent.
+	require.NoError(t, createSnapshot("snapshot-2", "2"))
+
+	// Ensure the snapshot exceeding the limit is rejected.
+	err = createSnapshot("snapshot-3", "2")
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Len(t, snapshots, 2)
+}
+
+func TestControllerDeleteVolumeAsync(t *testing.T) {
+	var deleteCalls int
+	op := &fakeAsyncDevLXDOperation{done: make(chan struct{})}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: getManagedVolume,
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				deleteCalls++
+				return op, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+	req := &csi.DeleteVolumeRequest{VolumeId: "remote/pvc-volume-name"}
+
+	// Ensure the request fails while the deletion is still in progress.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := controller.DeleteVolume(ctx, req)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Equal(t, 1, deleteCalls)
+
+	// Ensure the retried request waits on the existing operation
+	// instead of starting a new deletion.
+	close(op.done)
+
+	_, err = controller.DeleteVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleteCalls)
+	require.Nil(t, controller.pendingDeletes.Get(req.VolumeId))
+}
+
+func TestControllerCreateVolumeKubernetesConfig(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Parameters   map[string]string
+		expectConfig map[string]string
+	}{
+		{
+			Name: "Ensure Kubernetes object names are recorded in volume config",
+			Parameters: map[string]string{
+				ParameterPVCName:      "data",
+				ParameterPVCNamespace: "default",
+				ParameterPVName:       "pvc-1111-2222",
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				VolumeConfigPVCName:       "data",
+				VolumeConfigPVCNamespace:  "default",
+				VolumeConfigPVName:        "pvc-1111-2222",
+			},
+		},
+		{
+			Name: "Ensure only provided Kubernetes object names are recorded",
+			Parameters: map[string]string{
+				ParameterPVCName: "data",
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				VolumeConfigPVCName:       "data",
+			},
+		},
+		{
+			Name:       "Ensure no Kubernetes object names are recorded when not provided",
+			Parameters: map[string]string{},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+			},
+		},
+		{
+			Name: "Ensure discard mount option is applied with online discard",
+			Parameters: map[string]string{
+				ParameterDiscard: DiscardOnline,
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				"block.mount_options":     "discard",
+			},
+		},
+		{
+			Name: "Ensure discard mount option is not applied with periodic discard",
+			Parameters: map[string]string{
+				ParameterDiscard: DiscardPeriodic,
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+			},
+		},
+		{
+			Name: "Ensure filesystem type is applied",
+			Parameters: map[string]string{
+				ParameterFSType: "xfs",
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				"block.filesystem":        "xfs",
+			},
+		},
+		{
+			Name: "Ensure prefixed LXD volume configuration is passed through",
+			Parameters: map[string]string{
+				ParameterLXDConfigPrefix + "ceph.rbd.features": "layering",
+				ParameterLXDConfigPrefix + "block.filesystem":  "ext4",
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				"ceph.rbd.features":       "layering",
+				"block.filesystem":        "ext4",
+			},
+		},
+		{
+			Name: "Ensure filesystem type takes precedence over passed through configuration",
+			Parameters: map[string]string{
+				ParameterLXDConfigPrefix + "block.filesystem": "ext4",
+				ParameterFSType: "xfs",
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				"block.filesystem":        "xfs",
+			},
+		},
+		{
+			Name: "Ensure standard CSI filesystem type is applied",
+			Parameters: map[string]string{
+				ParameterCSIFSType: "btrfs",
+			},
+			expectConfig: map[string]string{
+				"size":                    "1048576",
+				VolumeConfigManagedBy:     VolumeManagedByValue,
+				VolumeConfigStorageDriver: "ceph",
+				"block.filesystem":        "btrfs",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var config map[string]string
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						config = volume.Config
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			params := maps.Clone(test.Parameters)
+			params[ParameterStoragePool] = "remote"
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1048576,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: params,
+			})
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectConfig, config)
+		})
+	}
+}
+
+func TestControllerDeleteVolumePoolAlias(t *testing.T) {
+	var deletedPool string
+
+	d := &Driver{
+		name:               "lxd.csi.canonical.com",
+		nodeID:             "test-node",
+		storagePoolAliases: map[string]string{"old": "new"},
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: getManagedVolume,
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				deletedPool = pool
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	// Ensure aliased storage pool is translated to the new name.
+	_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "old/pvc-volume-name"})
+	require.NoError(t, err)
+	require.Equal(t, "new", deletedPool)
+
+	// Ensure non-aliased storage pool is used as is.
+	_, err = controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "other/pvc-volume-name"})
+	require.NoError(t, err)
+	require.Equal(t, "other", deletedPool)
+}
+
+func TestControllerDeleteVolumeManagedMarker(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Volume        *api.DevLXDStorageVolume
+		DryRun        bool
+		expectDeleted bool
+		expectCode    codes.Code
+	}{
+		{
+			Name: "Ensure volume with managed marker is deleted",
+			Volume: &api.DevLXDStorageVolume{
+				Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue},
+			},
+			expectDeleted: true,
+			expectCode:    codes.OK,
+		},
+		{
+			Name: "Ensure volume created before managed marker was introduced is deleted",
+			Volume: &api.DevLXDStorageVolume{
+				Description: "Managed by Kubernetes PVC default/data",
+			},
+			expectDeleted: true,
+			expectCode:    codes.OK,
+		},
+		{
+			Name: "Ensure unmanaged volume is not deleted",
+			Volume: &api.DevLXDStorageVolume{
+				Description: "Database volume",
+				Config:      map[string]string{VolumeConfigManagedBy: "someone-else"},
+			},
+			expectDeleted: false,
+			expectCode:    codes.FailedPrecondition,
+		},
+		{
+			Name: "Ensure managed volume is not deleted in dry-run mode",
+			Volume: &api.DevLXDStorageVolume{
+				Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, "size": "1GiB"},
+			},
+			DryRun:        true,
+			expectDeleted: false,
+			expectCode:    codes.FailedPrecondition,
+		},
+		{
+			Name: "Ensure unmanaged volume is reported in dry-run mode",
+			Volume: &api.DevLXDStorageVolume{
+				Description: "Database volume",
+			},
+			DryRun:        true,
+			expectDeleted: false,
+			expectCode:    codes.FailedPrecondition,
+		},
+		{
+			Name:          "Ensure missing volume is considered deleted",
+			Volume:        nil,
+			expectDeleted: false,
+			expectCode:    codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var deleted bool
+
+			d := &Driver{
+				name:               "lxd.csi.canonical.com",
+				nodeID:             "test-node",
+				deleteVolumeDryRun: test.DryRun,
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if test.Volume == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return test.Volume, "", nil
+					},
+					updateVolFunc: func(target string, pool string, volType string, name string, vol api.DevLXDStorageVolumePut, etag string) (lxdClient.DevLXDOperation, error) {
+						require.FailNow(t, "Volume must not be modified")
+						return nil, nil
+					},
+					deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+						deleted = true
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "remote/pvc-volume-name"})
+			require.Equal(t, test.expectCode, status.Code(err))
+			require.Equal(t, test.expectDeleted, deleted)
+		})
+	}
+}
+
+func TestControllerPublishVolumeMismatchedDevice(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Reconcile         bool
+		expectCode        codes.Code
+		expectUpdatedPool string
+	}{
+		{
+			Name:       "Ensure mismatched device results in an error by default",
+			Reconcile:  false,
+			expectCode: codes.AlreadyExists,
+		},
+		{
+			Name:              "Ensure mismatched device is reconciled when enabled",
+			Reconcile:         true,
+			expectCode:        codes.OK,
+			expectUpdatedPool: "remote",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var updatedDevice map[string]string
+
+			d := &Driver{
+				name:                    "lxd.csi.canonical.com",
+				nodeID:                  "test-node",
+				reconcilePublishDevices: test.Reconcile,
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: getManagedVolume,
+					getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+						return &api.DevLXDInstance{
+							Name: name,
+							Devices: map[string]map[string]string{
+								"pvc-volume-name": {
+									"type":   "disk",
+									"source": "pvc-volume-name",
+									"pool":   "old-pool",
+								},
+							},
+						}, "etag", nil
+					},
+					updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+						updatedDevice = inst.Devices["pvc-volume-name"]
+						return nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+				VolumeId: "remote/pvc-volume-name",
+				NodeId:   "test-node",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+					AccessType: &csi.VolumeCapability_Block{
+						Block: &csi.VolumeCapability_BlockVolume{},
+					},
+				},
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+
+			if test.expectUpdatedPool == "" {
+				require.Nil(t, updatedDevice, "Device should not have been updated")
+			} else {
+				require.Equal(t, test.expectUpdatedPool, updatedDevice["pool"])
+			}
+		})
+	}
+}
+
+func TestControllerCheckSizeRounding(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Threshold     int
+		Requested     int64
+		Allocated     int64
+		expectWarning bool
+	}{
+		{
+			Name:          "Ensure no warning is reported when size is not rounded",
+			Threshold:     10,
+			Requested:     1000,
+			Allocated:     1000,
+			expectWarning: false,
+		},
+		{
+			Name:          "Ensure no warning is reported when rounding is within threshold",
+			Threshold:     10,
+			Requested:     1000,
+			Allocated:     1100,
+			expectWarning: false,
+		},
+		{
+			Name:          "Ensure warning is reported when rounding exceeds threshold",
+			Threshold:     10,
+			Requested:     1000,
+			Allocated:     1101,
+			expectWarning: true,
+		},
+		{
+			Name:          "Ensure no warning is reported when threshold is disabled",
+			Threshold:     0,
+			Requested:     1000,
+			Allocated:     1000000,
+			expectWarning: false,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{sizeRoundingWarningThreshold: test.Threshold}
+			controller := NewControllerServer(d)
+
+			// Use a distinct pool per test to isolate the counter.
+			poolName := fmt.Sprintf("pool-%d", i)
+
+			controller.checkSizeRounding("volume-id", poolName, test.Requested, test.Allocated)
+
+			expect := float64(0)
+			if test.expectWarning {
+				expect = 1
+			}
+
+			require.Equal(t, expect, volumeSizeRoundedTotal.Value(poolName))
+		})
+	}
+}
+
+func TestControllerUnpublishVolumeInstanceBusy(t *testing.T) {
+	tests := []struct {
+		Name       string
+		UpdateErr  error
+		expectCode codes.Code
+	}{
+		{
+			Name:       "Ensure detach succeeds",
+			UpdateErr:  nil,
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Ensure missing attachment is considered detached",
+			UpdateErr:  api.NewStatusError(http.StatusNotFound, "Device not found"),
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Ensure locked instance results in a retryable error",
+			UpdateErr:  api.NewStatusError(http.StatusInternalServerError, `Instance is busy running a "update" operation`),
+			expectCode: codes.Aborted,
+		},
+		{
+			Name:       "Ensure conflicting instance operation results in a retryable error",
+			UpdateErr:  api.NewStatusError(http.StatusConflict, "An operation with this conflict reference is already running"),
+			expectCode: codes.Aborted,
+		},
+		{
+			Name:       "Ensure other errors are not retried",
+			UpdateErr:  api.NewStatusError(http.StatusForbidden, "Not authorized"),
+			expectCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+						return &api.DevLXDInstance{Name: name}, "etag", nil
+					},
+					updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+						return test.UpdateErr
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+				VolumeId: "remote/pvc-volume-name",
+				NodeId:   "node-1",
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+		})
+	}
+}
+
+func TestControllerUnpublishVolumeStoppedInstance(t *testing.T) {
+	tests := []struct {
+		Name       string
+		UpdateErr  error
+		expectCode codes.Code
+	}{
+		{
+			Name:       "Ensure volume is detached from stopped instance",
+			UpdateErr:  nil,
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Ensure detach from stopping virtual machine results in a retryable error",
+			UpdateErr:  api.NewStatusError(http.StatusInternalServerError, `Failed to remove device "pvc-volume-name": Monitor is disconnected`),
+			expectCode: codes.Unavailable,
+		},
+		{
+			Name:       "Ensure detach from stopping container results in a retryable error",
+			UpdateErr:  api.NewStatusError(http.StatusInternalServerError, `Failed to remove device "pvc-volume-name": The container is not running`),
+			expectCode: codes.Unavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var devices map[string]map[string]string
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+						return &api.DevLXDInstance{
+							Name: name,
+							Devices: map[string]map[string]string{
+								"pvc-volume-name": {"type": "disk", "pool": "remote", "source": "pvc-volume-name"},
+							},
+						}, "etag", nil
+					},
+					updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+						devices = inst.Devices
+						return test.UpdateErr
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+				VolumeId: "remote/pvc-volume-name",
+				NodeId:   "node-1",
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+
+			// Device removal is requested regardless of the instance state.
+			device, ok := devices["pvc-volume-name"]
+			require.True(t, ok, "Device removal should have been requested")
+			require.Nil(t, device)
+		})
+	}
+}
+
+func TestControllerExpandVolumeIdempotent(t *testing.T) {
+	var updates int
+
+	vol := &api.DevLXDStorageVolume{
+		Name:   "pvc-volume-name",
+		Type:   "custom",
+		Config: map[string]string{"size": "10737418240"}, // 10Gi
+	}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return vol, "", nil
+			},
+			updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+				updates++
+				vol.Config = volume.Config
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	newRequest := func(sizeBytes int64) *csi.ControllerExpandVolumeRequest {
+		return &csi.ControllerExpandVolumeRequest{
+			VolumeId: "remote/pvc-volume-name",
+			CapacityRange: &csi.CapacityRange{
+				RequiredBytes: sizeBytes,
+			},
+			VolumeCapability: &csi.VolumeCapability{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+			},
+		}
+	}
+
+	// Ensure the same expansion requested twice updates the volume only once.
+	for range 2 {
+		resp, err := controller.ControllerExpandVolume(context.Background(), newRequest(21474836480)) // 20Gi
+		require.NoError(t, err)
+		require.Equal(t, int64(21474836480), resp.CapacityBytes)
+	}
+
+	require.Equal(t, 1, updates)
+
+	// Ensure requesting a smaller size reports the current size without an update.
+	resp, err := controller.ControllerExpandVolume(context.Background(), newRequest(10737418240))
+	require.NoError(t, err)
+	require.Equal(t, int64(21474836480), resp.CapacityBytes)
+	require.Equal(t, 1, updates)
+
+	// Ensure a zero size reports the current size without an update.
+	resp, err = controller.ControllerExpandVolume(context.Background(), newRequest(0))
+	require.NoError(t, err)
+	require.Equal(t, int64(21474836480), resp.CapacityBytes)
+	require.False(t, resp.NodeExpansionRequired)
+	require.Equal(t, 1, updates)
+
+	// Ensure an unset capacity range reports the current size without an update.
+	req := newRequest(0)
+	req.CapacityRange = nil
+	resp, err = controller.ControllerExpandVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, int64(21474836480), resp.CapacityBytes)
+	require.False(t, resp.NodeExpansionRequired)
+	require.Equal(t, 1, updates)
+}
+
+func TestControllerCreateDeleteVolumeSerialized(t *testing.T) {
+	createStarted := make(chan struct{})
+	createRelease := make(chan struct{})
+
+	var created bool
+
+	d := &Driver{
+		name:               "lxd.csi.canonical.com",
+		nodeID:             "test-node",
+		storagePoolAliases: map[string]string{"old": "remote"},
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "ceph", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				if !created {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				}
+
+				return getManagedVolume(pool, volType, name)
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				close(createStarted)
+				<-createRelease
+				created = true
+				return &fakeDevLXDOperation{}, nil
+			},
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	createDone := make(chan error)
+	go func() {
+		_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name: "pvc-1111-2222",
+			CapacityRange: &csi.CapacityRange{
+				RequiredBytes: 1024 * 1024 * 1024,
+			},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterStoragePool: "remote",
+			},
+		})
+
+		createDone <- err
+	}()
+
+	<-createStarted
+
+	// Ensure deletion of the same volume is rejected while it is being
+	// created, regardless of the volume ID used to refer to it.
+	for _, volumeID := range []string{
+		"remote/pvc-11112222",
+		"old/pvc-11112222",
+		"member1:remote/pvc-11112222",
+	} {
+		_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volumeID})
+		require.Equal(t, codes.Aborted, status.Code(err), "Volume %q should have been locked", volumeID)
+	}
+
+	close(createRelease)
+	require.NoError(t, <-createDone)
+
+	// Ensure deletion succeeds once the volume is created.
+	_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "old/pvc-11112222"})
+	require.NoError(t, err)
+}
+
+func TestControllerCreateDeleteSnapshotSerialized(t *testing.T) {
+	createStarted := make(chan struct{})
+	createRelease := make(chan struct{})
+
+	var created bool
+
+	d := &Driver{
+		name:               "lxd.csi.canonical.com",
+		nodeID:             "test-node",
+		storagePoolAliases: map[string]string{"old": "remote"},
+		devLXD: &fakeDevLXDServer{
+			getSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+				if !created {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Snapshot not found")
+				}
+
+				return &api.DevLXDStorageVolumeSnapshot{Name: snapshotName}, "", nil
+			},
+			createSnapshotFunc: func(pool string, volType string, volName string, snapshot api.DevLXDStorageVolumeSnapshotsPost) (lxdClient.DevLXDOperation, error) {
+				close(createStarted)
+				<-createRelease
+				created = true
+				return &fakeDevLXDOperation{}, nil
+			},
+			deleteSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (lxdClient.DevLXDOperation, error) {
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	createDone := make(chan error)
+	go func() {
+		_, err := controller.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{
+			Name:           "snapshot-1111-2222",
+			SourceVolumeId: "remote/pvc-11112222",
+		})
+
+		createDone <- err
+	}()
+
+	<-createStarted
+
+	// Ensure deletion of the same snapshot is rejected while it is being
+	// created, regardless of the snapshot ID used to refer to it.
+	for _, snapshotID := range []string{
+		"remote/pvc-11112222/snapshot-11112222",
+		"old/pvc-11112222/snapshot-11112222",
+		"member1:remote/pvc-11112222/snapshot-11112222",
+	} {
+		_, err := controller.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{SnapshotId: snapshotID})
+		require.Equal(t, codes.Aborted, status.Code(err), "Snapshot %q should have been locked", snapshotID)
+	}
+
+	close(createRelease)
+	require.NoError(t, <-createDone)
+
+	// Ensure deletion succeeds once the snapshot is created.
+	_, err := controller.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{SnapshotId: "old/pvc-11112222/snapshot-11112222"})
+	require.NoError(t, err)
+}
+
+func TestControllerDeleteVolumeOperationID(t *testing.T) {
+	tests := []struct {
+		Name          string
+		operation     *fakeDevLXDOperation
+		expectCode    codes.Code
+		expectMessage string
+	}{
+		{
+			Name: "Operation ID is included in the error",
+			operation: &fakeDevLXDOperation{
+				id:  "6916c8a6-9b7d-4abd-90b3-aedfec7ec7da",
+				err: api.NewStatusError(http.StatusInternalServerError, "Failed to delete volume"),
+			},
+			expectCode:    codes.Internal,
+			expectMessage: `LXD operation "6916c8a6-9b7d-4abd-90b3-aedfec7ec7da" failed`,
+		},
+		{
+			Name: "Error is returned as is without operation ID",
+			operation: &fakeDevLXDOperation{
+				err: api.NewStatusError(http.StatusInternalServerError, "Failed to delete volume"),
+			},
+			expectCode:    codes.Internal,
+			expectMessage: "Failed to delete volume",
+		},
+		{
+			Name: "Error status is preserved",
+			operation: &fakeDevLXDOperation{
+				id:  "6916c8a6-9b7d-4abd-90b3-aedfec7ec7da",
+				err: api.NewStatusError(http.StatusNotFound, "Volume not found"),
+			},
+			expectCode: codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: getManagedVolume,
+					deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+						return test.operation, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "pool/pvc-volume-name"})
+			require.Equal(t, test.expectCode, status.Code(err))
+			if test.expectMessage != "" {
+				require.ErrorContains(t, err, test.expectMessage)
+			}
+		})
+	}
+}
+
+func TestControllerCreateVolumeStorageDriverContentType(t *testing.T) {
+	mountCapability := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+	}
+
+	blockCapability := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+	}
+
+	tests := []struct {
+		Name         string
+		driver       string
+		capability   *csi.VolumeCapability
+		expectCode   codes.Code
+		expectErrMsg string
+	}{
+		{
+			Name:       "Filesystem volume on ceph",
+			driver:     "ceph",
+			capability: mountCapability,
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Block volume on ceph",
+			driver:     "ceph",
+			capability: blockCapability,
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Filesystem volume on cephfs",
+			driver:     "cephfs",
+			capability: mountCapability,
+			expectCode: codes.OK,
+		},
+		{
+			Name:         "Block volume on cephfs",
+			driver:       "cephfs",
+			capability:   blockCapability,
+			expectCode:   codes.InvalidArgument,
+			expectErrMsg: `Storage driver "cephfs" does not support volumes with content type "block"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: test.driver, Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: test.driver}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if createdVol == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return createdVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &api.DevLXDStorageVolume{
+							Name:        volume.Name,
+							ContentType: volume.ContentType,
+							Config:      volume.Config,
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1024 * 1024,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{test.capability},
+				Parameters: map[string]string{
+					ParameterStoragePool: "remote",
+				},
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+			if test.expectErrMsg != "" {
+				require.ErrorContains(t, err, test.expectErrMsg)
+			}
+		})
+	}
+}
+
+func TestControllerValidateVolumeCapabilities(t *testing.T) {
+	newCapability := func(mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+		return &csi.VolumeCapability{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: mode,
+			},
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{},
+			},
+		}
+	}
+
+	tests := []struct {
+		Name            string
+		capability      *csi.VolumeCapability
+		expectConfirmed bool
+	}{
+		{
+			Name:            "Single node writer",
+			capability:      newCapability(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+			expectConfirmed: true,
+		},
+		{
+			Name:            "Single node single writer",
+			capability:      newCapability(csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER),
+			expectConfirmed: true,
+		},
+		{
+			Name:            "Single node multi writer",
+			capability:      newCapability(csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER),
+			expectConfirmed: true,
+		},
+		{
+			Name:            "Multi node multi writer",
+			capability:      newCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER),
+			expectConfirmed: false,
+		},
+		{
+			Name: "Mismatched content type",
+			capability: &csi.VolumeCapability{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				},
+			},
+			expectConfirmed: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return &api.DevLXDStorageVolume{Name: name, ContentType: "filesystem"}, "", nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.ValidateVolumeCapabilities(context.Background(), &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId:           "pool/pvc-volume-name",
+				VolumeCapabilities: []*csi.VolumeCapability{test.capability},
+			})
+
+			require.NoError(t, err)
+			if test.expectConfirmed {
+				require.NotNil(t, resp.Confirmed)
+			} else {
+				require.Nil(t, resp.Confirmed)
+				require.NotEmpty(t, resp.Message)
+			}
+		})
+	}
+}
+
+func TestValidateStorageDriverContentType(t *testing.T) {
+	tests := []struct {
+		driver      api.DevLXDServerStorageDriverInfo
+		contentType string
+		expectError bool
+	}{
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "btrfs"}, contentType: "filesystem"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "btrfs"}, contentType: "block"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "dir"}, contentType: "filesystem"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "dir"}, contentType: "block"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "lvm"}, contentType: "filesystem"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "lvm"}, contentType: "block"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "zfs"}, contentType: "filesystem"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "zfs"}, contentType: "block"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "ceph", Remote: true}, contentType: "filesystem"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "ceph", Remote: true}, contentType: "block"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "cephfs", Remote: true}, contentType: "filesystem"},
+		{driver: api.DevLXDServerStorageDriverInfo{Name: "cephfs", Remote: true}, contentType: "block", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.driver.Name+"/"+test.contentType, func(t *testing.T) {
+			err := validateStorageDriverContentType(&test.driver, test.contentType)
+			if test.expectError {
+				require.ErrorContains(t, err, test.driver.Name)
+				require.ErrorContains(t, err, test.contentType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestControllerCreateVolumeNamePrefix(t *testing.T) {
+	tests := []struct {
+		Name             string
+		driverPrefix     string
+		parameters       map[string]string
+		expectVolumeName string
+		expectCode       codes.Code
+	}{
+		{
+			Name:             "Ensure driver prefix is used",
+			driverPrefix:     "global",
+			parameters:       map[string]string{ParameterStoragePool: "remote"},
+			expectVolumeName: "global-11112222",
+		},
+		{
+			Name:         "Ensure storage class prefix takes precedence over driver prefix",
+			driverPrefix: "global",
+			parameters: map[string]string{
+				ParameterStoragePool:      "remote",
+				ParameterVolumeNamePrefix: "tenant-a",
+			},
+			expectVolumeName: "tenant-a-11112222",
+		},
+		{
+			Name: "Ensure storage class prefix is used without driver prefix",
+			parameters: map[string]string{
+				ParameterStoragePool:      "remote",
+				ParameterVolumeNamePrefix: "tenant-b",
+			},
+			expectVolumeName: "tenant-b-11112222",
+		},
+		{
+			Name:         "Ensure invalid storage class prefix is rejected",
+			driverPrefix: "global",
+			parameters: map[string]string{
+				ParameterStoragePool:      "remote",
+				ParameterVolumeNamePrefix: "invalid_prefix",
+			},
+			expectCode: codes.InvalidArgument,
+		},
+		{
+			Name:         "Ensure reserved storage class prefix is rejected",
+			driverPrefix: "global",
+			parameters: map[string]string{
+				ParameterStoragePool:      "remote",
+				ParameterVolumeNamePrefix: "snapshot",
+			},
+			expectCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:             "lxd.csi.canonical.com",
+				nodeID:           "test-node",
+				volumeNamePrefix: test.driverPrefix,
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if createdVol == nil || createdVol.Name != name {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return createdVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &api.DevLXDStorageVolume{
+							Name:        volume.Name,
+							ContentType: volume.ContentType,
+							Config:      volume.Config,
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1024 * 1024,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: test.parameters,
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+			if test.expectCode != codes.OK {
+				return
+			}
+
+			require.Equal(t, test.expectVolumeName, createdVol.Name)
+			require.Equal(t, "remote/"+test.expectVolumeName, resp.Volume.VolumeId)
+		})
+	}
+}
+
+func TestControllerExpandVolumeTarget(t *testing.T) {
+	tests := []struct {
+		Name         string
+		isClustered  bool
+		volumeID     string
+		expectTarget string
+	}{
+		{
+			Name:         "Ensure target is applied to local volume in cluster",
+			isClustered:  true,
+			volumeID:     "member1:local/pvc-volume-name",
+			expectTarget: "member1",
+		},
+		{
+			Name:         "Ensure target is not applied without cluster",
+			isClustered:  false,
+			volumeID:     "member1:local/pvc-volume-name",
+			expectTarget: "",
+		},
+		{
+			Name:         "Ensure target is not applied to remote volume",
+			isClustered:  true,
+			volumeID:     "remote/pvc-volume-name",
+			expectTarget: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var updateTarget *string
+
+			d := &Driver{
+				name:        "lxd.csi.canonical.com",
+				nodeID:      "test-node",
+				isClustered: test.isClustered,
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return &api.DevLXDStorageVolume{
+							Name:   name,
+							Type:   "custom",
+							Config: map[string]string{"size": "10737418240"}, // 10Gi
+						}, "", nil
+					},
+					updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+						updateTarget = &target
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{
+				VolumeId: test.volumeID,
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 21474836480, // 20Gi
+				},
+				VolumeCapability: &csi.VolumeCapability{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+				},
+			})
+
+			require.NoError(t, err)
+			require.NotNil(t, updateTarget, "UpdateStoragePoolVolume should have been called")
+			require.Equal(t, test.expectTarget, *updateTarget)
+		})
+	}
+}
+
+func TestControllerCreateVolumeDeviceTuning(t *testing.T) {
+	mountCapability := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+	}
+
+	blockCapability := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+	}
+
+	tests := []struct {
+		Name       string
+		Parameters map[string]string
+		capability *csi.VolumeCapability
+		expectCode codes.Code
+	}{
+		{
+			Name:       "Ensure device tuning is accepted for block volumes",
+			Parameters: map[string]string{ParameterIOCache: "writeback", ParameterReadAheadKB: "4096"},
+			capability: blockCapability,
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Ensure IO cache is rejected for filesystem volumes",
+			Parameters: map[string]string{ParameterIOCache: "writeback"},
+			capability: mountCapability,
+			expectCode: codes.InvalidArgument,
+		},
+		{
+			Name:       "Ensure read-ahead is rejected for filesystem volumes",
+			Parameters: map[string]string{ParameterReadAheadKB: "4096"},
+			capability: mountCapability,
+			expectCode: codes.InvalidArgument,
+		},
+		{
+			Name:       "Ensure invalid IO cache mode is rejected",
+			Parameters: map[string]string{ParameterIOCache: "writethrough"},
+			capability: blockCapability,
+			expectCode: codes.InvalidArgument,
+		},
+		{
+			Name:       "Ensure invalid read-ahead is rejected",
+			Parameters: map[string]string{ParameterReadAheadKB: "-1"},
+			capability: blockCapability,
+			expectCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if createdVol == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return createdVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &api.DevLXDStorageVolume{
+							Name:        volume.Name,
+							ContentType: volume.ContentType,
+							Config:      volume.Config,
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			params := maps.Clone(test.Parameters)
+			params[ParameterStoragePool] = "remote"
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1048576,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{test.capability},
+				Parameters:         params,
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+		})
+	}
+}
+
+func TestControllerPublishVolumeDeviceTuning(t *testing.T) {
+	var updatedDevice map[string]string
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: getManagedVolume,
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				updatedDevice = inst.Devices["pvc-volume-name"]
+				return nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	resp, err := controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+		VolumeId: "remote/pvc-volume-name",
+		NodeId:   "test-node",
+		VolumeCapability: &csi.VolumeCapability{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+		},
+		VolumeContext: map[string]string{
+			ParameterIOCache:     "writeback",
+			ParameterReadAheadKB: "4096",
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "writeback", updatedDevice["io.cache"])
+	require.Equal(t, map[string]string{
+		PublishContextDeviceName: "pvc-volume-name",
+		ParameterIOCache:         "writeback",
+		ParameterReadAheadKB:     "4096",
+	}, resp.PublishContext)
+}
+
+func TestControllerPublishVolumeOutcome(t *testing.T) {
+	devices := map[string]map[string]string{}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: getManagedVolume,
+			getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+				return &api.DevLXDInstance{Name: name, Devices: devices}, "etag", nil
+			},
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				maps.Copy(devices, inst.Devices)
+				return nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	req := &csi.ControllerPublishVolumeRequest{
+		VolumeId: "remote/pvc-volume-name",
+		NodeId:   "test-node",
+		VolumeCapability: &csi.VolumeCapability{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+		},
+	}
+
+	attached := volumePublishTotal.Value(publishOutcomeAttached)
+	alreadyAttached := volumePublishTotal.Value(publishOutcomeAlreadyAttached)
+
+	// Ensure the first publish attaches the volume.
+	_, err := controller.ControllerPublishVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, attached+1, volumePublishTotal.Value(publishOutcomeAttached))
+	require.Equal(t, alreadyAttached, volumePublishTotal.Value(publishOutcomeAlreadyAttached))
+
+	// Ensure the repeated publish is reported as already attached.
+	_, err = controller.ControllerPublishVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, attached+1, volumePublishTotal.Value(publishOutcomeAttached))
+	require.Equal(t, alreadyAttached+1, volumePublishTotal.Value(publishOutcomeAlreadyAttached))
+}
+
+func TestControllerPublishVolumeNodeMember(t *testing.T) {
+	tests := []struct {
+		Name       string
+		VolumeID   string
+		NodeLabels map[string]string
+		LabelsErr  error
+		expectCode codes.Code
+	}{
+		{
+			Name:       "Ensure local volume is attached to node on the same member",
+			VolumeID:   "member-1:local/pvc-volume-name",
+			NodeLabels: map[string]string{AnnotationLXDClusterMember: "member-1"},
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Ensure local volume is not attached to node on a different member",
+			VolumeID:   "member-1:local/pvc-volume-name",
+			NodeLabels: map[string]string{AnnotationLXDClusterMember: "member-2"},
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			Name:       "Ensure local volume is attached to node with unknown member",
+			VolumeID:   "member-1:local/pvc-volume-name",
+			NodeLabels: nil,
+			expectCode: codes.OK,
+		},
+		{
+			Name:       "Ensure node retrieval failure results in a retryable error",
+			VolumeID:   "member-1:local/pvc-volume-name",
+			LabelsErr:  errors.New("Failed to retrieve node"),
+			expectCode: codes.Unavailable,
+		},
+		{
+			Name:       "Ensure remote volume is attached regardless of the node member",
+			VolumeID:   "remote/pvc-volume-name",
+			NodeLabels: map[string]string{AnnotationLXDClusterMember: "member-2"},
+			expectCode: codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name:        "lxd.csi.canonical.com",
+				nodeID:      "test-node",
+				isClustered: true,
+				topologyKey: AnnotationLXDClusterMember,
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: getManagedVolume,
+				},
+			}
+
+			controller := NewControllerServer(d)
+			controller.nodeLabels = func(ctx context.Context, name string) (map[string]string, error) {
+				require.Equal(t, "node-1", name)
+				return test.NodeLabels, test.LabelsErr
+			}
+
+			_, err := controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+				VolumeId: test.VolumeID,
+				NodeId:   "node-1",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Block{
+						Block: &csi.VolumeCapability_BlockVolume{},
+					},
+				},
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err), "Unexpected error: %v", err)
+		})
+	}
+}
+
+func TestControllerCreateVolumeColocateWith(t *testing.T) {
+	existingVols := []api.DevLXDStorageVolume{
+		{
+			Name:     "pvc-aaaa",
+			Type:     "custom",
+			Location: "member3",
+			Config: map[string]string{
+				VolumeConfigManagedBy:    VolumeManagedByValue,
+				VolumeConfigPVCName:      "db-data",
+				VolumeConfigPVCNamespace: "default",
+			},
+		},
+		{
+			Name:     "pvc-bbbb",
+			Type:     "custom",
+			Location: "member2",
+			Config: map[string]string{
+				VolumeConfigManagedBy:    VolumeManagedByValue,
+				VolumeConfigPVCName:      "db-data",
+				VolumeConfigPVCNamespace: "other",
+			},
+		},
+	}
+
+	tests := []struct {
+		Name           string
+		poolDriver     string
+		colocateWith   string
+		expectTarget   string
+		expectErrCode  codes.Code
+		expectErrorMsg string
+	}{
+		{
+			Name:         "Ensure volume is co-located with a PVC in the same namespace",
+			poolDriver:   "zfs",
+			colocateWith: "db-data",
+			expectTarget: "member3",
+		},
+		{
+			Name:         "Ensure volume is co-located with a PVC in another namespace",
+			poolDriver:   "zfs",
+			colocateWith: "other/db-data",
+			expectTarget: "member2",
+		},
+		{
+			Name:           "Ensure missing referenced volume is rejected",
+			poolDriver:     "zfs",
+			colocateWith:   "missing",
+			expectErrCode:  codes.NotFound,
+			expectErrorMsg: `Volume of PVC "missing" to co-locate with not found`,
+		},
+		{
+			Name:           "Ensure co-location on remote storage pool is rejected",
+			poolDriver:     "ceph",
+			colocateWith:   "db-data",
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: "supported only for local storage pools",
+		},
+		{
+			Name:           "Ensure invalid reference is rejected",
+			poolDriver:     "zfs",
+			colocateWith:   "default/",
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: `Invalid parameter "colocateWith"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdTarget string
+
+			d := &Driver{
+				name:        "lxd.csi.canonical.com",
+				nodeID:      "test-node",
+				isClustered: true,
+				topologyKey: AnnotationLXDClusterMember,
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "zfs", Remote: false},
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: test.poolDriver}, "", nil
+					},
+					getVolsFunc: func(pool string) ([]api.DevLXDStorageVolume, error) {
+						return existingVols, nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdTarget = target
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1024 * 1024 * 1024,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterStoragePool:  "local",
+					ParameterColocateWith: test.colocateWith,
+					ParameterPVCName:      "db-logs",
+					ParameterPVCNamespace: "default",
+				},
+				AccessibilityRequirements: &csi.TopologyRequirement{
+					Preferred: []*csi.Topology{
+						{
+							Segments: map[string]string{
+								AnnotationLXDClusterMember: "member1",
+							},
+						},
+					},
+				},
+			})
+
+			if test.expectErrorMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, test.expectErrCode, status.Code(err))
+				require.ErrorContains(t, err, test.expectErrorMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectTarget, createdTarget)
+			require.Equal(t, test.expectTarget+":local/pvc-11112222", resp.Volume.VolumeId)
+			require.Len(t, resp.Volume.AccessibleTopology, 1)
+			require.Equal(t, map[string]string{AnnotationLXDClusterMember: test.expectTarget}, resp.Volume.AccessibleTopology[0].Segments)
+		})
+	}
+}
+
+func TestControllerCreateVolumeMinSize(t *testing.T) {
+	tests := []struct {
+		Name           string
+		block          bool
+		requiredBytes  int64
+		limitBytes     int64
+		expectSize     int64
+		expectErrCode  codes.Code
+		expectErrorMsg string
+	}{
+		{
+			Name:          "Ensure small filesystem volume is rounded up to the minimum",
+			requiredBytes: 1024 * 1024,
+			expectSize:    100 * 1024 * 1024,
+		},
+		{
+			Name:          "Ensure small block volume is rounded up to the minimum",
+			block:         true,
+			requiredBytes: 1024 * 1024,
+			expectSize:    1024 * 1024 * 1024,
+		},
+		{
+			Name:          "Ensure volume larger than the minimum is not changed",
+			block:         true,
+			requiredBytes: 2 * 1024 * 1024 * 1024,
+			expectSize:    2 * 1024 * 1024 * 1024,
+		},
+		{
+			Name:           "Ensure minimum exceeding the size limit is rejected",
+			block:          true,
+			requiredBytes:  1024 * 1024,
+			limitBytes:     512 * 1024 * 1024,
+			expectErrCode:  codes.OutOfRange,
+			expectErrorMsg: "Minimum block volume size 1073741824 exceeds the volume size limit 536870912",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if createdVol == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return createdVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &api.DevLXDStorageVolume{Name: volume.Name, Config: volume.Config}
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			capability := &csi.VolumeCapability{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+			}
+
+			if test.block {
+				capability.AccessType = &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				}
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: test.requiredBytes,
+					LimitBytes:    test.limitBytes,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{capability},
+				Parameters: map[string]string{
+					ParameterStoragePool:       "remote",
+					ParameterMinSizeBlock:      "1GiB",
+					ParameterMinSizeFilesystem: "100MiB",
+				},
+			})
+
+			if test.expectErrorMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, test.expectErrCode, status.Code(err))
+				require.ErrorContains(t, err, test.expectErrorMsg)
+				require.Nil(t, createdVol, "Volume should not have been created")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectSize, resp.Volume.CapacityBytes)
+			require.Equal(t, strconv.FormatInt(test.expectSize, 10), createdVol.Config["size"])
+		})
+	}
+}
+
+func TestValidateStorageClassParametersMinSize(t *testing.T) {
+	for _, value := range []string{"", "0", "-1GiB", "large"} {
+		_, err := validateStorageClassParameters(map[string]string{
+			ParameterStoragePool:  "local",
+			ParameterMinSizeBlock: value,
+		})
+
+		require.ErrorContains(t, err, `Invalid parameter "minSize.block"`, "Value %q should be rejected", value)
+	}
+
+	_, err := validateStorageClassParameters(map[string]string{
+		ParameterStoragePool:       "local",
+		ParameterMinSizeFilesystem: "512MiB",
+	})
+
+	require.NoError(t, err)
+}
+
+func TestValidateStorageClassParametersFSType(t *testing.T) {
+	for _, param := range []string{ParameterFSType, ParameterCSIFSType} {
+		for _, value := range []string{"", "ntfs", "EXT4"} {
+			_, err := validateStorageClassParameters(map[string]string{
+				ParameterStoragePool: "local",
+				param:                value,
+			})
+
+			require.ErrorContains(t, err, fmt.Sprintf("Invalid parameter %q", param), "Value %q should be rejected", value)
+		}
+
+		_, err := validateStorageClassParameters(map[string]string{
+			ParameterStoragePool: "local",
+			param:                "ext4",
+		})
+
+		require.NoError(t, err)
+	}
+}
+
+func TestValidateStorageClassParametersDeletionProtection(t *testing.T) {
+	for _, value := range []string{"", "yes", "enabled"} {
+		_, err := validateStorageClassParameters(map[string]string{
+			ParameterStoragePool:        "local",
+			ParameterDeletionProtection: value,
+		})
+
+		require.ErrorContains(t, err, `Invalid parameter "deletionProtection"`, "Value %q should be rejected", value)
+	}
+
+	_, err := validateStorageClassParameters(map[string]string{
+		ParameterStoragePool:        "local",
+		ParameterDeletionProtection: "true",
+	})
+
+	require.NoError(t, err)
+}
+
+func TestValidateStorageClassParametersLXDConfig(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Key         string
+		expectError string
+	}{
+		{
+			Name: "Ensure LXD volume configuration key is accepted",
+			Key:  ParameterLXDConfigPrefix + "zfs.blocksize",
+		},
+		{
+			Name: "Ensure user configuration key is accepted",
+			Key:  ParameterLXDConfigPrefix + "user.team",
+		},
+		{
+			Name:        "Ensure empty key is rejected",
+			Key:         ParameterLXDConfigPrefix,
+			expectError: "LXD volume configuration key is empty",
+		},
+		{
+			Name:        "Ensure size is rejected",
+			Key:         ParameterLXDConfigPrefix + "size",
+			expectError: `LXD volume configuration key "size" is managed by the CSI driver`,
+		},
+		{
+			Name:        "Ensure driver configuration key is rejected",
+			Key:         ParameterLXDConfigPrefix + VolumeConfigManagedBy,
+			expectError: "is managed by the CSI driver",
+		},
+		{
+			Name:        "Ensure PVC label key is rejected",
+			Key:         ParameterLXDConfigPrefix + VolumeConfigLabelPrefix + "team",
+			expectError: "is managed by the CSI driver",
+		},
+		{
+			Name:        "Ensure unknown unprefixed parameter is rejected",
+			Key:         "zfs.blocksize",
+			expectError: `Invalid parameter "zfs.blocksize" in storage class`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := validateStorageClassParameters(map[string]string{
+				ParameterStoragePool: "local",
+				test.Key:             "value",
+			})
+
+			if test.expectError != "" {
+				require.ErrorContains(t, err, test.expectError)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVolumeFSType(t *testing.T) {
+	newVolCap := func(fsType string) []*csi.VolumeCapability {
+		return []*csi.VolumeCapability{
+			{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{FsType: fsType},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		Name         string
+		Parameters   map[string]string
+		VolCaps      []*csi.VolumeCapability
+		expectFSType string
+		expectError  string
+	}{
+		{
+			Name:    "Ensure filesystem type is empty when not requested",
+			VolCaps: newVolCap(""),
+		},
+		{
+			Name:         "Ensure storage class parameter takes precedence",
+			Parameters:   map[string]string{ParameterFSType: "xfs", ParameterCSIFSType: "btrfs"},
+			VolCaps:      newVolCap("ext4"),
+			expectFSType: "xfs",
+		},
+		{
+			Name:         "Ensure standard CSI parameter takes precedence over volume capability",
+			Parameters:   map[string]string{ParameterCSIFSType: "btrfs"},
+			VolCaps:      newVolCap("ext4"),
+			expectFSType: "btrfs",
+		},
+		{
+			Name:         "Ensure filesystem type of volume capability is used",
+			VolCaps:      newVolCap("ext4"),
+			expectFSType: "ext4",
+		},
+		{
+			Name:        "Ensure unsupported filesystem type of volume capability is rejected",
+			VolCaps:     newVolCap("ntfs"),
+			expectError: `Unsupported filesystem type "ntfs"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			fsType, err := volumeFSType(test.Parameters, test.VolCaps)
+			if test.expectError != "" {
+				require.ErrorContains(t, err, test.expectError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectFSType, fsType)
+		})
+	}
+}
+
+func TestApplyDeprecatedParameters(t *testing.T) {
+	oldDeprecatedParameters := deprecatedParameters
+	t.Cleanup(func() { deprecatedParameters = oldDeprecatedParameters })
+
+	deprecatedParameters = map[string]string{
+		"pool":   ParameterStoragePool,
+		"prefix": ParameterVolumeNamePrefix,
+	}
+
+	tests := []struct {
+		Name             string
+		parameters       map[string]string
+		expectParameters map[string]string
+		expectFound      []string
+	}{
+		{
+			Name:             "Ensure current parameters are unchanged",
+			parameters:       map[string]string{ParameterStoragePool: "local"},
+			expectParameters: map[string]string{ParameterStoragePool: "local"},
+		},
+		{
+			Name:             "Ensure deprecated parameters are renamed",
+			parameters:       map[string]string{"pool": "local", "prefix": "db"},
+			expectParameters: map[string]string{ParameterStoragePool: "local", ParameterVolumeNamePrefix: "db"},
+			expectFound:      []string{"pool", "prefix"},
+		},
+		{
+			Name:             "Ensure current parameter takes precedence over deprecated one",
+			parameters:       map[string]string{"pool": "old", ParameterStoragePool: "local"},
+			expectParameters: map[string]string{ParameterStoragePool: "local"},
+			expectFound:      []string{"pool"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			found := applyDeprecatedParameters(test.parameters)
+			require.Equal(t, test.expectFound, found)
+			require.Equal(t, test.expectParameters, test.parameters)
+		})
+	}
+}
+
+func TestControllerCreateVolumeCapacityRange(t *testing.T) {
+	tests := []struct {
+		Name           string
+		capacityRange  *csi.CapacityRange
+		poolSize       string
+		roundTo        int64
+		expectSize     int64
+		expectErrCode  codes.Code
+		expectErrorMsg string
+	}{
+		{
+			Name:           "Ensure missing capacity range is rejected without pool default size",
+			capacityRange:  nil,
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: `Required volume size not provided and storage pool "remote" has no default volume size`,
+		},
+		{
+			Name:           "Ensure zero required size is rejected without pool default size",
+			capacityRange:  &csi.CapacityRange{},
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: `Required volume size not provided and storage pool "remote" has no default volume size`,
+		},
+		{
+			Name:          "Ensure missing capacity range uses pool default size",
+			capacityRange: nil,
+			poolSize:      "1GiB",
+			expectSize:    1024 * 1024 * 1024,
+		},
+		{
+			Name:          "Ensure zero required size uses pool default size within limit",
+			capacityRange: &csi.CapacityRange{LimitBytes: 2048},
+			poolSize:      "1024",
+			expectSize:    1024,
+		},
+		{
+			Name:           "Ensure pool default size exceeding limit is rejected",
+			capacityRange:  &csi.CapacityRange{LimitBytes: 1024},
+			poolSize:       "2048",
+			expectErrCode:  codes.OutOfRange,
+			expectErrorMsg: `Default volume size 2048 of storage pool "remote" exceeds the volume size limit 1024`,
+		},
+		{
+			Name:           "Ensure negative required size is rejected",
+			capacityRange:  &csi.CapacityRange{RequiredBytes: -1},
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: "Required volume size -1 cannot be negative",
+		},
+		{
+			Name:           "Ensure negative required size with negative limit is rejected",
+			capacityRange:  &csi.CapacityRange{RequiredBytes: -1, LimitBytes: -1},
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: "Required volume size -1 cannot be negative",
+		},
+		{
+			Name:           "Ensure negative limit is rejected",
+			capacityRange:  &csi.CapacityRange{RequiredBytes: 1024, LimitBytes: -1},
+			expectErrCode:  codes.InvalidArgument,
+			expectErrorMsg: "Volume size limit -1 cannot be negative",
+		},
+		{
+			Name:           "Ensure limit lower than required size is rejected",
+			capacityRange:  &csi.CapacityRange{RequiredBytes: 2048, LimitBytes: 1024},
+			expectErrCode:  codes.OutOfRange,
+			expectErrorMsg: "Required volume size 2048 exceeds the volume size limit 1024",
+		},
+		{
+			Name:          "Ensure required size without limit is accepted",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 1024},
+			expectSize:    1024,
+		},
+		{
+			Name:          "Ensure limit equal to required size is accepted",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 1024, LimitBytes: 1024},
+			expectSize:    1024,
+		},
+		{
+			Name:          "Ensure limit greater than required size is accepted",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 1024, LimitBytes: 2048},
+			expectSize:    1024,
+		},
+		{
+			Name:          "Ensure rounded size within limit is reported",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 1000, LimitBytes: 2048},
+			roundTo:       512,
+			expectSize:    1024,
+		},
+		{
+			Name:          "Ensure rounded size without limit is reported",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 1000},
+			roundTo:       4096,
+			expectSize:    4096,
+		},
+		{
+			Name:           "Ensure rounded size exceeding limit is rejected",
+			capacityRange:  &csi.CapacityRange{RequiredBytes: 1000, LimitBytes: 2048},
+			roundTo:        4096,
+			expectErrCode:  codes.OutOfRange,
+			expectErrorMsg: "Allocated size 4096 of volume \"pvc-11112222\" exceeds the volume size limit 2048",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if createdVol == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return createdVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &api.DevLXDStorageVolume{Name: volume.Name, Config: volume.Config}
+
+						// Mimic LXD applying the default volume size of the pool.
+						if createdVol.Config["size"] == "" && test.poolSize != "" {
+							createdVol.Config["size"] = test.poolSize
+						}
+
+						// Mimic LXD rounding the size up to the allocation granularity.
+						if test.roundTo > 0 {
+							size, err := strconv.ParseInt(createdVol.Config["size"], 10, 64)
+							require.NoError(t, err)
+							createdVol.Config["size"] = strconv.FormatInt((size+test.roundTo-1)/test.roundTo*test.roundTo, 10)
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+					deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+						createdVol = nil
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:          "pvc-1111-2222",
+				CapacityRange: test.capacityRange,
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterStoragePool: "remote",
+				},
+			})
+
+			if test.expectErrorMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, test.expectErrCode, status.Code(err))
+				require.ErrorContains(t, err, test.expectErrorMsg)
+				require.Nil(t, createdVol, "Volume should not have been created or should have been deleted")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectSize, resp.Volume.CapacityBytes)
+		})
+	}
+}
+
+func TestControllerCreateVolumeDescriptionTemplate(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Template          string
+		expectDescription string
+		expectErrorMsg    string
+	}{
+		{
+			Name:              "Ensure default description is used without a template",
+			Template:          "",
+			expectDescription: "Managed by Kubernetes PVC default/data",
+		},
+		{
+			Name:              "Ensure description is rendered from PVC values",
+			Template:          "PVC {{ .PVCNamespace }}/{{ .PVCName }} ({{ .PVName }})",
+			expectDescription: "PVC default/data (pvc-1111-2222)",
+		},
+		{
+			Name:              "Ensure description is rendered from LXD values",
+			Template:          "Volume {{ .VolumeName }} in pool {{ .StoragePool }}",
+			expectDescription: "Volume pvc-11112222 in pool remote",
+		},
+		{
+			Name:              "Ensure template functions can be used",
+			Template:          `{{ printf "%s-%s" .PVCNamespace .PVCName }}{{ if .PVName }} bound{{ end }}`,
+			expectDescription: "default-data bound",
+		},
+		{
+			Name:           "Ensure template with unknown value is rejected",
+			Template:       "PVC {{ .StorageClass }}",
+			expectErrorMsg: "can't evaluate field StorageClass",
+		},
+		{
+			Name:           "Ensure template with invalid syntax is rejected",
+			Template:       "PVC {{ .PVCName",
+			expectErrorMsg: "Failed to parse description template",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolumesPost
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &volume
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+			controller.pvcMetadata = func(ctx context.Context, namespace string, name string) (map[string]string, map[string]string, error) {
+				return nil, nil, nil
+			}
+
+			parameters := map[string]string{
+				ParameterStoragePool:  "remote",
+				ParameterPVCName:      "data",
+				ParameterPVCNamespace: "default",
+				ParameterPVName:       "pvc-1111-2222",
+			}
+
+			if test.Template != "" {
+				parameters[ParameterDescriptionTemplate] = test.Template
+			}
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:          "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{RequiredBytes: 1024 * 1024},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: parameters,
+			})
+
+			if test.expectErrorMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+				require.ErrorContains(t, err, test.expectErrorMsg)
+				require.Nil(t, createdVol, "Volume should not have been created")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectDescription, createdVol.Description)
+		})
+	}
+}
+
+func TestControllerCreateVolumeDeprecatedParameters(t *testing.T) {
+	oldDeprecatedParameters := deprecatedParameters
+	t.Cleanup(func() { deprecatedParameters = oldDeprecatedParameters })
+
+	deprecatedParameters = map[string]string{
+		"pool": ParameterStoragePool,
+	}
+
+	var createdPool string
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "ceph", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				createdPool = pool
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-1111-2222",
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 1024 * 1024 * 1024,
+		},
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+			},
+		},
+		Parameters: map[string]string{
+			"pool": "remote",
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "remote", createdPool)
+	require.Equal(t, "remote/pvc-11112222", resp.Volume.VolumeId)
+	require.NotContains(t, resp.Volume.VolumeContext, "pool")
+}
+
+func TestControllerCreateVolumeAllocatedSize(t *testing.T) {
+	tests := []struct {
+		Name          string
+		allocatedSize string
+		expectSize    int64
+		expectRounded float64
+	}{
+		{
+			Name:          "Ensure requested size is reported when allocated size matches",
+			allocatedSize: "1073741824",
+			expectSize:    1024 * 1024 * 1024,
+		},
+		{
+			Name:          "Ensure allocated size in bytes is reported when larger than requested",
+			allocatedSize: "1610612736",
+			expectSize:    1536 * 1024 * 1024,
+			expectRounded: 1,
+		},
+		{
+			Name:          "Ensure allocated size with units is reported when larger than requested",
+			allocatedSize: "2GiB",
+			expectSize:    2 * 1024 * 1024 * 1024,
+			expectRounded: 1,
+		},
+		{
+			Name:          "Ensure requested size is reported when allocated size cannot be parsed",
+			allocatedSize: "unknown",
+			expectSize:    1024 * 1024 * 1024,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			// Use a distinct pool per test to isolate the counter.
+			poolName := fmt.Sprintf("allocated-%d", i)
+
+			d := &Driver{
+				name:                         "lxd.csi.canonical.com",
+				nodeID:                       "test-node",
+				sizeRoundingWarningThreshold: 10,
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if createdVol == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return createdVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						// Simulate LXD allocating a volume larger than requested.
+						createdVol = &api.DevLXDStorageVolume{
+							Name:   volume.Name,
+							Config: map[string]string{"size": test.allocatedSize},
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1024 * 1024 * 1024,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterStoragePool: poolName,
+				},
+			})
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectSize, resp.Volume.CapacityBytes)
+			require.Equal(t, test.expectRounded, volumeSizeRoundedTotal.Value(poolName))
+		})
+	}
+}
+
+func TestControllerDeleteVolumeProtection(t *testing.T) {
+	tests := []struct {
+		Name          string
+		protected     bool
+		secrets       map[string]string
+		expectDeleted bool
+		expectCode    codes.Code
+	}{
+		{
+			Name:          "Ensure unprotected volume is deleted without confirmation",
+			expectDeleted: true,
+			expectCode:    codes.OK,
+		},
+		{
+			Name:          "Ensure unprotected volume is deleted with confirmation",
+			secrets:       map[string]string{SecretDeletionConfirmation: "pvc-volume-name"},
+			expectDeleted: true,
+			expectCode:    codes.OK,
+		},
+		{
+			Name:          "Ensure protected volume is not deleted without confirmation",
+			protected:     true,
+			expectDeleted: false,
+			expectCode:    codes.FailedPrecondition,
+		},
+		{
+			Name:          "Ensure protected volume is not deleted with mismatched confirmation",
+			protected:     true,
+			secrets:       map[string]string{SecretDeletionConfirmation: "pvc-other-volume"},
+			expectDeleted: false,
+			expectCode:    codes.FailedPrecondition,
+		},
+		{
+			Name:          "Ensure protected volume is deleted with confirmation",
+			protected:     true,
+			secrets:       map[string]string{SecretDeletionConfirmation: "pvc-volume-name"},
+			expectDeleted: true,
+			expectCode:    codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var deleted bool
+
+			vol := &api.DevLXDStorageVolume{
+				Name:   "pvc-volume-name",
+				Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue},
+			}
+
+			if test.protected {
+				vol.Config[VolumeConfigDeletionProtection] = "true"
+			}
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return vol, "", nil
+					},
+					deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+						deleted = true
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{
+				VolumeId: "remote/pvc-volume-name",
+				Secrets:  test.secrets,
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+			require.Equal(t, test.expectDeleted, deleted)
+
+			if test.expectCode == codes.FailedPrecondition {
+				require.ErrorContains(t, err, `Volume "pvc-volume-name" is protected against deletion`)
+			}
+		})
+	}
+}
+
+func TestControllerCreateVolumeDeletionProtection(t *testing.T) {
+	tests := []struct {
+		Name               string
+		DeletionProtection string
+		annotations        map[string]string
+		annotationsErr     error
+		expectFetch        bool
+		expectProtection   string
+		expectCode         codes.Code
+	}{
+		{
+			Name:               "Ensure protection annotation is mirrored into volume config",
+			DeletionProtection: "true",
+			annotations:        map[string]string{AnnotationDeletionProtection: "true"},
+			expectFetch:        true,
+			expectProtection:   "true",
+			expectCode:         codes.OK,
+		},
+		{
+			Name:               "Ensure volume is not protected without annotation",
+			DeletionProtection: "true",
+			annotations:        map[string]string{"other": "true"},
+			expectFetch:        true,
+			expectCode:         codes.OK,
+		},
+		{
+			Name:        "Ensure PVC is not retrieved when deletion protection is not enabled",
+			annotations: map[string]string{AnnotationDeletionProtection: "true"},
+			expectCode:  codes.OK,
+		},
+		{
+			Name:               "Ensure PVC is not retrieved when deletion protection is disabled",
+			DeletionProtection: "false",
+			annotations:        map[string]string{AnnotationDeletionProtection: "true"},
+			expectCode:         codes.OK,
+		},
+		{
+			Name:               "Ensure failure to retrieve PVC annotations is reported",
+			DeletionProtection: "true",
+			annotationsErr:     errors.New("API server unavailable"),
+			expectFetch:        true,
+			expectCode:         codes.Unavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &api.DevLXDStorageVolume{Name: volume.Name, Config: volume.Config}
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			var fetched bool
+
+			controller := NewControllerServer(d)
+			controller.pvcMetadata = func(ctx context.Context, namespace string, name string) (map[string]string, map[string]string, error) {
+				require.Equal(t, "default", namespace)
+				require.Equal(t, "data", name)
+				fetched = true
+				return test.annotations, nil, test.annotationsErr
+			}
+
+			parameters := map[string]string{
+				ParameterStoragePool:  "remote",
+				ParameterPVCName:      "data",
+				ParameterPVCNamespace: "default",
+			}
+
+			if test.DeletionProtection != "" {
+				parameters[ParameterDeletionProtection] = test.DeletionProtection
+			}
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 1024 * 1024 * 1024,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: parameters,
+			})
+
+			require.Equal(t, test.expectCode, status.Code(err))
+			require.Equal(t, test.expectFetch, fetched, "Unexpected retrieval of the PVC")
+			if test.expectCode != codes.OK {
+				require.Nil(t, createdVol, "Volume should not have been created")
+				return
+			}
+
+			require.Equal(t, test.expectProtection, createdVol.Config[VolumeConfigDeletionProtection])
+		})
+	}
+}
+
+func TestControllerAttachedVolumesMetric(t *testing.T) {
+	nodeID := "attached-volumes-node"
+
+	// Instance devices, including a device not managed by the driver.
+	devices := map[string]map[string]string{
+		"root": {"type": "disk", "pool": "default", "path": "/"},
+	}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return &api.DevLXDStorageVolume{Name: name}, "", nil
+			},
+			getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+				return &api.DevLXDInstance{Name: name, Devices: maps.Clone(devices)}, "", nil
+			},
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				for devName, dev := range inst.Devices {
+					if dev == nil {
+						delete(devices, devName)
+					} else {
+						devices[devName] = dev
+					}
+				}
+
+				return nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	capability := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+	}
+
+	publish := func(volName string) {
+		_, err := controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+			VolumeId:         "remote/" + volName,
+			NodeId:           nodeID,
+			VolumeCapability: capability,
+		})
+		require.NoError(t, err)
+	}
+
+	unpublish := func(volName string) {
+		_, err := controller.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: "remote/" + volName,
+			NodeId:   nodeID,
+		})
+		require.NoError(t, err)
+	}
+
+	// Ensure the gauge increments when volumes are attached.
+	publish("pvc-1")
+	require.Equal(t, float64(1), attachedVolumes.Value(nodeID))
+
+	publish("pvc-2")
+	require.Equal(t, float64(2), attachedVolumes.Value(nodeID))
+
+	// Ensure the gauge is unchanged when an attached volume is published again.
+	publish("pvc-1")
+	require.Equal(t, float64(2), attachedVolumes.Value(nodeID))
+
+	// Ensure the gauge decrements when volumes are detached.
+	unpublish("pvc-1")
+	require.Equal(t, float64(1), attachedVolumes.Value(nodeID))
+
+	// Ensure the gauge is unchanged when a detached volume is unpublished again.
+	unpublish("pvc-1")
+	require.Equal(t, float64(1), attachedVolumes.Value(nodeID))
+
+	unpublish("pvc-2")
+	require.Equal(t, float64(0), attachedVolumes.Value(nodeID))
+}
+
+func TestControllerCreateVolumeProvisionMetric(t *testing.T) {
+	// Use a unique pool name, as metrics are shared between tests.
+	poolName := "provision-metric"
+
+	var createErr error
+	var createdVol *api.DevLXDStorageVolume
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "ceph", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				if createdVol == nil || createdVol.Name != name {
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				}
+
+				return createdVol, "", nil
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				if createErr != nil {
+					return nil, createErr
+				}
+
+				// Simulate a slow storage driver.
+				time.Sleep(10 * time.Millisecond)
+				createdVol = &api.DevLXDStorageVolume{Name: volume.Name, Config: volume.Config}
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	createVolume := func(name string) error {
+		_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:          name,
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterStoragePool: poolName,
+			},
+		})
+
+		return err
+	}
+
+	// Ensure the provisioning duration is recorded for a new volume.
+	require.NoError(t, createVolume("pvc-1111-2222"))
+	require.Equal(t, float64(1), volumeProvisionTotal.Value(poolName, "ceph"))
+	require.GreaterOrEqual(t, volumeProvisionSecondsTotal.Value(poolName, "ceph"), 0.01)
+
+	// Ensure failed provisioning is not recorded.
+	createErr = api.NewStatusError(http.StatusForbidden, "Not authorized")
+	require.Error(t, createVolume("pvc-3333-4444"))
+	require.Equal(t, float64(1), volumeProvisionTotal.Value(poolName, "ceph"))
+}
+
+func TestControllerCreateVolumeRestoreValidation(t *testing.T) {
+	tests := []struct {
+		Name               string
+		restoredType       string
+		restoredSize       string
+		limitBytes         int64
+		expectErrorMsg     string
+		expectCode         codes.Code
+		expectVolumeExists bool
+	}{
+		{
+			Name:               "Ensure matching restored volume is accepted",
+			restoredType:       "filesystem",
+			restoredSize:       "2097152",
+			expectVolumeExists: true,
+		},
+		{
+			Name:           "Ensure restored volume with mismatched content type is rejected",
+			restoredType:   "block",
+			restoredSize:   "2097152",
+			expectErrorMsg: `Content type "block" does not match the requested content type "filesystem"`,
+			expectCode:     codes.Internal,
+		},
+		{
+			Name:           "Ensure restored volume smaller than requested is rejected",
+			restoredType:   "filesystem",
+			restoredSize:   "1048576",
+			expectErrorMsg: "Size 1048576 is smaller than the requested size 2097152",
+			expectCode:     codes.Internal,
+		},
+		{
+			Name:           "Ensure restored volume exceeding the size limit is rejected",
+			restoredType:   "filesystem",
+			restoredSize:   "4194304",
+			limitBytes:     3145728,
+			expectErrorMsg: "Size 4194304 exceeds the volume size limit 3145728",
+			expectCode:     codes.OutOfRange,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var restoredVol *api.DevLXDStorageVolume
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+						return &api.DevLXDStorageVolumeSnapshot{
+							Name:        snapshotName,
+							ContentType: "filesystem",
+							Config:      map[string]string{"size": "1048576"},
+						}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						if restoredVol == nil {
+							return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+						}
+
+						return restoredVol, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						restoredVol = &api.DevLXDStorageVolume{
+							Name:        volume.Name,
+							ContentType: test.restoredType,
+							Config:      map[string]string{"size": test.restoredSize},
+						}
+
+						return &fakeDevLXDOperation{}, nil
+					},
+					deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+						restoredVol = nil
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: 2097152,
+					LimitBytes:    test.limitBytes,
+				},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				VolumeContentSource: &csi.VolumeContentSource{
+					Type: &csi.VolumeContentSource_Snapshot{
+						Snapshot: &csi.VolumeContentSource_SnapshotSource{
+							SnapshotId: "remote/pvc-source/snapshot-1",
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterStoragePool: "remote",
+				},
+			})
+
+			if test.expectErrorMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, test.expectCode, status.Code(err))
+				require.ErrorContains(t, err, test.expectErrorMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.expectVolumeExists, restoredVol != nil, "Mismatched restored volume should have been deleted")
+		})
+	}
+}
+
+func TestControllerCreateVolumeRestoreRetry(t *testing.T) {
+	tests := []struct {
+		Name           string
+		existingConfig map[string]string
+		expectErrCode  codes.Code
+	}{
+		{
+			Name: "Ensure volume restored from the same snapshot is accepted",
+			existingConfig: map[string]string{
+				"size":                   "2097152",
+				VolumeConfigManagedBy:    VolumeManagedByValue,
+				VolumeConfigRestoredFrom: "remote/pvc-source/snapshot-1",
+			},
+			expectErrCode: codes.OK,
+		},
+		{
+			Name: "Ensure volume restored from a different snapshot is rejected",
+			existingConfig: map[string]string{
+				"size":                   "2097152",
+				VolumeConfigManagedBy:    VolumeManagedByValue,
+				VolumeConfigRestoredFrom: "remote/pvc-source/snapshot-2",
+			},
+			expectErrCode: codes.AlreadyExists,
+		},
+		{
+			Name: "Ensure volume not restored from a snapshot is rejected",
+			existingConfig: map[string]string{
+				"size":                "2097152",
+				VolumeConfigManagedBy: VolumeManagedByValue,
+			},
+			expectErrCode: codes.AlreadyExists,
+		},
+		{
+			Name: "Ensure unmanaged volume is rejected",
+			existingConfig: map[string]string{
+				"size":                   "2097152",
+				VolumeConfigRestoredFrom: "remote/pvc-source/snapshot-1",
+			},
+			expectErrCode: codes.AlreadyExists,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var created *api.DevLXDStorageVolumesPost
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getSnapshotFunc: func(pool string, volType string, volName string, snapshotName string) (*api.DevLXDStorageVolumeSnapshot, string, error) {
+						return &api.DevLXDStorageVolumeSnapshot{
+							Name:        snapshotName,
+							ContentType: "filesystem",
+							Config:      map[string]string{"size": "1048576"},
+						}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return &api.DevLXDStorageVolume{
+							Name:        name,
+							ContentType: "filesystem",
+							Config:      test.existingConfig,
+						}, "", nil
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						created = &volume
+						return nil, api.NewStatusError(http.StatusConflict, "Volume by that name already exists")
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:          "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{RequiredBytes: 2097152},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				VolumeContentSource: &csi.VolumeContentSource{
+					Type: &csi.VolumeContentSource_Snapshot{
+						Snapshot: &csi.VolumeContentSource_SnapshotSource{
+							SnapshotId: "remote/pvc-source/snapshot-1",
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterStoragePool: "remote",
+				},
+			})
+
+			// Ensure the snapshot is recorded on the restored volume.
+			require.NotNil(t, created, "Volume should have been restored")
+			require.Equal(t, "remote/pvc-source/snapshot-1", created.Config[VolumeConfigRestoredFrom])
+
+			require.Equal(t, test.expectErrCode, status.Code(err), "Unexpected error: %v", err)
+			if test.expectErrCode == codes.OK {
+				require.Equal(t, "remote/pvc-11112222", resp.Volume.VolumeId)
+			}
+		})
+	}
+}
+
+func TestControllerCreateVolumePVCLabels(t *testing.T) {
+	tests := []struct {
+		Name           string
+		LabelKeys      string
+		Labels         map[string]string
+		expectLabels   map[string]string
+		expectErrorMsg string
+	}{
+		{
+			Name:         "Ensure labels are not copied without allowlist",
+			LabelKeys:    "",
+			Labels:       map[string]string{"team": "storage"},
+			expectLabels: map[string]string{},
+		},
+		{
+			Name:      "Ensure only allowlisted labels are copied",
+			LabelKeys: "team, app.kubernetes.io/name,missing",
+			Labels: map[string]string{
+				"team":                   "storage",
+				"app.kubernetes.io/name": "postgres",
+				"cost-center":            "1234",
+			},
+			expectLabels: map[string]string{
+				"user.label.team":                   "storage",
+				"user.label.app.kubernetes.io/name": "postgres",
+			},
+		},
+		{
+			Name:           "Ensure invalid label key is rejected",
+			LabelKeys:      "team,-invalid",
+			expectErrorMsg: `Invalid label key "-invalid"`,
+		},
+		{
+			Name:           "Ensure invalid label value is rejected",
+			LabelKeys:      "team",
+			Labels:         map[string]string{"team": "storage team"},
+			expectErrorMsg: `Invalid value "storage team" of PVC label "team"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var createdVol *api.DevLXDStorageVolumesPost
+
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{
+							DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+								SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+									{Name: "ceph", Remote: true},
+								},
+							},
+						}, nil
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+					},
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+					},
+					createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+						createdVol = &volume
+						return &fakeDevLXDOperation{}, nil
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+			controller.pvcMetadata = func(ctx context.Context, namespace string, name string) (map[string]string, map[string]string, error) {
+				require.Equal(t, "default", namespace)
+				require.Equal(t, "data", name)
+				return nil, test.Labels, nil
+			}
+
+			parameters := map[string]string{
+				ParameterStoragePool:  "remote",
+				ParameterPVCName:      "data",
+				ParameterPVCNamespace: "default",
+			}
+
+			if test.LabelKeys != "" {
+				parameters[ParameterPVCLabels] = test.LabelKeys
+			}
+
+			_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:          "pvc-1111-2222",
+				CapacityRange: &csi.CapacityRange{RequiredBytes: 1024 * 1024},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+					},
+				},
+				Parameters: parameters,
+			})
+
+			if test.expectErrorMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+				require.ErrorContains(t, err, test.expectErrorMsg)
+				require.Nil(t, createdVol, "Volume should not have been created")
+				return
+			}
+
+			require.NoError(t, err)
+
+			labels := make(map[string]string)
+			for key, value := range createdVol.Config {
+				if strings.HasPrefix(key, VolumeConfigLabelPrefix) {
+					labels[key] = value
+				}
+			}
+
+			require.Equal(t, test.expectLabels, labels)
+		})
+	}
+}
+
+func TestControllerPublishVolumeUsesVolumeIDPool(t *testing.T) {
+	var device map[string]string
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				require.Equal(t, "pool-a", pool, "Volume should be retrieved from the pool in the volume ID")
+				return getManagedVolume(pool, volType, name)
+			},
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				device = inst.Devices["vol-1"]
+				return nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	// The volume context refers to a different pool, for example, because
+	// the storage class was changed after the volume was provisioned.
+	_, err := controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+		VolumeId: "pool-a/vol-1",
+		NodeId:   "node-1",
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+		},
+		VolumeContext: map[string]string{
+			ParameterStoragePool: "pool-b",
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "pool-a", device["pool"])
+	require.Equal(t, "vol-1", device["source"])
+}
+
+func TestControllerMaintenanceMode(t *testing.T) {
+	var created bool
+	var deleted bool
+	var attached bool
+	var detached bool
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: getManagedVolume,
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				created = true
+				return &fakeDevLXDOperation{}, nil
+			},
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				deleted = true
+				return &fakeDevLXDOperation{}, nil
+			},
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				if inst.Devices["vol-1"] == nil {
+					detached = true
+				} else {
+					attached = true
+				}
+
+				return nil
+			},
+		},
+	}
+
+	d.SetMaintenanceMode(true)
+	controller := NewControllerServer(d)
+
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+	}
+
+	// Ensure provisioning is blocked with a retryable error.
+	_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name:               "pvc-1111-2222",
+		CapacityRange:      &csi.CapacityRange{RequiredBytes: 1024},
+		VolumeCapabilities: []*csi.VolumeCapability{volCap},
+		Parameters:         map[string]string{ParameterStoragePool: "remote"},
+	})
+
+	require.Equal(t, codes.Unavailable, status.Code(err), "Unexpected error: %v", err)
+	require.False(t, created, "Volume should not have been created")
+
+	_, err = controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "remote/vol-1"})
+	require.Equal(t, codes.Unavailable, status.Code(err), "Unexpected error: %v", err)
+	require.False(t, deleted, "Volume should not have been deleted")
+
+	// Ensure attach and detach proceed.
+	_, err = controller.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+		VolumeId:         "remote/vol-1",
+		NodeId:           "node-1",
+		VolumeCapability: volCap,
+	})
+
+	require.NoError(t, err)
+	require.True(t, attached, "Volume should have been attached")
+
+	_, err = controller.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "remote/vol-1",
+		NodeId:   "node-1",
+	})
+
+	require.NoError(t, err)
+	require.True(t, detached, "Volume should have been detached")
+
+	// Ensure provisioning resumes once maintenance mode is disabled.
+	d.SetMaintenanceMode(false)
+
+	_, err = controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "remote/vol-1"})
+	require.NoError(t, err)
+	require.True(t, deleted, "Volume should have been deleted")
+}
+
+func TestControllerMissingStoragePool(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+	}
+
+	operations := map[string]func(c *controllerServer) error{
+		"DeleteVolume": func(c *controllerServer) error {
+			_, err := c.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "remote/vol-1"})
+			return err
+		},
+		"ControllerPublishVolume": func(c *controllerServer) error {
+			_, err := c.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "remote/vol-1", NodeId: "node-1", VolumeCapability: volCap})
+			return err
+		},
+		"ControllerUnpublishVolume": func(c *controllerServer) error {
+			_, err := c.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{VolumeId: "remote/vol-1", NodeId: "node-1"})
+			return err
+		},
+		"ControllerExpandVolume": func(c *controllerServer) error {
+			_, err := c.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{VolumeId: "remote/vol-1", CapacityRange: &csi.CapacityRange{RequiredBytes: 1024}, VolumeCapability: volCap})
+			return err
+		},
+	}
+
+	tests := []struct {
+		Name        string
+		Operation   string
+		PoolMissing bool
+		expectCode  codes.Code
+	}{
+		{
+			Name:        "Ensure deleting volume in missing pool succeeds",
+			Operation:   "DeleteVolume",
+			PoolMissing: true,
+			expectCode:  codes.OK,
+		},
+		{
+			Name:       "Ensure deleting missing volume succeeds",
+			Operation:  "DeleteVolume",
+			expectCode: codes.OK,
+		},
+		{
+			Name:        "Ensure publishing volume in missing pool fails with failed precondition",
+			Operation:   "ControllerPublishVolume",
+			PoolMissing: true,
+			expectCode:  codes.FailedPrecondition,
+		},
+		{
+			Name:       "Ensure publishing missing volume fails with not found",
+			Operation:  "ControllerPublishVolume",
+			expectCode: codes.NotFound,
+		},
+		{
+			Name:        "Ensure unpublishing volume in missing pool succeeds",
+			Operation:   "ControllerUnpublishVolume",
+			PoolMissing: true,
+			expectCode:  codes.OK,
+		},
+		{
+			Name:        "Ensure expanding volume in missing pool fails with failed precondition",
+			Operation:   "ControllerExpandVolume",
+			PoolMissing: true,
+			expectCode:  codes.FailedPrecondition,
+		},
+		{
+			Name:       "Ensure expanding missing volume fails with not found",
+			Operation:  "ControllerExpandVolume",
+			expectCode: codes.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name:   "lxd.csi.canonical.com",
+				nodeID: "test-node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						return nil, "", api.StatusErrorf(http.StatusNotFound, "Storage volume not found")
+					},
+					getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+						if test.PoolMissing {
+							return nil, "", api.StatusErrorf(http.StatusNotFound, "Storage pool not found")
+						}
+
+						return &api.DevLXDStoragePool{Name: pool}, "", nil
+					},
+				},
+			}
+
+			err := operations[test.Operation](NewControllerServer(d))
+			require.Equal(t, test.expectCode, status.Code(err), "Unexpected error: %v", err)
+
+			if test.PoolMissing && test.expectCode != codes.OK {
+				require.ErrorContains(t, err, `Storage pool "remote" of volume "vol-1" no longer exists`)
+			}
+		})
+	}
+}
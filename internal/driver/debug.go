@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// redactURL removes the password from the given URL, so that the URL can be
+// reported. Values that are not URLs are returned unchanged.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+
+	return u.Redacted()
+}
+
+// effectiveConfig returns the configuration the driver is running with,
+// including the resolved defaults and the information detected from LXD.
+// Passwords in endpoint URLs are redacted.
+func (d *Driver) effectiveConfig() map[string]any {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	controllerCapabilities := make([]string, 0, len(d.controllerCapabilities))
+	for _, c := range d.controllerCapabilities {
+		controllerCapabilities = append(controllerCapabilities, c.GetRpc().GetType().String())
+	}
+
+	nodeCapabilities := make([]string, 0, len(d.nodeCapabilities))
+	for _, c := range d.nodeCapabilities {
+		nodeCapabilities = append(nodeCapabilities, c.GetRpc().GetType().String())
+	}
+
+	return map[string]any{
+		"name":                           d.name,
+		"version":                        d.version,
+		"nodeID":                         d.nodeID,
+		"controller":                     d.isController,
+		"endpoint":                       redactURL(d.endpoint),
+		"devLXDEndpoint":                 redactURL(d.devLXDEndpoint),
+		"devLXDTokenFile":                d.devLXDTokenFile,
+		"devLXDClientCertFile":           d.devLXDTLS.ClientCertFile,
+		"devLXDClientKeyFile":            d.devLXDTLS.ClientKeyFile,
+		"devLXDServerCertFile":           d.devLXDTLS.ServerCertFile,
+		"devLXDAPIVersion":               d.devLXDAPIVersion,
+		"location":                       d.location,
+		"clustered":                      d.isClustered,
+		"volumeNamePrefix":               d.volumeNamePrefix,
+		"topologyKey":                    d.topologyKey,
+		"storagePoolAliases":             d.storagePoolAliases,
+		"maxConcurrentOperationsPerPool": d.maxConcurrentOperationsPerPool,
+		"reconcilePublishDevices":        d.reconcilePublishDevices,
+		"deleteVolumeDryRun":             d.deleteVolumeDryRun,
+		"maintenanceMode":                d.MaintenanceMode(),
+		"waitForVolumeReady":             d.waitForVolumeReady,
+		"sizeRoundingWarningThreshold":   d.sizeRoundingWarningThreshold,
+		"metricsAddress":                 d.metricsAddress,
+		"debugAddress":                   d.debugAddress,
+		"maxRetries":                     d.maxRetries,
+		"validateStorageClasses":         d.validateStorageClasses,
+		"trimInterval":                   d.trimInterval.String(),
+		"startupTimeout":                 d.startupTimeout.String(),
+		"publishBatchWindow":             d.publishBatchWindow.String(),
+		"deleteVolumeGracePeriod":        d.deleteVolumeGracePeriod.String(),
+		"cloneShutdownTimeout":           d.cloneShutdownTimeout.String(),
+		"controllerCapabilities":         controllerCapabilities,
+		"nodeCapabilities":               nodeCapabilities,
+	}
+}
+
+// debugConfigHandler returns an HTTP handler that reports the effective
+// configuration of the driver in JSON format.
+func (d *Driver) debugConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(d.effectiveConfig())
+	})
+}
+
+// serveDebug serves the read-only debug endpoints on the given listener
+// until the listener is closed.
+func (d *Driver) serveDebug(listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/config", d.debugConfigHandler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	err := server.Serve(listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// DetachNodeVolumes detaches all volumes managed by the driver from the given
+// node (LXD instance), for example, before the node is decommissioned. The
+// volumes are detached the same way as by ControllerUnpublishVolume. It returns
+// the names of the detached volumes. Volumes that are not attached are skipped,
+// therefore the operation can be safely repeated.
+func (d *Driver) DetachNodeVolumes(ctx context.Context, nodeID string) ([]string, error) {
+	err := d.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewControllerServer(d).detachNodeVolumes(ctx, nodeID)
+}
+
+// detachNodeVolumes detaches all volumes managed by the driver from the given node.
+func (c *controllerServer) detachNodeVolumes(ctx context.Context, nodeID string) ([]string, error) {
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, err
+	}
+
+	inst, _, err := client.GetInstance(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve instance %q: %w", nodeID, err)
+	}
+
+	// Sort device names to detach volumes in a predictable order.
+	deviceNames := make([]string, 0, len(inst.Devices))
+	for name := range inst.Devices {
+		deviceNames = append(deviceNames, name)
+	}
+
+	slices.Sort(deviceNames)
+
+	var detached []string
+	for _, name := range deviceNames {
+		dev := inst.Devices[name]
+
+		// Volumes are attached by the driver as disk devices named
+		// after the custom volume.
+		if dev["type"] != "disk" || dev["pool"] == "" || dev["source"] != name {
+			continue
+		}
+
+		poolName := dev["pool"]
+
+		// Ensure the volume was created by the driver before detaching it.
+		vol, _, err := client.GetStoragePoolVolume(poolName, "custom", name)
+		if err != nil {
+			if api.StatusErrorCheck(err, http.StatusNotFound) {
+				continue
+			}
+
+			return detached, fmt.Errorf("Failed to retrieve volume %q from storage pool %q: %w", name, poolName, err)
+		}
+
+		if !isManagedVolume(vol) {
+			continue
+		}
+
+		// Include the cluster member in the volume ID, so that the volume
+		// is detached using the same target and lock as when it is
+		// unpublished by the container orchestrator.
+		_, err = c.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: getVolumeID(c.volumeTarget(vol), poolName, name),
+			NodeId:   nodeID,
+		})
+		if err != nil {
+			return detached, fmt.Errorf("Failed to detach volume %q from node %q: %w", name, nodeID, err)
+		}
+
+		klog.InfoS("Detached volume from node", "volume", name, "pool", poolName, "node", nodeID)
+		detached = append(detached, name)
+	}
+
+	return detached, nil
+}
@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/lxd/locking"
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestDetachNodeVolumes(t *testing.T) {
+	devices := map[string]map[string]string{
+		"root":  {"type": "disk", "pool": "default", "path": "/"},
+		"eth0":  {"type": "nic", "network": "lxdbr0"},
+		"csi-1": {"type": "disk", "pool": "remote", "source": "csi-1"},
+		"csi-2": {"type": "disk", "pool": "local", "source": "csi-2", "path": "/mnt/lxd-csi/csi-2"},
+		"data":  {"type": "disk", "pool": "remote", "source": "data"},
+		"gone":  {"type": "disk", "pool": "remote", "source": "gone"},
+	}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+				return &api.DevLXDInstance{Name: name, Devices: devices}, "etag", nil
+			},
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				for devName, dev := range inst.Devices {
+					if dev == nil {
+						delete(devices, devName)
+					}
+				}
+
+				return nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				switch name {
+				case "gone":
+					return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+				case "data":
+					// Volume not created by the driver.
+					return &api.DevLXDStorageVolume{Name: name}, "", nil
+				}
+
+				return getManagedVolume(pool, volType, name)
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	// Ensure only the volumes managed by the driver are detached.
+	detached, err := controller.detachNodeVolumes(context.Background(), "test-node")
+	require.NoError(t, err)
+	require.Equal(t, []string{"csi-1", "csi-2"}, detached)
+	require.Contains(t, devices, "root")
+	require.Contains(t, devices, "eth0")
+	require.Contains(t, devices, "data")
+	require.Contains(t, devices, "gone")
+	require.NotContains(t, devices, "csi-1")
+	require.NotContains(t, devices, "csi-2")
+
+	// Ensure repeated detach is a no-op.
+	detached, err = controller.detachNodeVolumes(context.Background(), "test-node")
+	require.NoError(t, err)
+	require.Empty(t, detached)
+}
+
+func TestDetachNodeVolumesClustered(t *testing.T) {
+	devices := map[string]map[string]string{
+		"csi-1": {"type": "disk", "pool": "local", "source": "csi-1"},
+	}
+
+	var updateTarget string
+
+	d := &Driver{
+		name:        "lxd.csi.canonical.com",
+		nodeID:      "test-node",
+		isClustered: true,
+		devLXD: &fakeDevLXDServer{
+			getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+				return &api.DevLXDInstance{Name: name, Devices: devices}, "etag", nil
+			},
+			updateInstFunc: func(name string, inst api.DevLXDInstancePut, ETag string) error {
+				for devName, dev := range inst.Devices {
+					if dev == nil {
+						delete(devices, devName)
+					}
+				}
+
+				return nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return &api.DevLXDStorageVolume{
+					Name:     name,
+					Pool:     pool,
+					Location: "member-1",
+					Config: map[string]string{
+						VolumeConfigManagedBy:  VolumeManagedByValue,
+						VolumeConfigAttachedTo: "test-node",
+					},
+				}, "", nil
+			},
+			updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+				updateTarget = target
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	// Ensure the volume is not detached while it is locked by a concurrent
+	// request referring to it by its volume ID.
+	unlock := locking.TryLock(controller.volumeLockKey("member-1", "local", "csi-1"))
+	require.NotNil(t, unlock)
+
+	_, err := controller.detachNodeVolumes(context.Background(), "test-node")
+	require.Equal(t, codes.Aborted, status.Code(errors.Unwrap(err)))
+	require.Contains(t, devices, "csi-1")
+
+	unlock()
+
+	// Ensure the volume is detached using the cluster member of the volume.
+	detached, err := controller.detachNodeVolumes(context.Background(), "test-node")
+	require.NoError(t, err)
+	require.Equal(t, []string{"csi-1"}, detached)
+	require.NotContains(t, devices, "csi-1")
+	require.Equal(t, "member-1", updateTarget)
+}
@@ -6,15 +6,23 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc"
+	k8sValidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog/v2"
 
 	"github.com/canonical/lxd-csi-driver/internal/devlxd"
 	"github.com/canonical/lxd-csi-driver/internal/fs"
+	"github.com/canonical/lxd-csi-driver/internal/metrics"
 	"github.com/canonical/lxd-csi-driver/internal/utils"
 	lxdClient "github.com/canonical/lxd/client"
 	"github.com/canonical/lxd/shared/api"
@@ -26,8 +34,8 @@ import (
 var driverVersion = "dev"
 
 // driverFileSystemMountPath is the path where the CSI driver mounts
-// the filesystem volumes.
-const driverFileSystemMountPath = "/mnt/lxd-csi"
+// the filesystem volumes. It can be overridden in tests.
+var driverFileSystemMountPath = "/mnt/lxd-csi"
 
 // Default CSI driver configuration values.
 const (
@@ -47,11 +55,43 @@ const (
 	// DefaultDevLXDTokenFile is the default path to the file containing the bearer token
 	// for authenticating with devLXD.
 	DefaultDevLXDTokenFile = "/etc/lxd-csi-driver/token"
+
+	// DefaultMaxConcurrentOperationsPerPool is the default maximum number of concurrent
+	// operations the controller performs on a single storage pool.
+	DefaultMaxConcurrentOperationsPerPool = 10
+
+	// DefaultSizeRoundingWarningThreshold is the default percentage by which
+	// the allocated volume size may exceed the requested size before a
+	// warning is reported.
+	DefaultSizeRoundingWarningThreshold = 10
+
+	// DefaultMaxRetries is the default maximum number of retries of
+	// a request that fails with a transient LXD error.
+	DefaultMaxRetries = 4
+
+	// DefaultTrimInterval is the default interval at which the node trims
+	// published filesystem volumes that use periodic discard.
+	DefaultTrimInterval = 24 * time.Hour
+
+	// DefaultStartupTimeout is the default time the driver waits on startup
+	// for devLXD to become reachable.
+	DefaultStartupTimeout = 5 * time.Minute
+
+	// DefaultCloneShutdownTimeout is the default time the controller waits
+	// on shutdown for in-progress clones to complete before cancelling them.
+	DefaultCloneShutdownTimeout = 20 * time.Second
 )
 
+// devLXDStartupPollInterval is the interval at which devLXD reachability
+// is checked on startup.
+var devLXDStartupPollInterval = 5 * time.Second
+
 const (
 	// AnnotationLXDClusterMember is the name of the annotation that
 	// specifies the location for the CSINode and volume.
+	//
+	// It is the default topology key and can be overridden using
+	// [DriverOptions.TopologyKey].
 	AnnotationLXDClusterMember = "lxd.csi.canonical.com/cluster-member"
 )
 
@@ -62,12 +102,34 @@ const (
 	// This is required parameter and must be set by the user.
 	ParameterStoragePool = "storagePool"
 
+	// ParameterVolumeNamePrefix is the name of the storage class parameter
+	// that specifies the prefix of LXD volume names. It overrides the
+	// driver-wide prefix ([DriverOptions.VolumeNamePrefix]) for volumes
+	// created using the storage class.
+	ParameterVolumeNamePrefix = "volumeNamePrefix"
+
 	// ParameterStorageDriver is the name of the underlying storage pool
 	// driver.
 	//
 	// This is internal parameter used only by the CSI driver.
 	ParameterStorageDriver = "internal.storageDriver"
 
+	// ParameterVolumeConfigPrefix is the prefix of volume context keys that
+	// contain the configuration of the LXD volume as applied by LXD after the
+	// volume is created (for example, the size after rounding). Only the keys
+	// listed in [VolumeContextConfigKeys] are included.
+	//
+	// This is internal parameter used only by the CSI driver.
+	ParameterVolumeConfigPrefix = "internal.config."
+
+	// ParameterLXDConfigPrefix is the prefix of storage class parameters
+	// that are passed through to the configuration of the created LXD
+	// volume with the prefix removed. For example, "lxd.config/zfs.blocksize"
+	// sets the "zfs.blocksize" volume option. The keys managed by the driver
+	// cannot be set, see [isReservedVolumeConfigKey]. As all storage class
+	// parameters, the prefixed parameters are included in the volume context.
+	ParameterLXDConfigPrefix = "lxd.config/"
+
 	// ParameterPVCName contains the name of the PVC that triggered volume creation.
 	// It is passed to the controller by the CSI provisioner.
 	ParameterPVCName = "csi.storage.k8s.io/pvc/name"
@@ -79,8 +141,208 @@ const (
 	// ParameterPVName contains the name of the PV that represents the LXD volume.
 	// It is passed to the controller by the CSI provisioner.
 	ParameterPVName = "csi.storage.k8s.io/pv/name"
+
+	// ParameterVolumeSnapshotName contains the name of the VolumeSnapshot that
+	// triggered snapshot creation. It is passed to the controller by the CSI
+	// snapshotter.
+	ParameterVolumeSnapshotName = "csi.storage.k8s.io/volumesnapshot/name"
+
+	// ParameterVolumeSnapshotNamespace contains the namespace of the VolumeSnapshot
+	// that triggered snapshot creation. It is passed to the controller by the CSI
+	// snapshotter.
+	ParameterVolumeSnapshotNamespace = "csi.storage.k8s.io/volumesnapshot/namespace"
+
+	// ParameterSnapshotDeletionPolicy is the name of the volume snapshot class
+	// parameter that specifies whether the LXD snapshot is removed when the
+	// corresponding snapshot is deleted. Supported values are "Delete" (default)
+	// and "Retain".
+	//
+	// The policy is recorded in the LXD snapshot when the snapshot is created.
+	ParameterSnapshotDeletionPolicy = "deletionPolicy"
+
+	// ParameterSnapshotsMaxCount is the name of the volume snapshot class
+	// parameter that specifies the maximum number of snapshots a single
+	// volume can have. Snapshot creation is rejected once the limit is reached.
+	// If not set, the number of snapshots is not limited.
+	ParameterSnapshotsMaxCount = "snapshots.maxCount"
+
+	// ParameterDiscard is the name of the storage class parameter that
+	// specifies how unused blocks of filesystem volumes are returned to
+	// the storage pool. Supported values are "online" and "periodic".
+	// If not set, the storage pool defaults apply.
+	//
+	// Online discard mounts the volume with the "discard" option, which
+	// reclaims space immediately, but may slow down file deletions.
+	// Periodic discard trims published volumes on the node at a fixed
+	// interval, which batches the work, but reclaims space with a delay.
+	ParameterDiscard = "discard"
+
+	// ParameterFSType is the name of the storage class parameter that
+	// specifies the filesystem of filesystem volumes. Supported values are
+	// "ext4", "xfs" and "btrfs". It maps to the "block.filesystem" option of
+	// the LXD volume. If not set, the standard [ParameterCSIFSType] parameter
+	// or the filesystem type of the volume capability is used, and if none
+	// is set, the storage pool defaults apply.
+	ParameterFSType = "fsType"
+
+	// ParameterCSIFSType is the standard CSI storage class parameter that
+	// specifies the filesystem type of the volume.
+	ParameterCSIFSType = "csi.storage.k8s.io/fstype"
+
+	// ParameterIOCache is the name of the storage class parameter that
+	// specifies the caching mode of the disk device the block volume is
+	// attached as. Supported values are "none", "writeback" and "unsafe".
+	// It maps to the "io.cache" option of the LXD disk device and applies
+	// only to block volumes, as filesystem volumes are shared with the
+	// instance using a filesystem passthrough.
+	ParameterIOCache = "ioCache"
+
+	// ParameterReadAheadKB is the name of the storage class parameter that
+	// specifies the read-ahead of the block device in kibibytes. LXD does
+	// not provide a read-ahead option, therefore it is applied on the node
+	// when the block volume is published.
+	ParameterReadAheadKB = "readAheadKB"
+
+	// ParameterColocateWith is the name of the storage class parameter that
+	// specifies a PVC, in format "<name>" or "<namespace>/<name>", whose
+	// volume the new volume is co-located with. The new volume is created
+	// on the same LXD cluster member as the referenced volume, which must
+	// exist in the same local storage pool. If the namespace is omitted,
+	// the namespace of the PVC being provisioned is used.
+	ParameterColocateWith = "colocateWith"
+
+	// ParameterMinSizeBlock is the name of the storage class parameter that
+	// specifies the minimum size of block volumes (for example, "1GiB").
+	// Smaller requests are rounded up to the minimum size.
+	ParameterMinSizeBlock = "minSize.block"
+
+	// ParameterMinSizeFilesystem is the name of the storage class parameter
+	// that specifies the minimum size of filesystem volumes (for example,
+	// "100MiB"). Smaller requests are rounded up to the minimum size.
+	ParameterMinSizeFilesystem = "minSize.filesystem"
+
+	// ParameterDescriptionTemplate is the name of the storage class parameter
+	// that specifies a Go template of the LXD volume description. See
+	// [volumeDescriptionData] for the values available in the template.
+	ParameterDescriptionTemplate = "descriptionTemplate"
+
+	// ParameterPVCLabels is the name of the storage class parameter that
+	// specifies a comma-separated list of PVC label keys. The values of the
+	// listed labels are copied from the PVC to the LXD volume configuration
+	// as "user.label.<key>" at creation, which allows filtering volumes by
+	// Kubernetes labels in LXD.
+	ParameterPVCLabels = "pvcLabels"
+
+	// ParameterDeletionProtection is the name of the storage class parameter
+	// that specifies whether volumes can be protected against deletion by
+	// annotating their PVC with [AnnotationDeletionProtection]. Supported
+	// values are "true" and "false" (default). The PVC is retrieved from the
+	// Kubernetes API only if this parameter or [ParameterPVCLabels] is set.
+	ParameterDeletionProtection = "deletionProtection"
 )
 
+// minSizeParameters maps volume content types to the storage class parameter
+// that specifies the minimum size of volumes with that content type.
+var minSizeParameters = map[string]string{
+	"block":      ParameterMinSizeBlock,
+	"filesystem": ParameterMinSizeFilesystem,
+}
+
+// ioCacheModes contains the supported values of [ParameterIOCache].
+var ioCacheModes = []string{"none", "writeback", "unsafe"}
+
+// fsTypes contains the supported values of [ParameterFSType].
+var fsTypes = []string{"ext4", "xfs", "btrfs"}
+
+const (
+	// PublishContextDeviceName is the publish context key that contains the
+	// name of the LXD disk device the volume is attached as. The node uses
+	// it to discover the block device of the volume.
+	PublishContextDeviceName = "deviceName"
+)
+
+const (
+	// DiscardOnline mounts filesystem volumes with the "discard" option.
+	DiscardOnline = "online"
+
+	// DiscardPeriodic periodically trims published filesystem volumes.
+	DiscardPeriodic = "periodic"
+)
+
+const (
+	// SnapshotDeletionPolicyDelete removes the LXD snapshot when the snapshot is deleted.
+	SnapshotDeletionPolicyDelete = "Delete"
+
+	// SnapshotDeletionPolicyRetain keeps the LXD snapshot when the snapshot is deleted.
+	SnapshotDeletionPolicyRetain = "Retain"
+)
+
+const (
+	// VolumeConfigPVCName is the LXD volume configuration key that contains
+	// the name of the PVC the volume was created for.
+	VolumeConfigPVCName = "user.pvc-name"
+
+	// VolumeConfigPVCNamespace is the LXD volume configuration key that contains
+	// the namespace of the PVC the volume was created for.
+	VolumeConfigPVCNamespace = "user.pvc-namespace"
+
+	// VolumeConfigPVName is the LXD volume configuration key that contains
+	// the name of the PV that represents the volume.
+	VolumeConfigPVName = "user.pv-name"
+
+	// VolumeConfigManagedBy is the LXD volume configuration key that marks
+	// the volume as created by the CSI driver. The driver refuses to delete
+	// volumes without this marker.
+	VolumeConfigManagedBy = "user.managed-by"
+
+	// VolumeManagedByValue is the value of [VolumeConfigManagedBy] set on
+	// volumes created by the CSI driver.
+	VolumeManagedByValue = "lxd-csi"
+
+	// VolumeConfigStorageDriver is the LXD volume configuration key that
+	// contains the name of the storage driver backing the volume.
+	VolumeConfigStorageDriver = "user.storage-driver"
+
+	// VolumeConfigRestoredFrom is the LXD volume configuration key that
+	// contains the ID of the snapshot the volume was restored from.
+	VolumeConfigRestoredFrom = "user.restored-from"
+
+	// VolumeConfigLabelPrefix is the prefix of LXD volume configuration keys
+	// that contain the PVC labels listed in [ParameterPVCLabels].
+	VolumeConfigLabelPrefix = "user.label."
+)
+
+// VolumeContextConfigKeys contains the LXD volume configuration keys that are
+// included in the volume context of a newly created volume.
+var VolumeContextConfigKeys = []string{
+	"size",
+	"block.filesystem",
+	"block.mount_options",
+	"block.type",
+}
+
+// reservedVolumeConfigKeys contains the LXD volume configuration keys that
+// are managed by the driver and therefore cannot be set using parameters
+// prefixed with [ParameterLXDConfigPrefix].
+var reservedVolumeConfigKeys = []string{
+	"size",
+	VolumeConfigAttachedTo,
+	VolumeConfigDeleteAfter,
+	VolumeConfigDeletionProtection,
+	VolumeConfigManagedBy,
+	VolumeConfigPVCName,
+	VolumeConfigPVCNamespace,
+	VolumeConfigPVName,
+	VolumeConfigRestoredFrom,
+	VolumeConfigStorageDriver,
+}
+
+// isReservedVolumeConfigKey reports whether the given LXD volume
+// configuration key is managed by the driver.
+func isReservedVolumeConfigKey(key string) bool {
+	return slices.Contains(reservedVolumeConfigKeys, key) || strings.HasPrefix(key, VolumeConfigLabelPrefix)
+}
+
 // DriverOptions contains the configurable options for the driver.
 type DriverOptions struct {
 	// Name of the driver.
@@ -89,9 +351,12 @@ type DriverOptions struct {
 	// CSI endpoint (unix).
 	Endpoint string
 
-	// DevLXD endpoint (unix).
+	// DevLXD endpoint (unix or https).
 	DevLXDEndpoint string
 
+	// TLS configuration used when DevLXD endpoint is a remote https address.
+	DevLXDTLS devlxd.TLSOptions
+
 	// Prefix used for LXD volume names.
 	VolumeNamePrefix string
 
@@ -100,6 +365,81 @@ type DriverOptions struct {
 
 	// IsController indicates whether to start controller server.
 	IsController bool
+
+	// Maximum number of concurrent operations per storage pool.
+	// Zero disables the limit.
+	MaxConcurrentOperationsPerPool int
+
+	// Topology segment key that specifies the LXD cluster member.
+	// Defaults to [AnnotationLXDClusterMember].
+	TopologyKey string
+
+	// Whether to replace an existing disk device with mismatched parameters
+	// when publishing a volume, instead of failing the request.
+	ReconcilePublishDevices bool
+
+	// Whether to only log the volumes that would be deleted by DeleteVolume
+	// without deleting them. DeleteVolume fails for such volumes, so that
+	// their persistent volumes are kept and the deletion is retried.
+	DeleteVolumeDryRun bool
+
+	// Whether the controller starts in maintenance mode, in which volumes
+	// are neither created nor deleted, but can still be published and
+	// unpublished. Maintenance mode is toggled at runtime with SIGUSR1.
+	MaintenanceMode bool
+
+	// Whether CreateVolume waits until the created volume can be retrieved
+	// with the expected content type before returning.
+	WaitForVolumeReady bool
+
+	// Percentage by which the allocated volume size may exceed the requested
+	// size before a warning is reported. Set to 0 to disable the warning.
+	SizeRoundingWarningThreshold int
+
+	// Address on which the read-only debug endpoint reporting the effective
+	// driver configuration is served. The endpoint is not served if empty.
+	DebugAddress string
+
+	// Address on which the metrics are served. Metrics are not served
+	// if the address is empty.
+	MetricsAddress string
+
+	// Maximum number of retries of a request that fails with a transient
+	// LXD error, regardless of the request deadline. Set to 0 to disable
+	// retries.
+	MaxRetries int
+
+	// Whether the controller validates the storage classes that use
+	// the driver on startup.
+	ValidateStorageClasses bool
+
+	// Interval at which the node trims published filesystem volumes that
+	// use periodic discard. Defaults to [DefaultTrimInterval].
+	TrimInterval time.Duration
+
+	// Maximum time the driver waits on startup for devLXD to become
+	// reachable before failing. Defaults to [DefaultStartupTimeout].
+	StartupTimeout time.Duration
+
+	// Time to wait for further volumes to be published to the same node
+	// before the node instance is updated with all of them at once.
+	// Set to 0 to update the instance for each volume separately.
+	PublishBatchWindow time.Duration
+
+	// Time after which a deleted volume is permanently deleted. Until then,
+	// the volume is only marked as deleted and can be recovered. Set to 0
+	// to delete volumes immediately.
+	DeleteVolumeGracePeriod time.Duration
+
+	// Maximum time the controller waits on shutdown for in-progress clones
+	// to complete before cancelling them. Defaults to
+	// [DefaultCloneShutdownTimeout].
+	CloneShutdownTimeout time.Duration
+
+	// Mapping of old storage pool names to new ones. It allows
+	// volumes provisioned before a storage pool was renamed to
+	// be managed using the new storage pool name.
+	StoragePoolAliases map[string]string
 }
 
 // Driver represents a CSI driver for LXD.
@@ -118,6 +458,7 @@ type Driver struct {
 	// DevLXD.
 	devLXD         lxdClient.DevLXDServer
 	devLXDEndpoint string
+	devLXDTLS      devlxd.TLSOptions
 
 	// Path to the file containing the bearer token for authenticating with devLXD.
 	devLXDTokenFile string
@@ -129,9 +470,69 @@ type Driver struct {
 	location    string
 	isClustered bool
 
+	// Version of the devLXD API reported by LXD.
+	devLXDAPIVersion string
+
 	// Prefix used for LXD volume names.
 	volumeNamePrefix string
 
+	// Maximum number of concurrent operations per storage pool.
+	maxConcurrentOperationsPerPool int
+
+	// Mapping of old storage pool names to new ones.
+	storagePoolAliases map[string]string
+
+	// Whether to replace mismatched disk devices when publishing a volume.
+	reconcilePublishDevices bool
+
+	// Topology segment key that specifies the LXD cluster member.
+	topologyKey string
+
+	// Whether DeleteVolume only logs the volumes that would be deleted.
+	deleteVolumeDryRun bool
+
+	// Whether CreateVolume waits until the created volume is ready.
+	waitForVolumeReady bool
+
+	// Percentage by which the allocated volume size may exceed the
+	// requested size before a warning is reported.
+	sizeRoundingWarningThreshold int
+
+	// Address on which the metrics are served.
+	metricsAddress string
+
+	// Address on which the debug endpoint is served.
+	debugAddress string
+
+	// Maximum number of retries of a request that fails with a transient
+	// LXD error.
+	maxRetries int
+
+	// Whether the controller validates the storage classes on startup.
+	validateStorageClasses bool
+
+	// Interval at which the node trims volumes that use periodic discard.
+	trimInterval time.Duration
+
+	// Maximum time to wait on startup for devLXD to become reachable.
+	startupTimeout time.Duration
+
+	// Time to wait for further volumes to be published to the same node.
+	publishBatchWindow time.Duration
+
+	// Time after which a deleted volume is permanently deleted.
+	deleteVolumeGracePeriod time.Duration
+
+	// Maximum time to wait on shutdown for in-progress clones.
+	cloneShutdownTimeout time.Duration
+
+	// Whether devLXD was reachable on startup. Until then, the driver
+	// reports it is not ready.
+	ready atomic.Bool
+
+	// Whether volume provisioning is paused for maintenance.
+	maintenance atomic.Bool
+
 	// gRPC server.
 	server *grpc.Server
 
@@ -146,37 +547,192 @@ func NewDriver(opts DriverOptions) *Driver {
 		version:          driverVersion,
 		endpoint:         opts.Endpoint,
 		devLXDEndpoint:   opts.DevLXDEndpoint,
+		devLXDTLS:        opts.DevLXDTLS,
 		devLXDTokenFile:  DefaultDevLXDTokenFile,
 		volumeNamePrefix: opts.VolumeNamePrefix,
 		nodeID:           opts.NodeID,
 		isController:     opts.IsController,
+
+		maxConcurrentOperationsPerPool: opts.MaxConcurrentOperationsPerPool,
+		storagePoolAliases:             opts.StoragePoolAliases,
+		reconcilePublishDevices:        opts.ReconcilePublishDevices,
+		topologyKey:                    opts.TopologyKey,
+		deleteVolumeDryRun:             opts.DeleteVolumeDryRun,
+		waitForVolumeReady:             opts.WaitForVolumeReady,
+		sizeRoundingWarningThreshold:   opts.SizeRoundingWarningThreshold,
+		metricsAddress:                 opts.MetricsAddress,
+		debugAddress:                   opts.DebugAddress,
+		maxRetries:                     opts.MaxRetries,
+		validateStorageClasses:         opts.ValidateStorageClasses,
+		trimInterval:                   opts.TrimInterval,
+		startupTimeout:                 opts.StartupTimeout,
+		publishBatchWindow:             opts.PublishBatchWindow,
+		deleteVolumeGracePeriod:        opts.DeleteVolumeGracePeriod,
+		cloneShutdownTimeout:           opts.CloneShutdownTimeout,
+	}
+
+	if d.trimInterval == 0 {
+		d.trimInterval = DefaultTrimInterval
+	}
+
+	if d.startupTimeout == 0 {
+		d.startupTimeout = DefaultStartupTimeout
+	}
+
+	if d.cloneShutdownTimeout == 0 {
+		d.cloneShutdownTimeout = DefaultCloneShutdownTimeout
 	}
 
+	if d.topologyKey == "" {
+		d.topologyKey = AnnotationLXDClusterMember
+	}
+
+	d.maintenance.Store(opts.MaintenanceMode)
+
 	return d
 }
 
+// MaintenanceMode returns true if volume provisioning is paused for
+// maintenance.
+func (d *Driver) MaintenanceMode() bool {
+	return d.maintenance.Load()
+}
+
+// SetMaintenanceMode pauses or resumes volume provisioning. While paused,
+// CreateVolume and DeleteVolume fail with a retryable error, so that the
+// requests are retried once maintenance is over.
+func (d *Driver) SetMaintenanceMode(enabled bool) {
+	if d.maintenance.Swap(enabled) != enabled {
+		klog.InfoS("Changed maintenance mode", "enabled", enabled)
+	}
+}
+
 // Version returns the driver version.
 func (d *Driver) Version() string {
 	return d.version
 }
 
+// driverNameRegexp matches valid CSI driver names. The CSI specification
+// requires the name to be at most 63 characters long, begin and end with
+// an alphanumeric character, and contain only alphanumerics, dashes (-)
+// and dots (.) in between.
+var driverNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]{0,61}[a-zA-Z0-9])?$`)
+
+// reservedVolumeNamePrefixes contains the volume name prefixes that are
+// rejected, as the resulting volume names would be confused with snapshot
+// names. The external-snapshotter names snapshots "snapshot-<uuid>", and
+// LXD names snapshots "snap<n>" by default.
+var reservedVolumeNamePrefixes = []string{"snapshot", "snap"}
+
+// validateVolumeNamePrefix checks whether the given volume name prefix is a
+// valid hostname and not reserved.
+func validateVolumeNamePrefix(prefix string) error {
+	err := lxdValidate.IsHostname(prefix)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(reservedVolumeNamePrefixes, strings.ToLower(prefix)) {
+		return fmt.Errorf("Prefix is reserved, as volume names would be confused with snapshot names (reserved prefixes: %s)", strings.Join(reservedVolumeNamePrefixes, ", "))
+	}
+
+	return nil
+}
+
 // Validate checks whether the driver configuration is valid.
 func (d *Driver) Validate() error {
+	// Validate driver name.
+	if d.name != "" && !driverNameRegexp.MatchString(d.name) {
+		return fmt.Errorf("Driver name %q is not valid: Name must be 1-63 characters long, begin and end with an alphanumeric character, and contain only alphanumerics, dashes and dots", d.name)
+	}
+
 	// Validate volume name prefix.
 	// Ensure the volume name prefix is not longer than 63 characters. The full name is
 	// generated as "<prefix>-<uuid>", where the UUID is 36 characters plus hyphen.
 	// Although the maximum volume name length varies by LXD storage driver, we cap the name
 	// length at 100 characters to stay within safe limits.
-	err := lxdValidate.IsHostname(d.volumeNamePrefix)
+	err := validateVolumeNamePrefix(d.volumeNamePrefix)
 	if err != nil {
 		return fmt.Errorf("Volume name prefix %q is not valid: %w", d.volumeNamePrefix, err)
 	}
 
+	// Validate TLS configuration for remote devLXD endpoint.
+	if strings.HasPrefix(d.devLXDEndpoint, "https://") {
+		if d.devLXDTLS.ClientCertFile == "" || d.devLXDTLS.ClientKeyFile == "" {
+			return fmt.Errorf("Client certificate and key are required for devLXD endpoint %q", d.devLXDEndpoint)
+		}
+	} else if d.devLXDTLS != (devlxd.TLSOptions{}) {
+		return fmt.Errorf("TLS options are supported only for https devLXD endpoint, got %q", d.devLXDEndpoint)
+	}
+
+	// Validate topology key.
+	if d.topologyKey != "" {
+		errs := k8sValidation.IsQualifiedName(d.topologyKey)
+		if len(errs) > 0 {
+			return fmt.Errorf("Topology key %q is not a valid label key: %s", d.topologyKey, strings.Join(errs, "; "))
+		}
+	}
+
+	for oldName, newName := range d.storagePoolAliases {
+		if oldName == "" || newName == "" {
+			return fmt.Errorf("Storage pool alias %q=%q is not valid: Pool names cannot be empty", oldName, newName)
+		}
+
+		if oldName == newName {
+			return fmt.Errorf("Storage pool alias %q=%q is not valid: Pool cannot be aliased to itself", oldName, newName)
+		}
+
+		_, ok := d.storagePoolAliases[newName]
+		if ok {
+			return fmt.Errorf("Storage pool alias %q=%q is not valid: Target pool %q is also aliased", oldName, newName, newName)
+		}
+	}
+
+	if len(d.storagePoolAliases) > 0 {
+		klog.InfoS("Storage pool aliases are configured", "aliases", d.storagePoolAliases)
+	}
+
+	if d.maxConcurrentOperationsPerPool < 0 {
+		return fmt.Errorf("Maximum number of concurrent operations per storage pool cannot be negative: %d", d.maxConcurrentOperationsPerPool)
+	}
+
+	if d.trimInterval < 0 {
+		return fmt.Errorf("Trim interval cannot be negative: %s", d.trimInterval)
+	}
+
+	if d.sizeRoundingWarningThreshold < 0 {
+		return fmt.Errorf("Size rounding warning threshold cannot be negative: %d", d.sizeRoundingWarningThreshold)
+	}
+
+	if d.maxRetries < 0 {
+		return fmt.Errorf("Maximum number of retries cannot be negative: %d", d.maxRetries)
+	}
+
+	if d.startupTimeout < 0 {
+		return fmt.Errorf("Startup timeout cannot be negative: %s", d.startupTimeout)
+	}
+
+	if d.publishBatchWindow < 0 {
+		return fmt.Errorf("Publish batch window cannot be negative: %s", d.publishBatchWindow)
+	}
+
+	if d.deleteVolumeGracePeriod < 0 {
+		return fmt.Errorf("Delete volume grace period cannot be negative: %s", d.deleteVolumeGracePeriod)
+	}
+
+	if d.cloneShutdownTimeout < 0 {
+		return fmt.Errorf("Clone shutdown timeout cannot be negative: %s", d.cloneShutdownTimeout)
+	}
+
 	return nil
 }
 
 // DevLXDClient returns the connected DevLXD client.
 // If devLXD token has changed, or connection has not been established yet, a new client is returned.
+//
+// The returned client is shared between concurrent requests and must not be
+// modified. Use client.UseTarget to obtain a derived client that targets a
+// specific cluster member; it does not affect the shared client.
 func (d *Driver) DevLXDClient() (lxdClient.DevLXDServer, error) {
 	// Return connected client if it exists.
 	d.lock.Lock()
@@ -203,7 +759,7 @@ func (d *Driver) DevLXDClient() (lxdClient.DevLXDServer, error) {
 		devLXDClient = d.devLXD.UseBearerToken(token)
 	} else {
 		// Connect to DevLXD because DevLXD client is not initialized yet.
-		devLXDClient, err = devlxd.Connect(d.devLXDEndpoint, token)
+		devLXDClient, err = devlxd.Connect(d.devLXDEndpoint, token, d.devLXDTLS)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to connect to devLXD: %w", err)
 		}
@@ -225,11 +781,45 @@ func (d *Driver) DevLXDClient() (lxdClient.DevLXDServer, error) {
 	d.devLXD = devLXDClient
 	d.location = info.Location
 	d.isClustered = info.Environment.ServerClustered
+	d.devLXDAPIVersion = info.APIVersion
 	d.hasDevLXDTokenChanged = false
 
 	return d.devLXD, nil
 }
 
+// waitDevLXDReachable waits until the devLXD client can be connected, or
+// until the startup timeout is reached. This allows the driver to start
+// before LXD is ready, for example, when the whole cluster is booting.
+// Once devLXD is reachable, the driver is marked as ready.
+func (d *Driver) waitDevLXDReachable(ctx context.Context) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, d.startupTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(devLXDStartupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DevLXDClient()
+		if err == nil {
+			d.ready.Store(true)
+			return nil
+		}
+
+		klog.InfoS("Waiting for devLXD to become reachable", "endpoint", d.devLXDEndpoint, "err", err)
+
+		select {
+		case <-timeoutCtx.Done():
+			// Distinguish the driver being stopped from the timeout.
+			if ctx.Err() != nil {
+				return fmt.Errorf("Stopped waiting for devLXD to become reachable: %w", ctx.Err())
+			}
+
+			return fmt.Errorf("Timed out waiting for devLXD to become reachable: %w", err)
+		case <-ticker.C:
+		}
+	}
+}
+
 // Run starts CSI driver gRPC server.
 func (d *Driver) Run() error {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -247,12 +837,6 @@ func (d *Driver) Run() error {
 		return err
 	}
 
-	// Connect to devLXD.
-	_, err = d.DevLXDClient()
-	if err != nil {
-		return err
-	}
-
 	// Watch for token file changes.
 	handleTokenFileChange := func(path string) {
 		klog.InfoS("DevLXD token file has changed, will re-read it on next operation", "path", path)
@@ -266,6 +850,42 @@ func (d *Driver) Run() error {
 		return fmt.Errorf("Failed to watch DevLXD token file %q for changes: %w", d.devLXDTokenFile, err)
 	}
 
+	// Serve metrics if enabled.
+	if d.metricsAddress != "" {
+		metricsListener, err := net.Listen("tcp", d.metricsAddress)
+		if err != nil {
+			return fmt.Errorf("Failed to listen on metrics address %q: %w", d.metricsAddress, err)
+		}
+
+		defer func() { _ = metricsListener.Close() }()
+
+		go func() {
+			klog.InfoS("Serving metrics", "address", d.metricsAddress)
+			err := metrics.Serve(metricsListener)
+			if err != nil {
+				klog.ErrorS(err, "Failed to serve metrics", "address", d.metricsAddress)
+			}
+		}()
+	}
+
+	// Serve debug endpoint if enabled.
+	if d.debugAddress != "" {
+		debugListener, err := net.Listen("tcp", d.debugAddress)
+		if err != nil {
+			return fmt.Errorf("Failed to listen on debug address %q: %w", d.debugAddress, err)
+		}
+
+		defer func() { _ = debugListener.Close() }()
+
+		go func() {
+			klog.InfoS("Serving debug endpoint", "address", d.debugAddress)
+			err := d.serveDebug(debugListener)
+			if err != nil {
+				klog.ErrorS(err, "Failed to serve debug endpoint", "address", d.debugAddress)
+			}
+		}()
+	}
+
 	// Construct gRPC unix address.
 	url, socket, err := utils.ParseUnixSocketURL(d.endpoint)
 	if err != nil {
@@ -287,6 +907,8 @@ func (d *Driver) Run() error {
 	// Register CSI services.
 	csi.RegisterIdentityServer(d.server, NewIdentityServer(d))
 
+	var controller *controllerServer
+
 	if d.isController {
 		d.SetControllerServiceCapabilities(
 			csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
@@ -294,17 +916,106 @@ func (d *Driver) Run() error {
 			csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 			csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
 			csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+			csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+			csi.ControllerServiceCapability_RPC_GET_VOLUME,
+			csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
 		)
 
-		csi.RegisterControllerServer(d.server, NewControllerServer(d))
+		controller = NewControllerServer(d)
+		if d.deleteVolumeGracePeriod > 0 {
+			go controller.RunSoftDeleteSweeper(ctx, min(d.deleteVolumeGracePeriod, softDeleteSweepInterval))
+		}
+
+		csi.RegisterControllerServer(d.server, controller)
 	} else {
-		d.SetNodeServiceCapabilities()
-		csi.RegisterNodeServer(d.server, NewNodeServer(d))
+		d.SetNodeServiceCapabilities(
+			csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+			csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+			csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+		)
+
+		nodeServer := NewNodeServer(d)
+		go nodeServer.volumeHealth.Run(ctx, volumeHealthCheckInterval)
+		go nodeServer.volumeTrimmer.Run(ctx, d.trimInterval)
+
+		csi.RegisterNodeServer(d.server, nodeServer)
 	}
 
-	// Start gRPC server.
+	// Toggle maintenance mode on SIGUSR1.
+	maintenanceSignals := make(chan os.Signal, 1)
+	signal.Notify(maintenanceSignals, syscall.SIGUSR1)
+	defer signal.Stop(maintenanceSignals)
+
+	go func() {
+		for {
+			select {
+			case <-maintenanceSignals:
+				d.SetMaintenanceMode(!d.MaintenanceMode())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Stop gracefully on termination. In-progress requests are completed,
+	// except for long-running clones, which are cancelled after a timeout.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			klog.InfoS("Shutting down LXD CSI driver", "signal", sig.String())
+			if controller != nil {
+				controller.stopClones(d.cloneShutdownTimeout)
+			}
+
+			cancel()
+			d.server.GracefulStop()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Start gRPC server. It is started before devLXD is reachable,
+	// so that the probe reports the driver is starting, not failed.
 	klog.InfoS("Listening for connections", "endpoint", url.String())
-	err = d.server.Serve(listener)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- d.server.Serve(listener)
+	}()
+
+	// Connect to devLXD.
+	err = d.waitDevLXDReachable(ctx)
+	if err != nil {
+		d.server.Stop()
+
+		// The driver was stopped before devLXD became reachable.
+		if ctx.Err() != nil {
+			klog.InfoS("Stopped before devLXD became reachable", "endpoint", d.devLXDEndpoint)
+			return nil
+		}
+
+		return err
+	}
+
+	klog.InfoS("Connected to devLXD", "endpoint", d.devLXDEndpoint)
+
+	// Record the attachments of volumes attached before attachments were
+	// recorded, so that ListVolumes reports their published nodes.
+	if controller != nil {
+		go controller.backfillVolumeAttachments(ctx)
+	}
+
+	// Validate storage classes in the background, as the problems
+	// are only reported and do not prevent the controller from starting.
+	if d.isController && d.validateStorageClasses {
+		go d.ValidateStorageClasses(ctx)
+	}
+
+	err = <-serveErr
 	if err != nil {
 		return fmt.Errorf("Failed to serve gRPC server: %w", err)
 	}
@@ -347,6 +1058,46 @@ func getVolumeID(clusterMember string, poolName string, volName string) string {
 	return volumeID
 }
 
+// resolvePoolName returns the current name of the given storage pool.
+// If the storage pool was renamed and an alias is configured for its
+// old name, the new storage pool name is returned.
+func (d *Driver) resolvePoolName(poolName string) string {
+	newName, ok := d.storagePoolAliases[poolName]
+	if !ok {
+		return poolName
+	}
+
+	// Aliases are resolved for every request, therefore they are logged
+	// once on startup and only at higher verbosity here.
+	klog.V(4).InfoS("Applying storage pool alias", "pool", poolName, "alias", newName)
+	return newName
+}
+
+// ParseStoragePoolAliases parses storage pool aliases in format
+// "<old>=<new>[,<old>=<new>...]" into a map of old to new pool names.
+func ParseStoragePoolAliases(aliases string) (map[string]string, error) {
+	result := make(map[string]string)
+	if aliases == "" {
+		return result, nil
+	}
+
+	for alias := range strings.SplitSeq(aliases, ",") {
+		oldName, newName, ok := strings.Cut(strings.TrimSpace(alias), "=")
+		if !ok {
+			return nil, fmt.Errorf("Invalid storage pool alias %q: Expected format \"<old>=<new>\"", alias)
+		}
+
+		_, exists := result[oldName]
+		if exists {
+			return nil, fmt.Errorf("Invalid storage pool alias %q: Duplicate alias for pool %q", alias, oldName)
+		}
+
+		result[oldName] = newName
+	}
+
+	return result, nil
+}
+
 // splitVolumeID splits an internal volume ID separated into cluster member name,
 // pool name, and volume name.
 func splitVolumeID(volumeID string) (clusterMember string, poolName string, volName string, err error) {
@@ -1,9 +1,14 @@
 package driver
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/canonical/lxd-csi-driver/internal/devlxd"
 )
 
 func TestValidateDriver(t *testing.T) {
@@ -12,6 +17,30 @@ func TestValidateDriver(t *testing.T) {
 		Driver      *Driver
 		expectError string
 	}{
+		{
+			Name: "Ensure custom driver name is accepted",
+			Driver: &Driver{
+				name:             "canary.lxd.csi.canonical.com",
+				volumeNamePrefix: "csi",
+			},
+			expectError: "",
+		},
+		{
+			Name: "Ensure driver name cannot end with a dot",
+			Driver: &Driver{
+				name:             "lxd.csi.canonical.com.",
+				volumeNamePrefix: "csi",
+			},
+			expectError: "Driver name \"lxd.csi.canonical.com.\" is not valid",
+		},
+		{
+			Name: "Ensure driver name cannot contain slashes",
+			Driver: &Driver{
+				name:             "lxd.csi/canonical.com",
+				volumeNamePrefix: "csi",
+			},
+			expectError: "is not valid",
+		},
 		{
 			Name: "Ensure valid volume name prefix is accepted",
 			Driver: &Driver{
@@ -40,6 +69,133 @@ func TestValidateDriver(t *testing.T) {
 			},
 			expectError: "Name must be 1-63 characters long",
 		},
+		{
+			Name: "Ensure reserved volume name prefix is rejected",
+			Driver: &Driver{
+				volumeNamePrefix: "snapshot",
+			},
+			expectError: "Prefix is reserved",
+		},
+		{
+			Name: "Ensure reserved volume name prefix is rejected regardless of case",
+			Driver: &Driver{
+				volumeNamePrefix: "Snap",
+			},
+			expectError: "Prefix is reserved",
+		},
+		{
+			Name: "Ensure volume name prefix starting with a reserved prefix is accepted",
+			Driver: &Driver{
+				volumeNamePrefix: "snapshots-db",
+			},
+		},
+		{
+			Name: "Ensure negative maximum number of concurrent operations per pool is rejected",
+			Driver: &Driver{
+				volumeNamePrefix:               "csi",
+				maxConcurrentOperationsPerPool: -1,
+			},
+			expectError: "cannot be negative",
+		},
+		{
+			Name: "Ensure remote devLXD endpoint requires client certificate",
+			Driver: &Driver{
+				volumeNamePrefix: "csi",
+				devLXDEndpoint:   "https://lxd.example.com:8443",
+			},
+			expectError: "Client certificate and key are required",
+		},
+		{
+			Name: "Ensure TLS options are rejected for unix devLXD endpoint",
+			Driver: &Driver{
+				volumeNamePrefix: "csi",
+				devLXDEndpoint:   DefaultDevLXDEndpoint,
+				devLXDTLS: devlxd.TLSOptions{
+					ClientCertFile: "/etc/lxd-csi-driver/client.crt",
+				},
+			},
+			expectError: "TLS options are supported only for https devLXD endpoint",
+		},
+		{
+			Name: "Ensure storage pool cannot be aliased to itself",
+			Driver: &Driver{
+				volumeNamePrefix:   "csi",
+				storagePoolAliases: map[string]string{"pool": "pool"},
+			},
+			expectError: "Pool cannot be aliased to itself",
+		},
+		{
+			Name: "Ensure chained storage pool aliases are rejected",
+			Driver: &Driver{
+				volumeNamePrefix:   "csi",
+				storagePoolAliases: map[string]string{"a": "b", "b": "c"},
+			},
+			expectError: "is also aliased",
+		},
+		{
+			Name: "Ensure negative size rounding warning threshold is rejected",
+			Driver: &Driver{
+				volumeNamePrefix:             "csi",
+				sizeRoundingWarningThreshold: -1,
+			},
+			expectError: "Size rounding warning threshold cannot be negative",
+		},
+		{
+			Name: "Ensure negative maximum number of retries is rejected",
+			Driver: &Driver{
+				volumeNamePrefix: "csi",
+				maxRetries:       -1,
+			},
+			expectError: "Maximum number of retries cannot be negative",
+		},
+		{
+			Name: "Ensure negative startup timeout is rejected",
+			Driver: &Driver{
+				volumeNamePrefix: "csi",
+				startupTimeout:   -time.Second,
+			},
+			expectError: "Startup timeout cannot be negative",
+		},
+		{
+			Name: "Ensure negative publish batch window is rejected",
+			Driver: &Driver{
+				volumeNamePrefix:   "csi",
+				publishBatchWindow: -time.Second,
+			},
+			expectError: "Publish batch window cannot be negative",
+		},
+		{
+			Name: "Ensure negative delete volume grace period is rejected",
+			Driver: &Driver{
+				volumeNamePrefix:        "csi",
+				deleteVolumeGracePeriod: -time.Second,
+			},
+			expectError: "Delete volume grace period cannot be negative",
+		},
+		{
+			Name: "Ensure negative clone shutdown timeout is rejected",
+			Driver: &Driver{
+				volumeNamePrefix:     "csi",
+				cloneShutdownTimeout: -time.Second,
+			},
+			expectError: "Clone shutdown timeout cannot be negative",
+		},
+		{
+			Name: "Ensure custom topology key is accepted",
+			Driver: &Driver{
+				volumeNamePrefix: "csi",
+				topologyKey:      "topology.example.com/lxd-member",
+			},
+			expectError: "",
+		},
+		{
+			Name: "Ensure invalid topology key is rejected",
+			Driver: &Driver{
+				volumeNamePrefix: "csi",
+				topologyKey:      "invalid key/with/slashes",
+			},
+			expectError: "is not a valid label key",
+		},
 	}
 
 	for _, test := range tests {
@@ -57,3 +213,129 @@ func TestValidateDriver(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStoragePoolAliases(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Aliases       string
+		expectAliases map[string]string
+		expectError   string
+	}{
+		{
+			Name:          "Ensure empty aliases are accepted",
+			Aliases:       "",
+			expectAliases: map[string]string{},
+		},
+		{
+			Name:          "Ensure multiple aliases are parsed",
+			Aliases:       "old=new, legacy=default",
+			expectAliases: map[string]string{"old": "new", "legacy": "default"},
+		},
+		{
+			Name:        "Ensure alias without separator is rejected",
+			Aliases:     "old",
+			expectError: "Expected format",
+		},
+		{
+			Name:        "Ensure duplicate alias is rejected",
+			Aliases:     "old=new,old=other",
+			expectError: "Duplicate alias",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			aliases, err := ParseStoragePoolAliases(test.Aliases)
+			if test.expectError != "" {
+				require.ErrorContains(t, err, test.expectError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectAliases, aliases)
+		})
+	}
+}
+
+func TestResolvePoolName(t *testing.T) {
+	d := &Driver{
+		storagePoolAliases: map[string]string{"old": "new"},
+	}
+
+	require.Equal(t, "new", d.resolvePoolName("old"))
+	require.Equal(t, "other", d.resolvePoolName("other"))
+}
+
+func TestWaitDevLXDReachable(t *testing.T) {
+	devLXDStartupPollInterval = time.Millisecond
+
+	tests := []struct {
+		Name           string
+		reachableAfter time.Duration
+		timeout        time.Duration
+		expectReady    bool
+	}{
+		{
+			Name:           "Ensure driver is ready once devLXD becomes reachable",
+			reachableAfter: 20 * time.Millisecond,
+			timeout:        10 * time.Second,
+			expectReady:    true,
+		},
+		{
+			Name:           "Ensure waiting fails if devLXD does not become reachable in time",
+			reachableAfter: time.Hour,
+			timeout:        20 * time.Millisecond,
+			expectReady:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				// Connecting fails as long as the token file does not exist.
+				devLXDTokenFile: filepath.Join(t.TempDir(), "token"),
+				startupTimeout:  test.timeout,
+			}
+
+			// Simulate devLXD becoming reachable.
+			timer := time.AfterFunc(test.reachableAfter, func() {
+				d.lock.Lock()
+				d.devLXD = &fakeDevLXDServer{}
+				d.lock.Unlock()
+			})
+
+			defer timer.Stop()
+
+			resp, err := NewIdentityServer(d).Probe(context.Background(), nil)
+			require.NoError(t, err)
+			require.False(t, resp.Ready.Value, "Driver should not be ready before devLXD is reachable")
+
+			err = d.waitDevLXDReachable(context.Background())
+			if test.expectReady {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, "Timed out waiting for devLXD to become reachable")
+			}
+
+			resp, err = NewIdentityServer(d).Probe(context.Background(), nil)
+			require.NoError(t, err)
+			require.Equal(t, test.expectReady, resp.Ready.Value)
+		})
+	}
+}
+
+func TestWaitDevLXDReachableStopped(t *testing.T) {
+	d := &Driver{
+		// Connecting fails as long as the token file does not exist.
+		devLXDTokenFile: filepath.Join(t.TempDir(), "token"),
+		startupTimeout:  time.Hour,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Ensure stopping the driver while waiting is not reported as a timeout.
+	err := d.waitDevLXDReachable(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "Stopped waiting for devLXD to become reachable")
+}
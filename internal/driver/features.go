@@ -0,0 +1,131 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// storageDriverFeatures contains the CSI features available for volumes on
+// a storage pool using a particular LXD storage driver.
+type storageDriverFeatures struct {
+	// Name of the storage driver.
+	Driver string
+
+	// Whether the volumes are accessible from all cluster members.
+	Remote bool
+
+	// Content types of volumes that can be created.
+	ContentTypes []string
+
+	// Whether volume snapshots are supported.
+	Snapshots bool
+
+	// Whether volumes can be cloned.
+	Clone bool
+
+	// Whether volumes can be expanded.
+	Expansion bool
+}
+
+// getStorageDriverFeatures returns the CSI features available for the given
+// storage driver. It returns an error if the driver is not supported by the CSI.
+func getStorageDriverFeatures(state *api.DevLXDGet, driverName string) (*storageDriverFeatures, error) {
+	driver, err := getSupportedStorageDriver(state, driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	contentTypes, ok := storageDriverContentTypes[driver.Name]
+	if !ok {
+		contentTypes = []string{"filesystem", "block"}
+	}
+
+	// All supported drivers support snapshots, cloning, and expansion of
+	// custom volumes. Encryption is not reported, as it is not supported.
+	return &storageDriverFeatures{
+		Driver:       driver.Name,
+		Remote:       driver.Remote,
+		ContentTypes: slices.Clone(contentTypes),
+		Snapshots:    true,
+		Clone:        true,
+		Expansion:    true,
+	}, nil
+}
+
+// ReportStorageFeatures writes the CSI features available for the given
+// storage pools to w. If no storage pools are given, the features are
+// reported for each storage driver supported by the CSI, as devLXD does not
+// allow listing the storage pools.
+func (d *Driver) ReportStorageFeatures(w io.Writer, poolNames []string) error {
+	client, err := d.DevLXDClient()
+	if err != nil {
+		return err
+	}
+
+	state, err := client.GetState()
+	if err != nil {
+		return fmt.Errorf("Failed to get LXD server info: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if len(poolNames) == 0 {
+		_, _ = fmt.Fprintln(tw, "DRIVER\tREMOTE\tCONTENT TYPES\tSNAPSHOTS\tCLONE\tEXPANSION")
+
+		for _, driver := range state.SupportedStorageDrivers {
+			features, err := getStorageDriverFeatures(state, driver.Name)
+			if err != nil {
+				// Skip drivers not supported by the CSI.
+				continue
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%s\n", features.Driver, features.columns())
+		}
+
+		return tw.Flush()
+	}
+
+	_, _ = fmt.Fprintln(tw, "POOL\tDRIVER\tREMOTE\tCONTENT TYPES\tSNAPSHOTS\tCLONE\tEXPANSION")
+
+	for _, poolName := range poolNames {
+		pool, _, err := client.GetStoragePool(d.resolvePoolName(poolName))
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve storage pool %q: %w", poolName, err)
+		}
+
+		features, err := getStorageDriverFeatures(state, pool.Driver)
+		if err != nil {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", poolName, pool.Driver, "unsupported")
+			continue
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", poolName, features.Driver, features.columns())
+	}
+
+	return tw.Flush()
+}
+
+// columns returns the features as tab separated columns.
+func (f *storageDriverFeatures) columns() string {
+	return strings.Join([]string{
+		yesNo(f.Remote),
+		strings.Join(f.ContentTypes, ","),
+		yesNo(f.Snapshots),
+		yesNo(f.Clone),
+		yesNo(f.Expansion),
+	}, "\t")
+}
+
+// yesNo returns "yes" if the given value is true, and "no" otherwise.
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+
+	return "no"
+}
@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestGetStorageDriverFeatures(t *testing.T) {
+	state := &api.DevLXDGet{
+		DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+			SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+				{Name: "zfs", Remote: false},
+				{Name: "ceph", Remote: true},
+				{Name: "cephfs", Remote: true},
+				{Name: "cephobject", Remote: true},
+			},
+		},
+	}
+
+	tests := []struct {
+		Name           string
+		driver         string
+		expectFeatures *storageDriverFeatures
+		expectError    string
+	}{
+		{
+			Name:   "Local driver",
+			driver: "zfs",
+			expectFeatures: &storageDriverFeatures{
+				Driver:       "zfs",
+				ContentTypes: []string{"filesystem", "block"},
+				Snapshots:    true,
+				Clone:        true,
+				Expansion:    true,
+			},
+		},
+		{
+			Name:   "Remote driver",
+			driver: "ceph",
+			expectFeatures: &storageDriverFeatures{
+				Driver:       "ceph",
+				Remote:       true,
+				ContentTypes: []string{"filesystem", "block"},
+				Snapshots:    true,
+				Clone:        true,
+				Expansion:    true,
+			},
+		},
+		{
+			Name:   "Filesystem only driver",
+			driver: "cephfs",
+			expectFeatures: &storageDriverFeatures{
+				Driver:       "cephfs",
+				Remote:       true,
+				ContentTypes: []string{"filesystem"},
+				Snapshots:    true,
+				Clone:        true,
+				Expansion:    true,
+			},
+		},
+		{
+			Name:        "Driver not supported by the CSI",
+			driver:      "cephobject",
+			expectError: `CSI does not support storage driver "cephobject"`,
+		},
+		{
+			Name:        "Driver not supported by LXD",
+			driver:      "btrfs",
+			expectError: `CSI does not support storage driver "btrfs"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			features, err := getStorageDriverFeatures(state, test.driver)
+			if test.expectError != "" {
+				require.ErrorContains(t, err, test.expectError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectFeatures, features)
+		})
+	}
+}
+
+func TestReportStorageFeatures(t *testing.T) {
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "zfs", Remote: false},
+							{Name: "cephfs", Remote: true},
+							{Name: "cephobject", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				switch pool {
+				case "local":
+					return &api.DevLXDStoragePool{Name: pool, Driver: "zfs"}, "", nil
+				case "objects":
+					return &api.DevLXDStoragePool{Name: pool, Driver: "cephobject"}, "", nil
+				}
+
+				return nil, "", api.NewStatusError(http.StatusNotFound, "Storage pool not found")
+			},
+		},
+	}
+
+	// Ensure features are reported per supported driver.
+	var buf bytes.Buffer
+	err := d.ReportStorageFeatures(&buf, nil)
+	require.NoError(t, err)
+	require.Equal(t, `DRIVER  REMOTE  CONTENT TYPES     SNAPSHOTS  CLONE  EXPANSION
+zfs     no      filesystem,block  yes        yes    yes
+cephfs  yes     filesystem        yes        yes    yes
+`, buf.String())
+
+	// Ensure features are reported per storage pool.
+	buf.Reset()
+	err = d.ReportStorageFeatures(&buf, []string{"local", "objects"})
+	require.NoError(t, err)
+	require.Equal(t, `POOL     DRIVER      REMOTE  CONTENT TYPES     SNAPSHOTS  CLONE  EXPANSION
+local    zfs         no      filesystem,block  yes        yes    yes
+objects  cephobject  unsupported
+`, buf.String())
+
+	// Ensure missing storage pool is reported.
+	err = d.ReportStorageFeatures(&buf, []string{"missing"})
+	require.ErrorContains(t, err, `Failed to retrieve storage pool "missing"`)
+}
@@ -0,0 +1,150 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/canonical/lxd-csi-driver/internal/fs"
+)
+
+// volumeHealthCheckInterval is the interval between health checks of the
+// volumes published on the node.
+const volumeHealthCheckInterval = time.Minute
+
+// publishedVolume contains the health of a volume published on the node.
+type publishedVolume struct {
+	volumeID string
+	readOnly bool
+
+	// Reason why the volume is abnormal. Empty if the volume is healthy.
+	abnormalReason string
+}
+
+// volumeHealthMonitor periodically checks the health of the volumes
+// published on the node.
+type volumeHealthMonitor struct {
+	lock    sync.Mutex
+	volumes map[string]*publishedVolume
+
+	// check returns the reason why the volume mounted at the given path
+	// is abnormal, or an empty string if the volume is healthy.
+	// It can be overridden in tests.
+	check func(path string, readOnly bool) string
+}
+
+// newVolumeHealthMonitor returns a new volume health monitor.
+func newVolumeHealthMonitor() *volumeHealthMonitor {
+	return &volumeHealthMonitor{
+		volumes: make(map[string]*publishedVolume),
+		check:   checkVolumeHealth,
+	}
+}
+
+// Track starts monitoring the volume published at the given target path.
+func (m *volumeHealthMonitor) Track(targetPath string, volumeID string, readOnly bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.volumes[targetPath] = &publishedVolume{
+		volumeID: volumeID,
+		readOnly: readOnly,
+	}
+}
+
+// Untrack stops monitoring the volume published at the given target path.
+func (m *volumeHealthMonitor) Untrack(targetPath string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.volumes, targetPath)
+}
+
+// AbnormalReason returns the reason why the volume published at the given
+// target path is abnormal. The second return value is false if the volume
+// is not monitored.
+func (m *volumeHealthMonitor) AbnormalReason(targetPath string) (string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	vol, ok := m.volumes[targetPath]
+	if !ok {
+		return "", false
+	}
+
+	return vol.abnormalReason, true
+}
+
+// CheckAll checks the health of all monitored volumes.
+func (m *volumeHealthMonitor) CheckAll() {
+	m.lock.Lock()
+	targets := make(map[string]bool, len(m.volumes))
+	for targetPath, vol := range m.volumes {
+		targets[targetPath] = vol.readOnly
+	}
+
+	m.lock.Unlock()
+
+	// Check volumes without holding the lock, as the checks may block.
+	for targetPath, readOnly := range targets {
+		reason := m.check(targetPath, readOnly)
+
+		m.lock.Lock()
+		vol, ok := m.volumes[targetPath]
+		if ok {
+			if reason != "" && vol.abnormalReason == "" {
+				klog.InfoS("Volume has become abnormal", "volumeID", vol.volumeID, "targetPath", targetPath, "reason", reason)
+			}
+
+			vol.abnormalReason = reason
+		}
+
+		m.lock.Unlock()
+	}
+}
+
+// Run periodically checks the health of the monitored volumes until the
+// context is cancelled.
+func (m *volumeHealthMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.CheckAll()
+		}
+	}
+}
+
+// checkVolumeHealth returns the reason why the volume mounted at the given
+// path is abnormal, or an empty string if the volume is healthy.
+func checkVolumeHealth(path string, readOnly bool) string {
+	if !fs.PathExists(path) {
+		return "Volume path does not exist"
+	}
+
+	mounted, err := fs.IsMountPoint(path)
+	if err != nil {
+		return "Failed to check volume mount: " + err.Error()
+	}
+
+	if !mounted {
+		return "Volume is not mounted"
+	}
+
+	stats, err := fs.GetVolumeStats(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	if stats.ReadOnly && !readOnly {
+		return "Volume mount has become read-only"
+	}
+
+	return ""
+}
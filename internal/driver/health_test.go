@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeHealthMonitorReadOnly(t *testing.T) {
+	targetPath := t.TempDir()
+	readOnly := false
+
+	node := NewNodeServer(&Driver{})
+
+	// Simulate a mount that becomes read-only.
+	node.volumeHealth.check = func(path string, requestedReadOnly bool) string {
+		if readOnly && !requestedReadOnly {
+			return "Volume mount has become read-only"
+		}
+
+		return ""
+	}
+
+	node.volumeHealth.Track(targetPath, "remote/pvc-volume-name", false)
+
+	getCondition := func() *csi.VolumeCondition {
+		resp, err := node.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+			VolumeId:   "remote/pvc-volume-name",
+			VolumePath: targetPath,
+		})
+
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.Usage)
+		return resp.VolumeCondition
+	}
+
+	// Ensure the volume is reported healthy initially.
+	node.volumeHealth.CheckAll()
+	require.False(t, getCondition().Abnormal)
+
+	// Ensure the volume is reported abnormal once it becomes read-only.
+	readOnly = true
+	node.volumeHealth.CheckAll()
+
+	condition := getCondition()
+	require.True(t, condition.Abnormal)
+	require.Equal(t, "Volume mount has become read-only", condition.Message)
+
+	// Ensure volumes that are published read-only are not reported abnormal.
+	node.volumeHealth.Track(targetPath, "remote/pvc-volume-name", true)
+	node.volumeHealth.CheckAll()
+	require.False(t, getCondition().Abnormal)
+
+	// Ensure the volume is no longer monitored once unpublished.
+	node.volumeHealth.Untrack(targetPath)
+	_, ok := node.volumeHealth.AbnormalReason(targetPath)
+	require.False(t, ok)
+}
+
+func TestNodeGetVolumeStatsUntrackedReadOnly(t *testing.T) {
+	targetPath := t.TempDir()
+
+	node := NewNodeServer(&Driver{})
+
+	// Simulate a read-only mount.
+	node.volumeHealth.check = func(path string, requestedReadOnly bool) string {
+		if !requestedReadOnly {
+			return "Volume mount has become read-only"
+		}
+
+		return ""
+	}
+
+	// Ensure a read-only volume that is not monitored, for example after
+	// the node plugin restarts, is not reported abnormal.
+	resp, err := node.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+		VolumeId:   "remote/pvc-volume-name",
+		VolumePath: targetPath,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.VolumeCondition.Abnormal)
+}
+
+func TestNodeGetVolumeStatsInvalidRequest(t *testing.T) {
+	node := NewNodeServer(&Driver{})
+
+	_, err := node.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+		VolumeId:   "remote/pvc-volume-name",
+		VolumePath: t.TempDir() + "/missing",
+	})
+	require.ErrorContains(t, err, "not found")
+
+	_, err = node.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+		VolumeId: "remote/pvc-volume-name",
+	})
+	require.ErrorContains(t, err, "Volume path not provided")
+}
@@ -7,6 +7,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/klog/v2"
 )
 
 type identityServer struct {
@@ -68,12 +69,27 @@ func (i *identityServer) GetPluginCapabilities(ctx context.Context, req *csi.Get
 	}, nil
 }
 
-// Probe reports plugin readiness. Always returns ready=true, since if the driver
-// were not ready the gRPC server would not have started.
+// Probe reports plugin readiness. The plugin is not ready while it waits on
+// startup for devLXD to become reachable. If devLXD does not become reachable
+// in time, the driver exits and the probe fails.
+//
+// Once started, the plugin is ready only while LXD is reachable, so that the
+// liveness probe restarts the driver if the connection to devLXD is broken.
 func (i *identityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	return &csi.ProbeResponse{
-		Ready: &wrapperspb.BoolValue{
-			Value: true,
-		},
-	}, nil
+	if !i.driver.ready.Load() {
+		return &csi.ProbeResponse{Ready: wrapperspb.Bool(false)}, nil
+	}
+
+	client, err := i.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Probe: %v", err)
+	}
+
+	_, err = client.GetState()
+	if err != nil {
+		klog.ErrorS(err, "Probe: LXD is unreachable")
+		return &csi.ProbeResponse{Ready: wrapperspb.Bool(false)}, nil
+	}
+
+	return &csi.ProbeResponse{Ready: wrapperspb.Bool(true)}, nil
 }
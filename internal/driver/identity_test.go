@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestIdentityGetPluginInfo(t *testing.T) {
+	tests := []struct {
+		Name       string
+		driverName string
+		expectName string
+	}{
+		{
+			Name:       "Ensure default driver name is reported",
+			driverName: DefaultDriverName,
+			expectName: "lxd.csi.canonical.com",
+		},
+		{
+			Name:       "Ensure configured driver name is reported",
+			driverName: "canary.lxd.csi.canonical.com",
+			expectName: "canary.lxd.csi.canonical.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := NewDriver(DriverOptions{
+				Name:             test.driverName,
+				VolumeNamePrefix: DefaultVolumeNamePrefix,
+			})
+
+			require.NoError(t, d.Validate())
+
+			resp, err := NewIdentityServer(d).GetPluginInfo(context.Background(), &csi.GetPluginInfoRequest{})
+			require.NoError(t, err)
+			require.Equal(t, test.expectName, resp.Name)
+		})
+	}
+}
+
+func TestIdentityProbe(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Started     bool
+		StateErr    error
+		expectReady bool
+	}{
+		{
+			Name:        "Ensure driver is not ready before startup completes",
+			Started:     false,
+			expectReady: false,
+		},
+		{
+			Name:        "Ensure driver is ready when LXD is reachable",
+			Started:     true,
+			expectReady: true,
+		},
+		{
+			Name:        "Ensure driver is not ready when LXD is unreachable",
+			Started:     true,
+			StateErr:    errors.New("Connection refused"),
+			expectReady: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name: "lxd.csi.canonical.com",
+				devLXD: &fakeDevLXDServer{
+					getStateFunc: func() (*api.DevLXDGet, error) {
+						return &api.DevLXDGet{}, test.StateErr
+					},
+				},
+			}
+
+			d.ready.Store(test.Started)
+
+			resp, err := NewIdentityServer(d).Probe(context.Background(), &csi.ProbeRequest{})
+			require.NoError(t, err)
+			require.Equal(t, test.expectReady, resp.Ready.GetValue())
+		})
+	}
+}
+
+func TestIdentityProbeClientFailure(t *testing.T) {
+	d := &Driver{
+		name:            "lxd.csi.canonical.com",
+		devLXDTokenFile: filepath.Join(t.TempDir(), "missing-token"),
+	}
+
+	d.ready.Store(true)
+
+	// Ensure the probe fails if the devLXD client cannot be constructed.
+	_, err := NewIdentityServer(d).Probe(context.Background(), &csi.ProbeRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err), "Unexpected error: %v", err)
+}
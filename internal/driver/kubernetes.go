@@ -0,0 +1,143 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// kubernetesClient returns a Kubernetes client using the in-cluster
+// configuration. The client is created once and shared.
+var kubernetesClient = sync.OnceValues(func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load in-cluster Kubernetes configuration: %w", err)
+	}
+
+	return kubernetes.NewForConfig(config)
+})
+
+// nodeLabelsFunc returns the labels of the given Kubernetes node.
+type nodeLabelsFunc func(ctx context.Context, name string) (map[string]string, error)
+
+// getNodeLabels retrieves the labels of the given node from the Kubernetes
+// API. If the driver is not running in a Kubernetes cluster, no labels are
+// returned.
+func getNodeLabels(ctx context.Context, name string) (map[string]string, error) {
+	client, err := kubernetesClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping retrieval of node labels", "node", name)
+		return nil, nil
+	}
+
+	node, err := client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve node %q: %w", name, err)
+	}
+
+	return node.Labels, nil
+}
+
+// pvcMetadataFunc returns the annotations and the labels of the given PVC.
+type pvcMetadataFunc func(ctx context.Context, namespace string, name string) (annotations map[string]string, labels map[string]string, err error)
+
+// getPVCMetadata retrieves the annotations and the labels of the given PVC
+// from the Kubernetes API. If the driver is not running in a Kubernetes
+// cluster, neither are returned, as there is no PVC to retrieve them from.
+func getPVCMetadata(ctx context.Context, namespace string, name string) (annotations map[string]string, labels map[string]string, err error) {
+	client, err := kubernetesClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping retrieval of PVC metadata", "pvc", namespace+"/"+name)
+		return nil, nil, nil
+	}
+
+	pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to retrieve PVC %q: %w", namespace+"/"+name, err)
+	}
+
+	return pvc.Annotations, pvc.Labels, nil
+}
+
+// persistentVolumeIDsFunc returns the volume IDs of the persistent volumes
+// provisioned by the given CSI driver.
+type persistentVolumeIDsFunc func(ctx context.Context, driverName string) ([]string, error)
+
+// getPersistentVolumeIDs retrieves the volume IDs of the persistent volumes
+// of the given CSI driver from the Kubernetes API. If the driver is not
+// running in a Kubernetes cluster, no volume IDs are returned.
+func getPersistentVolumeIDs(ctx context.Context, driverName string) ([]string, error) {
+	client, err := kubernetesClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping retrieval of persistent volumes")
+		return nil, nil
+	}
+
+	pvs, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list persistent volumes: %w", err)
+	}
+
+	var volumeIDs []string
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == driverName {
+			volumeIDs = append(volumeIDs, pv.Spec.CSI.VolumeHandle)
+		}
+	}
+
+	return volumeIDs, nil
+}
+
+// volumeAttachmentsFunc returns the nodes the persistent volumes of the given
+// CSI driver are attached to, keyed by the volume ID.
+type volumeAttachmentsFunc func(ctx context.Context, driverName string) (map[string]string, error)
+
+// getVolumeAttachments retrieves the attached volume attachments of the given
+// CSI driver from the Kubernetes API, and returns the node of each attached
+// persistent volume keyed by its volume ID. The node ID of the driver is the
+// name of the Kubernetes node. If the driver is not running in a Kubernetes
+// cluster, no attachments are returned.
+func getVolumeAttachments(ctx context.Context, driverName string) (map[string]string, error) {
+	client, err := kubernetesClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping retrieval of volume attachments")
+		return nil, nil
+	}
+
+	vas, err := client.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list volume attachments: %w", err)
+	}
+
+	pvs, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list persistent volumes: %w", err)
+	}
+
+	volumeIDs := make(map[string]string)
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == driverName {
+			volumeIDs[pv.Name] = pv.Spec.CSI.VolumeHandle
+		}
+	}
+
+	attachments := make(map[string]string)
+	for _, va := range vas.Items {
+		pvName := va.Spec.Source.PersistentVolumeName
+		if va.Spec.Attacher != driverName || !va.Status.Attached || pvName == nil {
+			continue
+		}
+
+		volumeID, ok := volumeIDs[*pvName]
+		if ok {
+			attachments[volumeID] = va.Spec.NodeName
+		}
+	}
+
+	return attachments, nil
+}
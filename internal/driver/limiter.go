@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// poolLimiter limits the number of concurrent operations per storage pool.
+// Each storage pool has its own set of operation slots, which ensures that
+// a busy storage pool cannot starve operations on other storage pools.
+type poolLimiter struct {
+	// Maximum number of concurrent operations per storage pool.
+	// Zero or negative value disables the limit.
+	limit int
+
+	// Operation slots per storage pool.
+	slots map[string]chan struct{}
+
+	lock sync.Mutex
+}
+
+// newPoolLimiter returns a new storage pool operation limiter that allows
+// up to limit concurrent operations on each storage pool.
+func newPoolLimiter(limit int) *poolLimiter {
+	return &poolLimiter{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// poolSlots returns the operation slots of the given storage pool.
+// Slots are created on first use.
+func (l *poolLimiter) poolSlots(poolName string) chan struct{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	slots, ok := l.slots[poolName]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.slots[poolName] = slots
+	}
+
+	return slots
+}
+
+// Acquire blocks until an operation slot for the given storage pool becomes
+// available or the context is done. On success, it returns a function that
+// releases the acquired slot.
+//
+// The per-pool slot must always be acquired after the per-volume lock is
+// obtained. The volume lock is never awaited, so holding it while waiting
+// for a pool slot cannot result in a deadlock.
+func (l *poolLimiter) Acquire(ctx context.Context, poolName string) (release func(), err error) {
+	if l == nil || l.limit <= 0 {
+		return func() {}, nil
+	}
+
+	slots := l.poolSlots(poolName)
+
+	release = func() {
+		<-slots
+		poolOperationsInFlight.Add(-1, poolName)
+	}
+
+	// Acquire a free slot without waiting if possible.
+	select {
+	case slots <- struct{}{}:
+		poolOperationsInFlight.Add(1, poolName)
+		return release, nil
+	default:
+	}
+
+	// Record the time spent waiting for a slot, which indicates that
+	// the storage pool is saturated.
+	poolOperationsWaiting.Add(1, poolName)
+	operationsWaiting.Add(1)
+	start := time.Now()
+
+	defer func() {
+		poolOperationsWaiting.Add(-1, poolName)
+		operationsWaiting.Add(-1)
+		poolOperationWaitSecondsTotal.Add(time.Since(start).Seconds(), poolName)
+		poolOperationWaitsTotal.Inc(poolName)
+	}()
+
+	select {
+	case slots <- struct{}{}:
+		poolOperationsInFlight.Add(1, poolName)
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InFlight returns the number of in-flight operations on the given storage pool.
+func (l *poolLimiter) InFlight(poolName string) int {
+	if l == nil || l.limit <= 0 {
+		return 0
+	}
+
+	return len(l.poolSlots(poolName))
+}
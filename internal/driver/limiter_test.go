@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolLimiter(t *testing.T) {
+	l := newPoolLimiter(2)
+
+	// Fill all slots of pool "a".
+	releaseA1, err := l.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	releaseA2, err := l.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+	require.Equal(t, 2, l.InFlight("a"))
+
+	// Operations on pool "b" must not be blocked by pool "a".
+	releaseB, err := l.Acquire(context.Background(), "b")
+	require.NoError(t, err)
+	require.Equal(t, 1, l.InFlight("b"))
+	releaseB()
+	require.Equal(t, 0, l.InFlight("b"))
+
+	// Acquiring another slot on pool "a" waits until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = l.Acquire(ctx, "a")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Releasing a slot allows a waiting operation to proceed.
+	acquired := make(chan struct{})
+	go func() {
+		release, err := l.Acquire(context.Background(), "a")
+		if err == nil {
+			release()
+		}
+
+		close(acquired)
+	}()
+
+	releaseA1()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the operation slot")
+	}
+
+	releaseA2()
+	require.Equal(t, 0, l.InFlight("a"))
+}
+
+func TestPoolLimiterUnlimited(t *testing.T) {
+	for _, l := range []*poolLimiter{nil, newPoolLimiter(0)} {
+		for range 10 {
+			_, err := l.Acquire(context.Background(), "a")
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, 0, l.InFlight("a"))
+	}
+}
+
+func TestPoolLimiterMetrics(t *testing.T) {
+	// Use a unique pool name, as metrics are shared between tests.
+	poolName := "limiter-metrics"
+	l := newPoolLimiter(1)
+
+	release, err := l.Acquire(context.Background(), poolName)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), poolOperationsInFlight.Value(poolName))
+
+	// Ensure acquiring a free slot is not recorded as waiting.
+	require.Equal(t, float64(0), poolOperationWaitsTotal.Value(poolName))
+
+	// Ensure the waiting operation is reported while the limiter is full.
+	acquired := make(chan struct{})
+	go func() {
+		release, err := l.Acquire(context.Background(), poolName)
+		if err == nil {
+			release()
+		}
+
+		close(acquired)
+	}()
+
+	require.Eventually(t, func() bool {
+		return poolOperationsWaiting.Value(poolName) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	release()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the operation slot")
+	}
+
+	// Ensure the wait time is recorded once the slot is acquired.
+	require.Equal(t, float64(0), poolOperationsWaiting.Value(poolName))
+	require.Equal(t, float64(0), poolOperationsInFlight.Value(poolName))
+	require.Equal(t, float64(1), poolOperationWaitsTotal.Value(poolName))
+	require.GreaterOrEqual(t, poolOperationWaitSecondsTotal.Value(poolName), 0.02)
+}
@@ -0,0 +1,266 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/canonical/lxd-csi-driver/internal/lxderrors"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/units"
+)
+
+// ListVolumes lists the volumes managed by the driver in the storage pools
+// known to contain volumes of the driver. These are the storage pools of the
+// storage classes and persistent volumes of the driver, and the storage pools
+// the driver created volumes in. The devLXD API cannot list storage pools, so
+// volumes in other storage pools are not listed.
+//
+// The starting token is the offset of the first entry to return.
+//
+// The devLXD API cannot list the instances a volume is attached to, therefore
+// the published node of a volume is the node recorded in its configuration
+// key [VolumeConfigAttachedTo], once confirmed to still have it attached.
+// The records of volumes attached before attachments were recorded are
+// backfilled from the Kubernetes volume attachments on controller startup.
+func (c *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+	if req.MaxEntries < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "ListVolumes: Max entries must not be negative: %d", req.MaxEntries)
+	}
+
+	start := 0
+	if req.StartingToken != "" {
+		var err error
+		start, err = strconv.Atoi(req.StartingToken)
+		if err != nil || start < 0 {
+			return nil, status.Errorf(codes.Aborted, "ListVolumes: Invalid starting token %q", req.StartingToken)
+		}
+	} else {
+		// Discover the storage pools of new storage classes and persistent
+		// volumes when the listing starts, but keep the pools unchanged
+		// while paginating.
+		c.discoverVolumePools(ctx)
+	}
+
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ListVolumes: %v", err)
+	}
+
+	var entries []*csi.ListVolumesResponse_Entry
+
+	// Nodes recorded as having the listed volumes attached, keyed by the
+	// volume ID.
+	attachedTo := make(map[string]string)
+
+	for _, poolName := range c.volumePools.List() {
+		vols, err := client.GetStoragePoolVolumes(poolName)
+		if err != nil {
+			// Skip storage pools that no longer exist.
+			if api.StatusErrorCheck(err, http.StatusNotFound) {
+				klog.InfoS("ListVolumes: Skipping storage pool that no longer exists", "pool", poolName)
+				continue
+			}
+
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ListVolumes: Failed to retrieve storage volumes from pool %q: %v", poolName, err)
+		}
+
+		for _, vol := range vols {
+			// Soft-deleted volumes are already deleted from the point of
+			// view of the container orchestrator.
+			_, softDeleted := softDeleteExpiry(&vol)
+			if vol.Type != "custom" || !isManagedVolume(&vol) || softDeleted {
+				continue
+			}
+
+			volume := c.csiVolume(poolName, &vol)
+			attachedTo[volume.VolumeId] = vol.Config[VolumeConfigAttachedTo]
+			entries = append(entries, &csi.ListVolumesResponse_Entry{Volume: volume})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b *csi.ListVolumesResponse_Entry) int {
+		return strings.Compare(a.Volume.VolumeId, b.Volume.VolumeId)
+	})
+
+	if start > len(entries) {
+		return nil, status.Errorf(codes.Aborted, "ListVolumes: Starting token %q exceeds the number of volumes %d", req.StartingToken, len(entries))
+	}
+
+	end := len(entries)
+	if req.MaxEntries > 0 && start+int(req.MaxEntries) < end {
+		end = start + int(req.MaxEntries)
+	}
+
+	resp := &csi.ListVolumesResponse{
+		Entries: entries[start:end],
+	}
+
+	// Report the published nodes of the returned volumes only, as each
+	// recorded attachment is confirmed with a separate request.
+	for _, entry := range resp.Entries {
+		entry.Status = &csi.ListVolumesResponse_VolumeStatus{}
+
+		nodeID := attachedTo[entry.Volume.VolumeId]
+		if nodeID == "" {
+			continue
+		}
+
+		_, poolName, volName, err := splitVolumeID(entry.Volume.VolumeId)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "ListVolumes: %v", err)
+		}
+
+		attached, err := isAttachedTo(client, nodeID, poolName, volName)
+		if err != nil {
+			return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ListVolumes: Failed to check attachment of volume %q to node %q: %v", volName, nodeID, err)
+		}
+
+		if attached {
+			entry.Status.PublishedNodeIds = []string{nodeID}
+		}
+	}
+
+	if end < len(entries) {
+		resp.NextToken = strconv.Itoa(end)
+	}
+
+	return resp, nil
+}
+
+// volumeTarget returns the cluster member the given LXD volume is located on.
+// It returns an empty string if LXD is not clustered or if the volume is in a
+// remote storage pool, and thus not bound to any cluster member.
+func (c *controllerServer) volumeTarget(vol *api.DevLXDStorageVolume) string {
+	if !c.driver.isClustered || vol.Location == "none" {
+		return ""
+	}
+
+	return vol.Location
+}
+
+// discoverVolumePools adds the storage pools of the storage classes and the
+// persistent volumes of the driver to the storage pools listed by ListVolumes.
+// The persistent volumes reveal the storage pools of existing volumes whose
+// storage class no longer exists or refers to a different storage pool.
+func (c *controllerServer) discoverVolumePools(ctx context.Context) {
+	c.trackStorageClassPools(ctx, c.volumePools)
+
+	volumeIDs, err := c.persistentVolumeIDs(ctx, c.driver.name)
+	if err != nil {
+		klog.ErrorS(err, "Skipping discovery of storage pools of persistent volumes")
+		return
+	}
+
+	for _, volumeID := range volumeIDs {
+		_, poolName, _, err := splitVolumeID(volumeID)
+		if err != nil {
+			klog.ErrorS(err, "Skipping storage pool of persistent volume with invalid volume ID", "volumeID", volumeID)
+			continue
+		}
+
+		c.volumePools.Track(c.driver.resolvePoolName(poolName))
+	}
+}
+
+// csiVolume returns the CSI volume of the given LXD volume. Volumes located
+// on a cluster member are reported with the cluster member in their volume
+// ID and topology, as done when the volume is created.
+func (c *controllerServer) csiVolume(poolName string, vol *api.DevLXDStorageVolume) *csi.Volume {
+	target := c.volumeTarget(vol)
+
+	// The size is unknown if not set on the volume.
+	sizeBytes, _ := units.ParseByteSizeString(vol.Config["size"])
+
+	volume := &csi.Volume{
+		VolumeId:      getVolumeID(target, poolName, vol.Name),
+		CapacityBytes: sizeBytes,
+	}
+
+	if target != "" {
+		volume.AccessibleTopology = []*csi.Topology{
+			{
+				Segments: map[string]string{
+					c.driver.topologyKey: target,
+				},
+			},
+		}
+	}
+
+	return volume
+}
+
+// ControllerGetVolume reports the capacity and the condition of the given
+// volume. A volume that no longer exists in LXD, is marked as deleted, or
+// whose size cannot be read is reported as abnormal rather than failing the
+// request, so that the external health monitor can flag it.
+func (c *controllerServer) ControllerGetVolume(_ context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerGetVolume: %v", err)
+	}
+
+	target, poolName, volName, err := splitVolumeID(req.VolumeId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "ControllerGetVolume: %v", err)
+	}
+
+	poolName = c.driver.resolvePoolName(poolName)
+
+	// Set target if provided and LXD is clustered.
+	if target != "" && c.driver.isClustered {
+		client = client.UseTarget(target)
+	}
+
+	abnormal := func(format string, args ...any) *csi.ControllerGetVolumeResponse {
+		return &csi.ControllerGetVolumeResponse{
+			Volume: &csi.Volume{VolumeId: req.VolumeId},
+			Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+				VolumeCondition: &csi.VolumeCondition{
+					Abnormal: true,
+					Message:  fmt.Sprintf(format, args...),
+				},
+			},
+		}
+	}
+
+	vol, _, err := client.GetStoragePoolVolume(poolName, "custom", volName)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return abnormal("Volume %q not found in storage pool %q", volName, poolName), nil
+		}
+
+		return nil, status.Errorf(lxderrors.ToGRPCCode(err), "ControllerGetVolume: Failed to retrieve volume %q from storage pool %q: %v", volName, poolName, err)
+	}
+
+	_, softDeleted := softDeleteExpiry(vol)
+	if softDeleted {
+		return abnormal("Volume %q in storage pool %q is marked as deleted", volName, poolName), nil
+	}
+
+	_, err = units.ParseByteSizeString(vol.Config["size"])
+	if err != nil {
+		return abnormal("Failed to read size %q of volume %q in storage pool %q: %v", vol.Config["size"], volName, poolName, err), nil
+	}
+
+	volume := c.csiVolume(poolName, vol)
+	volume.VolumeId = req.VolumeId
+
+	return &csi.ControllerGetVolumeResponse{
+		Volume: volume,
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			VolumeCondition: &csi.VolumeCondition{
+				Abnormal: false,
+				Message:  "Volume is healthy",
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,242 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestControllerListVolumes(t *testing.T) {
+	managed := map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, "size": "1GiB"}
+
+	vols := map[string][]api.DevLXDStorageVolume{
+		"remote": {
+			{Name: "vol-b", Type: "custom", Config: managed},
+			{Name: "vol-a", Type: "custom", Description: volumeDescriptionPrefix + " default/pvc", Config: map[string]string{"size": "2GiB"}},
+			{Name: "unmanaged", Type: "custom", Config: map[string]string{"size": "1GiB"}},
+			{Name: "deleted", Type: "custom", Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, VolumeConfigDeleteAfter: "2099-01-01T00:00:00Z"}},
+			{Name: "instance", Type: "container", Config: managed},
+		},
+		"local": {
+			{Name: "vol-c", Type: "custom", Location: "member-1", Config: managed},
+		},
+		"archive": {
+			{Name: "vol-d", Type: "custom", Config: managed},
+		},
+	}
+
+	d := &Driver{
+		name:        "lxd.csi.canonical.com",
+		nodeID:      "test-node",
+		isClustered: true,
+		topologyKey: "lxd.csi.canonical.com/node",
+		storagePoolAliases: map[string]string{
+			"old-archive": "archive",
+		},
+		devLXD: &fakeDevLXDServer{
+			getVolsFunc: func(pool string) ([]api.DevLXDStorageVolume, error) {
+				if pool == "removed" {
+					return nil, api.StatusErrorf(http.StatusNotFound, "Storage pool not found")
+				}
+
+				return vols[pool], nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+	controller.volumePools.Track("remote")
+	controller.volumePools.Track("local")
+	controller.volumePools.Track("removed")
+
+	// The storage pool "archive" is not referenced by any storage class,
+	// and is discovered only through the persistent volume in it.
+	controller.persistentVolumeIDs = func(ctx context.Context, driverName string) ([]string, error) {
+		require.Equal(t, d.name, driverName)
+		return []string{"old-archive/vol-d", "remote/vol-b"}, nil
+	}
+
+	// Ensure only managed custom volumes are listed, sorted by volume ID.
+	resp, err := controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
+	require.NoError(t, err)
+	require.Empty(t, resp.NextToken)
+	require.Len(t, resp.Entries, 4)
+	require.Equal(t, "archive/vol-d", resp.Entries[0].Volume.VolumeId)
+	require.Equal(t, "member-1:local/vol-c", resp.Entries[1].Volume.VolumeId)
+	require.Equal(t, "member-1", resp.Entries[1].Volume.AccessibleTopology[0].Segments[d.topologyKey])
+	require.Equal(t, "remote/vol-a", resp.Entries[2].Volume.VolumeId)
+	require.Equal(t, int64(2*1024*1024*1024), resp.Entries[2].Volume.CapacityBytes)
+	require.Empty(t, resp.Entries[2].Volume.AccessibleTopology)
+	require.Equal(t, "remote/vol-b", resp.Entries[3].Volume.VolumeId)
+
+	// Ensure the soft deletion sweeper does not share the listed pools.
+	require.Empty(t, controller.softDeletePools.List())
+
+	// Ensure the listing is paginated.
+	resp, err = controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{MaxEntries: 3})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 3)
+	require.Equal(t, "3", resp.NextToken)
+
+	resp, err = controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{MaxEntries: 3, StartingToken: resp.NextToken})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+	require.Equal(t, "remote/vol-b", resp.Entries[0].Volume.VolumeId)
+	require.Empty(t, resp.NextToken)
+
+	// Ensure invalid starting tokens are rejected.
+	for _, token := range []string{"invalid", "-1", "5"} {
+		_, err = controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{StartingToken: token})
+		require.Equal(t, codes.Aborted, status.Code(err), "Unexpected error for token %q: %v", token, err)
+	}
+
+	// Ensure negative max entries are rejected.
+	_, err = controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{MaxEntries: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestControllerListVolumesPublishedNodes(t *testing.T) {
+	attached := func(nodeID string) map[string]string {
+		return map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, VolumeConfigAttachedTo: nodeID}
+	}
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolsFunc: func(pool string) ([]api.DevLXDStorageVolume, error) {
+				return []api.DevLXDStorageVolume{
+					{Name: "vol-a", Type: "custom", Config: attached("node-1")},
+					{Name: "vol-b", Type: "custom", Config: attached("node-2")},
+					{Name: "vol-c", Type: "custom", Config: attached("removed")},
+					{Name: "vol-d", Type: "custom", Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue}},
+				}, nil
+			},
+			getInstFunc: func(name string) (*api.DevLXDInstance, string, error) {
+				switch name {
+				case "node-1":
+					return &api.DevLXDInstance{
+						Name: name,
+						Devices: map[string]map[string]string{
+							"vol-a": {"type": "disk", "source": "vol-a", "pool": "remote"},
+						},
+					}, "", nil
+				case "node-2":
+					return &api.DevLXDInstance{Name: name}, "", nil
+				default:
+					return nil, "", api.StatusErrorf(http.StatusNotFound, "Instance not found")
+				}
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+	controller.volumePools.Track("remote")
+	controller.persistentVolumeIDs = func(ctx context.Context, driverName string) ([]string, error) {
+		return nil, nil
+	}
+
+	// Ensure only the recorded attachments that are still present are
+	// reported as published nodes.
+	resp, err := controller.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 4)
+	require.Equal(t, []string{"node-1"}, resp.Entries[0].Status.PublishedNodeIds)
+	require.Empty(t, resp.Entries[1].Status.PublishedNodeIds)
+	require.Empty(t, resp.Entries[2].Status.PublishedNodeIds)
+	require.Empty(t, resp.Entries[3].Status.PublishedNodeIds)
+}
+
+func TestControllerGetVolume(t *testing.T) {
+	tests := []struct {
+		Name            string
+		Volume          *api.DevLXDStorageVolume
+		GetErr          error
+		expectCode      codes.Code
+		expectAbnormal  bool
+		expectMessage   string
+		expectCapacity  int64
+		expectTopology  string
+		expectClustered bool
+	}{
+		{
+			Name:           "Ensure healthy volume is reported with its capacity",
+			Volume:         &api.DevLXDStorageVolume{Name: "vol-1", Config: map[string]string{"size": "1GiB"}},
+			expectMessage:  "Volume is healthy",
+			expectCapacity: 1024 * 1024 * 1024,
+		},
+		{
+			Name:            "Ensure topology of local volume is reported",
+			Volume:          &api.DevLXDStorageVolume{Name: "vol-1", Location: "member-1", Config: map[string]string{"size": "1GiB"}},
+			expectMessage:   "Volume is healthy",
+			expectCapacity:  1024 * 1024 * 1024,
+			expectTopology:  "member-1",
+			expectClustered: true,
+		},
+		{
+			Name:           "Ensure missing volume is reported as abnormal",
+			GetErr:         api.StatusErrorf(http.StatusNotFound, "Storage volume not found"),
+			expectAbnormal: true,
+			expectMessage:  `Volume "vol-1" not found in storage pool "remote"`,
+		},
+		{
+			Name:           "Ensure volume marked as deleted is reported as abnormal",
+			Volume:         &api.DevLXDStorageVolume{Name: "vol-1", Config: map[string]string{"size": "1GiB", VolumeConfigDeleteAfter: "2099-01-01T00:00:00Z"}},
+			expectAbnormal: true,
+			expectMessage:  `Volume "vol-1" in storage pool "remote" is marked as deleted`,
+		},
+		{
+			Name:           "Ensure volume with unreadable size is reported as abnormal",
+			Volume:         &api.DevLXDStorageVolume{Name: "vol-1", Config: map[string]string{"size": "invalid"}},
+			expectAbnormal: true,
+			expectMessage:  `Failed to read size "invalid" of volume "vol-1" in storage pool "remote"`,
+		},
+		{
+			Name:       "Ensure LXD errors are returned",
+			GetErr:     api.StatusErrorf(http.StatusInternalServerError, "Internal error"),
+			expectCode: codes.Internal,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			d := &Driver{
+				name:        "lxd.csi.canonical.com",
+				nodeID:      "test-node",
+				isClustered: test.expectClustered,
+				topologyKey: "lxd.csi.canonical.com/node",
+				devLXD: &fakeDevLXDServer{
+					getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+						require.Equal(t, "remote", pool)
+						require.Equal(t, "vol-1", name)
+						return test.Volume, "", test.GetErr
+					},
+				},
+			}
+
+			controller := NewControllerServer(d)
+
+			resp, err := controller.ControllerGetVolume(context.Background(), &csi.ControllerGetVolumeRequest{VolumeId: "remote/vol-1"})
+			require.Equal(t, test.expectCode, status.Code(err), "Unexpected error: %v", err)
+			if test.expectCode != codes.OK {
+				return
+			}
+
+			require.Equal(t, "remote/vol-1", resp.Volume.VolumeId)
+			require.Equal(t, test.expectCapacity, resp.Volume.CapacityBytes)
+			require.Equal(t, test.expectAbnormal, resp.Status.VolumeCondition.Abnormal)
+			require.Contains(t, resp.Status.VolumeCondition.Message, test.expectMessage)
+
+			if test.expectTopology != "" {
+				require.Equal(t, test.expectTopology, resp.Volume.AccessibleTopology[0].Segments[d.topologyKey])
+			}
+		})
+	}
+}
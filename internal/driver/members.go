@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"sync"
+	"time"
+)
+
+// memberHealthCacheTTL is the duration for which the health of an LXD
+// cluster member is cached.
+const memberHealthCacheTTL = 30 * time.Second
+
+// memberHealth contains the cached health of an LXD cluster member.
+type memberHealth struct {
+	online    bool
+	checkedAt time.Time
+}
+
+// memberHealthCache caches the health of LXD cluster members to avoid
+// probing the member on every request.
+type memberHealthCache struct {
+	ttl     time.Duration
+	members map[string]memberHealth
+	lock    sync.Mutex
+
+	// now returns the current time. It can be overridden in tests.
+	now func() time.Time
+}
+
+// newMemberHealthCache returns a new cluster member health cache with the given TTL.
+func newMemberHealthCache(ttl time.Duration) *memberHealthCache {
+	return &memberHealthCache{
+		ttl:     ttl,
+		members: make(map[string]memberHealth),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached health of the given cluster member. The second return
+// value is false if the member health is not cached or the cached entry has expired.
+func (c *memberHealthCache) Get(member string) (online bool, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	health, ok := c.members[member]
+	if !ok || c.now().Sub(health.checkedAt) > c.ttl {
+		return false, false
+	}
+
+	return health.online, true
+}
+
+// Set records the health of the given cluster member.
+func (c *memberHealthCache) Set(member string, online bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.members[member] = memberHealth{
+		online:    online,
+		checkedAt: c.now(),
+	}
+}
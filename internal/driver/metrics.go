@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"github.com/canonical/lxd-csi-driver/internal/metrics"
+)
+
+// volumeSizeRoundedTotal counts volumes whose allocated size exceeds the
+// requested size by more than the configured threshold.
+var volumeSizeRoundedTotal = metrics.NewCounter(
+	"lxd_csi_volume_size_rounded_total",
+	"Number of volumes whose allocated size exceeds the requested size by more than the configured threshold.",
+	"pool",
+)
+
+// retriesTotal counts the retries of requests that failed with a transient
+// LXD error.
+var retriesTotal = metrics.NewCounter(
+	"lxd_csi_retries_total",
+	"Number of retries of requests that failed with a transient LXD error.",
+	"rpc",
+)
+
+// retriesInFlight tracks the number of requests that are currently being
+// retried.
+var retriesInFlight = metrics.NewGauge(
+	"lxd_csi_retries_in_flight",
+	"Number of requests that are currently being retried.",
+	"rpc",
+)
+
+// volumePublishTotal counts the volumes published to nodes by outcome.
+// The outcome is either "attached" when the volume is newly attached, or
+// "already_attached" when the volume is already attached to the node.
+var volumePublishTotal = metrics.NewCounter(
+	"lxd_csi_volume_publish_total",
+	"Number of volumes published to nodes by outcome.",
+	"outcome",
+)
+
+const (
+	// publishOutcomeAttached indicates the volume was newly attached.
+	publishOutcomeAttached = "attached"
+
+	// publishOutcomeAlreadyAttached indicates the volume was already attached.
+	publishOutcomeAlreadyAttached = "already_attached"
+)
+
+// attachedVolumes tracks the number of volumes attached to each node. It is
+// updated from the node devices on each publish and unpublish, rather than
+// incremented, so that it is corrected by the next request if it was missed
+// (for example, when the controller restarts).
+var attachedVolumes = metrics.NewGauge(
+	"lxd_csi_attached_volumes",
+	"Number of volumes attached to the node.",
+	"node",
+)
+
+// volumeProvisionSecondsTotal and volumeProvisionTotal track the time spent
+// provisioning new volumes. Together they allow computing the average
+// provisioning duration per storage pool and storage driver.
+var volumeProvisionSecondsTotal = metrics.NewCounter(
+	"lxd_csi_volume_provision_seconds_total",
+	"Total time spent provisioning new volumes in seconds.",
+	"pool", "driver",
+)
+
+var volumeProvisionTotal = metrics.NewCounter(
+	"lxd_csi_volume_provision_total",
+	"Number of provisioned volumes.",
+	"pool", "driver",
+)
+
+// poolOperationsInFlight tracks the number of operations holding a slot of
+// the storage pool operation limiter.
+var poolOperationsInFlight = metrics.NewGauge(
+	"lxd_csi_pool_operations_in_flight",
+	"Number of in-flight operations per storage pool.",
+	"pool",
+)
+
+// poolOperationsWaiting and operationsWaiting track the number of operations
+// waiting for a free slot of the storage pool operation limiter, per storage
+// pool and in total. A non-zero value indicates that the driver is saturated.
+var poolOperationsWaiting = metrics.NewGauge(
+	"lxd_csi_pool_operations_waiting",
+	"Number of operations waiting for a free operation slot per storage pool.",
+	"pool",
+)
+
+var operationsWaiting = metrics.NewGauge(
+	"lxd_csi_operations_waiting",
+	"Number of operations waiting for a free operation slot.",
+)
+
+// poolOperationWaitSecondsTotal and poolOperationWaitsTotal track the time
+// operations spent waiting for a free slot of the storage pool operation
+// limiter. Operations that acquire a slot immediately are not counted.
+var poolOperationWaitSecondsTotal = metrics.NewCounter(
+	"lxd_csi_pool_operation_wait_seconds_total",
+	"Total time operations spent waiting for a free operation slot in seconds.",
+	"pool",
+)
+
+var poolOperationWaitsTotal = metrics.NewCounter(
+	"lxd_csi_pool_operation_waits_total",
+	"Number of operations that waited for a free operation slot.",
+	"pool",
+)
@@ -2,10 +2,13 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
@@ -17,6 +20,12 @@ import (
 type nodeServer struct {
 	driver *Driver
 
+	// Monitors the health of the volumes published on the node.
+	volumeHealth *volumeHealthMonitor
+
+	// Trims the volumes published on the node that use periodic discard.
+	volumeTrimmer *volumeTrimmer
+
 	// Must be embedded for forward compatibility.
 	csi.UnimplementedNodeServer
 }
@@ -24,7 +33,9 @@ type nodeServer struct {
 // NewNodeServer returns a new instance of the CSI node server.
 func NewNodeServer(driver *Driver) *nodeServer {
 	return &nodeServer{
-		driver: driver,
+		driver:        driver,
+		volumeHealth:  newVolumeHealthMonitor(),
+		volumeTrimmer: newVolumeTrimmer(),
 	}
 }
 
@@ -41,7 +52,7 @@ func (n *nodeServer) NodeGetInfo(_ context.Context, _ *csi.NodeGetInfoRequest) (
 		NodeId: n.driver.nodeID,
 		AccessibleTopology: &csi.Topology{
 			Segments: map[string]string{
-				AnnotationLXDClusterMember: n.driver.location,
+				n.driver.topologyKey: n.driver.location,
 			},
 		},
 	}, nil
@@ -84,25 +95,59 @@ func (n *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublish
 
 	if mounted {
 		// Already mounted, nothing to do.
+		n.trackVolume(req)
 		return &csi.NodePublishVolumeResponse{}, nil
 	}
 
 	var sourcePath string
+	var selinuxContext string
 
 	switch req.VolumeCapability.AccessType.(type) {
 	case *csi.VolumeCapability_Block:
+		// The controller provides the name of the LXD device the volume is
+		// attached as. Fall back to the volume name for volumes published
+		// by older controllers.
+		deviceName := req.PublishContext[PublishContextDeviceName]
+		if deviceName == "" {
+			deviceName = volName
+		}
+
 		// Get the disk device path for the block volume.
-		sourcePath, err = getDiskDevicePath(volName)
+		sourcePath, err = waitDiskDevicePath(ctx, deviceName)
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "NodePublishVolume: Source device for volume %q not found: %v", volName, err)
+			code := codes.Internal
+			if errors.Is(err, errDiskDeviceNotFound) {
+				code = codes.NotFound
+			}
+
+			return nil, status.Errorf(code, "NodePublishVolume: Source device for volume %q not found: %v", volName, err)
+		}
+
+		// Apply the read-ahead of the block device if configured.
+		readAheadKB := req.PublishContext[ParameterReadAheadKB]
+		if readAheadKB != "" {
+			kb, err := strconv.ParseUint(readAheadKB, 10, 32)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume: Invalid read-ahead %q: %v", readAheadKB, err)
+			}
+
+			err = fs.SetReadAhead(sourcePath, kb)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "NodePublishVolume: %v", err)
+			}
 		}
 	case *csi.VolumeCapability_Mount:
 		// Construct the source path for the filesystem volume.
 		sourcePath = filepath.Join(driverFileSystemMountPath, volName)
 
-		// Read mount flags from the request.
+		// Read mount flags from the request. The SELinux context is not
+		// applicable to bind mounts, so it is applied after the mount.
+		// Mount propagation flags (for example, "rshared") are applied
+		// to the bind mount by [fs.Mount].
 		mnt := req.VolumeCapability.GetMount()
-		mountOptions = append(mountOptions, mnt.MountFlags...)
+		var mountFlags []string
+		selinuxContext, mountFlags = fs.ExtractSELinuxContext(mnt.MountFlags)
+		mountOptions = append(mountOptions, mountFlags...)
 
 		// Ensure source path is available.
 		if !fs.PathExists(sourcePath) {
@@ -112,15 +157,36 @@ func (n *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublish
 		return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume: Unsupported access type %q", req.VolumeCapability.AccessType)
 	}
 
+	// Label the volume with the SELinux context of the pod. The source path
+	// is labeled, as the bind mount of a read-only volume is not writable.
+	if selinuxContext != "" {
+		err = fs.SetSELinuxContext(sourcePath, selinuxContext)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "NodePublishVolume: %v", err)
+		}
+	}
+
 	// Bind mount the volume to the target path (application container).
 	err = fs.Mount(sourcePath, targetPath, contentType, mountOptions)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "NodePublishVolume: %v", err)
 	}
 
+	n.trackVolume(req)
+
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// trackVolume starts the background tasks for the published volume.
+func (n *nodeServer) trackVolume(req *csi.NodePublishVolumeRequest) {
+	n.volumeHealth.Track(req.TargetPath, req.VolumeId, req.Readonly)
+
+	// Read-only volumes cannot be trimmed.
+	if req.VolumeCapability.GetMount() != nil && !req.Readonly && req.VolumeContext[ParameterDiscard] == DiscardPeriodic {
+		n.volumeTrimmer.Track(req.TargetPath, req.VolumeId)
+	}
+}
+
 // NodeUnpublishVolume unmounts a filesystem volume or unmaps a block volume from the
 // pod’s target path on this node.
 func (n *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
@@ -134,38 +200,154 @@ func (n *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpub
 		return nil, status.Errorf(codes.Internal, "NodeUnpublishVolume: %v", err)
 	}
 
+	n.volumeHealth.Untrack(targetPath)
+	n.volumeTrimmer.Untrack(targetPath)
+
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
-// getDiskDevicePath returns the disk device path for a given volume name.
-func getDiskDevicePath(volName string) (string, error) {
+// NodeGetVolumeStats returns the usage and the condition of the volume
+// published at the given volume path.
+func (n *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	if req.VolumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "NodeGetVolumeStats: Volume ID not provided")
+	}
+
+	volumePath := req.VolumePath
+	if volumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "NodeGetVolumeStats: Volume path not provided")
+	}
+
+	if !fs.PathExists(volumePath) {
+		return nil, status.Errorf(codes.NotFound, "NodeGetVolumeStats: Volume path %q not found", volumePath)
+	}
+
+	// Use the result of the latest periodic health check if the volume is
+	// monitored. Otherwise, for example after the node plugin restarts,
+	// check the volume health directly. As it is unknown whether such a
+	// volume was published read-only, a read-only mount is not abnormal.
+	abnormalReason, ok := n.volumeHealth.AbnormalReason(volumePath)
+	if !ok {
+		abnormalReason = n.volumeHealth.check(volumePath, true)
+	}
+
+	condition := &csi.VolumeCondition{
+		Abnormal: abnormalReason != "",
+		Message:  abnormalReason,
+	}
+
+	if !condition.Abnormal {
+		condition.Message = "Volume is healthy"
+	}
+
+	stats, err := fs.GetVolumeStats(volumePath)
+	if err != nil {
+		// Report the condition even when the usage cannot be retrieved,
+		// as this is likely caused by the volume being abnormal.
+		if condition.Abnormal {
+			return &csi.NodeGetVolumeStatsResponse{VolumeCondition: condition}, nil
+		}
+
+		return nil, status.Errorf(codes.Internal, "NodeGetVolumeStats: %v", err)
+	}
+
+	usage := []*csi.VolumeUsage{
+		{
+			Unit:      csi.VolumeUsage_BYTES,
+			Total:     stats.TotalBytes,
+			Available: stats.AvailableBytes,
+			Used:      stats.UsedBytes,
+		},
+	}
+
+	if !stats.IsBlock {
+		usage = append(usage, &csi.VolumeUsage{
+			Unit:      csi.VolumeUsage_INODES,
+			Total:     stats.TotalInodes,
+			Available: stats.FreeInodes,
+			Used:      stats.UsedInodes,
+		})
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage:           usage,
+		VolumeCondition: condition,
+	}, nil
+}
+
+// diskDevicesPath is the directory containing symlinks to the disk devices
+// named after their identifiers. It can be overridden in tests.
+var diskDevicesPath = "/dev/disk/by-id"
+
+// diskDevicePollInterval is the interval between checks whether the disk
+// device of an attached volume has appeared.
+var diskDevicePollInterval = 500 * time.Millisecond
+
+// diskDeviceWaitTimeout is the maximum duration to wait for the disk device
+// of an attached volume to appear.
+var diskDeviceWaitTimeout = 30 * time.Second
+
+// errDiskDeviceNotFound is returned when the disk device of a volume is not found.
+var errDiskDeviceNotFound = errors.New("Disk device not found")
+
+// waitDiskDevicePath waits until the disk device with the given LXD device
+// name appears on the node and returns its path. The device may appear with
+// a delay after the volume is attached to the instance.
+func waitDiskDevicePath(ctx context.Context, deviceName string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, diskDeviceWaitTimeout)
+	defer cancel()
+
+	for {
+		devPath, err := getDiskDevicePath(deviceName)
+		if err == nil || !errors.Is(err, errDiskDeviceNotFound) {
+			return devPath, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(diskDevicePollInterval):
+		}
+	}
+}
+
+// getDiskDevicePath returns the disk device path for a given LXD device name.
+// The LXD device name of an attached volume matches the volume name.
+func getDiskDevicePath(deviceName string) (string, error) {
 	// LXD uses a prefix of a device name and "-" is replaced with "--".
-	// To match the device, we first extract the disk name from the device name by
-	// separating the name on "_lxd_" and then ensure the resulting substring is a
-	// prefix of the actual volume name.
-	basePath := "/dev/disk/by-id"
-	devices, err := os.ReadDir(basePath)
+	// To match the device, we first extract the disk name from the device name
+	// and then ensure the resulting substring is a prefix of the actual device
+	// name.
+	//
+	// Depending on the instance type and the disk bus, the device is exposed
+	// either as SCSI ("scsi-0QEMU_QEMU_HARDDISK_lxd_<name>") or virtio
+	// ("virtio-lxd_<name>") disk, where the virtio serial is truncated.
+	devices, err := os.ReadDir(diskDevicesPath)
 	if err != nil {
 		return "", fmt.Errorf("Failed to list disk devices: %v", err)
 	}
 
-	// Replace "-" with "--" in the volume name to match the device name format.
-	volDevName := strings.ReplaceAll(volName, "-", "--")
+	// Replace "-" with "--" in the device name to match the device name format.
+	devName := strings.ReplaceAll(deviceName, "-", "--")
 
 	for _, device := range devices {
 		// Example device name: "scsi-0QEMU_QEMU_HARDDISK_lxd_pvc--8722b28c--a".
 		// We are interested only in the device name suffix "pvc--8722b28c--a" after "_lxd_".
-		_, suffix, ok := strings.Cut(device.Name(), "_lxd_")
+		suffix, ok := strings.CutPrefix(device.Name(), "virtio-lxd_")
 		if !ok {
+			_, suffix, ok = strings.Cut(device.Name(), "_lxd_")
+		}
+
+		if !ok || suffix == "" {
 			continue
 		}
 
-		// Device name suffix should be a prefix of a volume name.
-		if strings.HasPrefix(volDevName, suffix) {
-			devPath := filepath.Join(basePath, device.Name())
+		// Device name suffix should be a prefix of a device name.
+		if strings.HasPrefix(devName, suffix) {
+			devPath := filepath.Join(diskDevicesPath, device.Name())
 			return filepath.EvalSymlinks(devPath)
 		}
 	}
 
-	return "", fmt.Errorf("Disk device not found for volume %q", volName)
+	return "", fmt.Errorf("%w for LXD device %q", errDiskDeviceNotFound, deviceName)
 }
@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/canonical/lxd-csi-driver/internal/fs"
+)
+
+func TestGetDiskDevicePath(t *testing.T) {
+	dir := t.TempDir()
+	diskDevicesPath = dir
+
+	device := filepath.Join(dir, "sdb")
+	require.NoError(t, os.WriteFile(device, nil, 0600))
+
+	// LXD truncates long device names, therefore the device identifier
+	// contains only a prefix of the device name.
+	require.NoError(t, os.Symlink(device, filepath.Join(dir, "scsi-0QEMU_QEMU_HARDDISK_lxd_pvc--8722b28c--a")))
+	require.NoError(t, os.Symlink(device, filepath.Join(dir, "scsi-0QEMU_QEMU_HARDDISK_drive--scsi0")))
+
+	// Virtio disks are identified by their serial, which is truncated as well.
+	virtioDevice := filepath.Join(dir, "vdb")
+	require.NoError(t, os.WriteFile(virtioDevice, nil, 0600))
+	require.NoError(t, os.Symlink(virtioDevice, filepath.Join(dir, "virtio-lxd_pvc--5a6b7c8d")))
+
+	tests := []struct {
+		Name         string
+		deviceName   string
+		expectPath   string
+		expectErrMsg string
+	}{
+		{
+			Name:       "Ensure device with truncated name is found",
+			deviceName: "pvc-8722b28c-a4f1-4bd8-9b1c-123456789abc",
+			expectPath: device,
+		},
+		{
+			Name:       "Ensure virtio device with truncated serial is found",
+			deviceName: "pvc-5a6b7c8d-1e2f-3a4b-5c6d-7e8f9a0b1c2d",
+			expectPath: virtioDevice,
+		},
+		{
+			Name:         "Ensure unknown device is not found",
+			deviceName:   "pvc-11111111-2222-3333-4444-555555555555",
+			expectErrMsg: "Disk device not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			path, err := getDiskDevicePath(test.deviceName)
+			if test.expectErrMsg != "" {
+				require.ErrorIs(t, err, errDiskDeviceNotFound)
+				require.ErrorContains(t, err, test.expectErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectPath, path)
+		})
+	}
+}
+
+func TestWaitDiskDevicePath(t *testing.T) {
+	diskDevicePollInterval = time.Millisecond
+
+	tests := []struct {
+		Name        string
+		deviceID    string
+		appearAfter time.Duration
+		timeout     time.Duration
+		expectFound bool
+	}{
+		{
+			Name:        "Ensure device that appears later is found",
+			deviceID:    "scsi-0QEMU_QEMU_HARDDISK_lxd_pvc--1234",
+			appearAfter: 20 * time.Millisecond,
+			timeout:     10 * time.Second,
+			expectFound: true,
+		},
+		{
+			Name:        "Ensure virtio device that appears later is found",
+			deviceID:    "virtio-lxd_pvc--1234",
+			appearAfter: 20 * time.Millisecond,
+			timeout:     10 * time.Second,
+			expectFound: true,
+		},
+		{
+			Name:        "Ensure device that does not appear in time is not found",
+			deviceID:    "scsi-0QEMU_QEMU_HARDDISK_lxd_pvc--1234",
+			appearAfter: time.Hour,
+			timeout:     20 * time.Millisecond,
+			expectFound: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			diskDevicesPath = dir
+			diskDeviceWaitTimeout = test.timeout
+
+			device := filepath.Join(dir, "sdb")
+			require.NoError(t, os.WriteFile(device, nil, 0600))
+
+			// Simulate the device appearing once the volume is attached.
+			timer := time.AfterFunc(test.appearAfter, func() {
+				_ = os.Symlink(device, filepath.Join(dir, test.deviceID))
+			})
+
+			defer timer.Stop()
+
+			path, err := waitDiskDevicePath(context.Background(), "pvc-1234")
+			if test.expectFound {
+				require.NoError(t, err)
+				require.Equal(t, device, path)
+			} else {
+				require.ErrorIs(t, err, errDiskDeviceNotFound)
+			}
+		})
+	}
+}
+
+func TestNodePublishVolumeReadOnlySELinuxContext(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Mounting volumes requires root privileges")
+	}
+
+	label := "system_u:object_r:container_file_t:s0:c1,c2"
+
+	mountPath := driverFileSystemMountPath
+	driverFileSystemMountPath = t.TempDir()
+	t.Cleanup(func() { driverFileSystemMountPath = mountPath })
+
+	sourcePath := filepath.Join(driverFileSystemMountPath, "pvc-volume-name")
+	require.NoError(t, os.Mkdir(sourcePath, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(sourcePath, "data"), nil, 0o640))
+
+	err := unix.Lsetxattr(t.TempDir(), "security.selinux", []byte(label), 0)
+	if err != nil {
+		t.Skipf("SELinux labels are not supported: %v", err)
+	}
+
+	targetPath := filepath.Join(t.TempDir(), "target")
+	t.Cleanup(func() { _ = fs.Unmount(targetPath) })
+
+	node := NewNodeServer(&Driver{name: "lxd.csi.canonical.com", nodeID: "test-node"})
+
+	// Ensure a read-only volume is published with the SELinux context of
+	// the pod, even though the bind mount is not writable.
+	_, err = node.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "remote/pvc-volume-name",
+		TargetPath: targetPath,
+		Readonly:   true,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{
+					MountFlags: []string{`context="` + label + `"`},
+				},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	buf := make([]byte, 256)
+	n, err := unix.Lgetxattr(filepath.Join(targetPath, "data"), "security.selinux", buf)
+	require.NoError(t, err)
+	require.Equal(t, label, strings.TrimRight(string(buf[:n]), "\x00"))
+}
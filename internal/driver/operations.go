@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"maps"
+	"sync"
+
+	lxdClient "github.com/canonical/lxd/client"
+)
+
+// pendingOperations tracks LXD operations that are still in progress after
+// the request that started them has returned (for example, due to a timeout).
+// This allows a retried request to wait on the existing operation instead of
+// starting a new one.
+type pendingOperations struct {
+	ops  map[string]lxdClient.DevLXDOperation
+	lock sync.Mutex
+}
+
+// newPendingOperations returns a new pending operations tracker.
+func newPendingOperations() *pendingOperations {
+	return &pendingOperations{
+		ops: make(map[string]lxdClient.DevLXDOperation),
+	}
+}
+
+// Get returns the pending operation for the given ID, or nil if there is none.
+func (p *pendingOperations) Get(id string) lxdClient.DevLXDOperation {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.ops[id]
+}
+
+// Set records the pending operation for the given ID.
+func (p *pendingOperations) Set(id string, op lxdClient.DevLXDOperation) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.ops[id] = op
+}
+
+// Delete removes the pending operation for the given ID.
+func (p *pendingOperations) Delete(id string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.ops, id)
+}
+
+// List returns a copy of the pending operations by ID.
+func (p *pendingOperations) List() map[string]lxdClient.DevLXDOperation {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return maps.Clone(p.ops)
+}
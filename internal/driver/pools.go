@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// poolSet is a set of storage pool names that is safe for concurrent use.
+// The devLXD API cannot list storage pools, therefore the controller keeps
+// track of the storage pools it needs to operate on.
+type poolSet struct {
+	pools map[string]bool
+	lock  sync.Mutex
+}
+
+// newPoolSet returns a new empty set of storage pools.
+func newPoolSet() *poolSet {
+	return &poolSet{
+		pools: make(map[string]bool),
+	}
+}
+
+// Track adds the given storage pool to the set.
+func (p *poolSet) Track(poolName string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.pools[poolName] = true
+}
+
+// List returns the sorted names of the storage pools in the set.
+func (p *poolSet) List() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return slices.Sorted(maps.Keys(p.pools))
+}
+
+// trackStorageClassPools adds the storage pools of the storage classes that
+// use this driver to the given set. This ensures the pools are known after
+// the controller restarts.
+func (c *controllerServer) trackStorageClassPools(ctx context.Context, pools *poolSet) {
+	k8sClient, err := kubernetesClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping discovery of storage pools of storage classes")
+		return
+	}
+
+	storageClasses, err := k8sClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.ErrorS(err, "Skipping discovery of storage pools of storage classes: Failed to list storage classes")
+		return
+	}
+
+	c.trackPoolsOf(pools, storageClasses.Items)
+}
+
+// trackPoolsOf adds the storage pools of the given storage classes that use
+// this driver to the given set.
+func (c *controllerServer) trackPoolsOf(pools *poolSet, storageClasses []storagev1.StorageClass) {
+	for _, sc := range storageClasses {
+		if sc.Provisioner != c.driver.name {
+			continue
+		}
+
+		poolName := sc.Parameters[ParameterStoragePool]
+		if poolName != "" {
+			pools.Track(c.driver.resolvePoolName(poolName))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Deletion protection prevents important volumes from being deleted when
+// their PVC is deleted, even if the reclaim policy of the PV is "Delete".
+//
+// The protection is requested by annotating the PVC with
+// [AnnotationDeletionProtection] set to "true" before the volume is
+// provisioned, if the storage class enables [ParameterDeletionProtection].
+// The annotation is mirrored into the LXD volume configuration
+// key [VolumeConfigDeletionProtection], which can also be set directly in
+// LXD for existing volumes.
+//
+// DeleteVolume refuses to delete a protected volume unless the deletion is
+// confirmed. To confirm the deletion, the storage class must reference a
+// provisioner secret, for example:
+//
+//	csi.storage.k8s.io/provisioner-secret-name: ${pvc.name}-deletion
+//	csi.storage.k8s.io/provisioner-secret-namespace: ${pvc.namespace}
+//
+// and the secret must contain the key [SecretDeletionConfirmation] with the
+// name of the LXD volume as its value when the PV is deleted. Kubernetes
+// retries the deletion, so the volume is deleted once the secret is updated.
+const (
+	// AnnotationDeletionProtection is the name of the PVC annotation that
+	// requests deletion protection of the provisioned volume.
+	AnnotationDeletionProtection = "lxd.csi.canonical.com/deletion-protection"
+
+	// VolumeConfigDeletionProtection is the LXD volume configuration key
+	// that marks the volume as protected against deletion.
+	VolumeConfigDeletionProtection = "user.deletion-protection"
+
+	// SecretDeletionConfirmation is the key of the DeleteVolume secret that
+	// confirms the deletion of a protected volume. Its value must be the
+	// name of the LXD volume.
+	SecretDeletionConfirmation = "deletionConfirmation"
+)
+
+// isDeletionProtected reports whether the given volume is protected against deletion.
+func isDeletionProtected(vol *api.DevLXDStorageVolume) bool {
+	return vol.Config[VolumeConfigDeletionProtection] == "true"
+}
+
+// checkDeletionConfirmation returns an error if the deletion of the given
+// protected volume is not confirmed by the request secrets.
+func checkDeletionConfirmation(volName string, secrets map[string]string) error {
+	confirmation, ok := secrets[SecretDeletionConfirmation]
+	if !ok {
+		return fmt.Errorf("Volume %q is protected against deletion: Secret %q is required to confirm the deletion", volName, SecretDeletionConfirmation)
+	}
+
+	if confirmation != volName {
+		return fmt.Errorf("Volume %q is protected against deletion: Deletion confirmation %q does not match the volume name", volName, confirmation)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/canonical/lxd-csi-driver/internal/lxderrors"
+)
+
+// retryDelay is the delay between attempts of a request that fails with
+// a transient LXD error.
+var retryDelay = 500 * time.Millisecond
+
+// isRetryableError returns true if the given error is transient and the
+// request can be safely retried.
+func isRetryableError(err error) bool {
+	return lxderrors.IsLeadershipChange(err)
+}
+
+// retry calls the given function until it succeeds, fails with an error that
+// is not retryable, the maximum number of retries is reached, or the context
+// is done. The last error is returned.
+func retry(ctx context.Context, action string, maxRetries int, fn func() error) error {
+	err := fn()
+	if err == nil || !isRetryableError(err) || maxRetries <= 0 {
+		return err
+	}
+
+	retriesInFlight.Add(1, action)
+	defer retriesInFlight.Add(-1, action)
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		klog.InfoS("Retrying request after transient LXD error", "action", action, "attempt", attempt, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryDelay):
+		}
+
+		retriesTotal.Inc(action)
+
+		err = fn()
+		if err == nil || !isRetryableError(err) {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,104 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestRetry(t *testing.T) {
+	retryDelay = time.Millisecond
+
+	leadershipErr := api.NewStatusError(http.StatusServiceUnavailable, "Failed to create storage volume: not leader")
+
+	tests := []struct {
+		Name          string
+		Errors        []error
+		maxRetries    int
+		expectCalls   int
+		expectSuccess bool
+	}{
+		{
+			Name:          "Ensure successful request is not retried",
+			Errors:        []error{nil},
+			maxRetries:    DefaultMaxRetries,
+			expectCalls:   1,
+			expectSuccess: true,
+		},
+		{
+			Name:          "Ensure leadership change is retried",
+			Errors:        []error{leadershipErr, errors.New("no available dqlite leader server found"), nil},
+			maxRetries:    DefaultMaxRetries,
+			expectCalls:   3,
+			expectSuccess: true,
+		},
+		{
+			Name:          "Ensure other errors are not retried",
+			Errors:        []error{api.NewStatusError(http.StatusBadRequest, "Invalid config"), nil},
+			maxRetries:    DefaultMaxRetries,
+			expectCalls:   1,
+			expectSuccess: false,
+		},
+		{
+			Name:          "Ensure retries are limited",
+			Errors:        []error{leadershipErr, leadershipErr, leadershipErr, leadershipErr, leadershipErr, nil},
+			maxRetries:    DefaultMaxRetries,
+			expectCalls:   DefaultMaxRetries + 1,
+			expectSuccess: false,
+		},
+		{
+			Name:          "Ensure retry budget caps retries",
+			Errors:        []error{leadershipErr, leadershipErr, leadershipErr, nil},
+			maxRetries:    1,
+			expectCalls:   2,
+			expectSuccess: false,
+		},
+		{
+			Name:          "Ensure retries can be disabled",
+			Errors:        []error{leadershipErr, nil},
+			maxRetries:    0,
+			expectCalls:   1,
+			expectSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			calls := 0
+			retries := retriesTotal.Value(test.Name)
+
+			err := retry(context.Background(), test.Name, test.maxRetries, func() error {
+				err := test.Errors[calls]
+				calls++
+				return err
+			})
+
+			require.Equal(t, test.expectCalls, calls)
+			require.Equal(t, test.expectSuccess, err == nil)
+			require.Equal(t, float64(test.expectCalls-1), retriesTotal.Value(test.Name)-retries)
+			require.Zero(t, retriesInFlight.Value(test.Name))
+		})
+	}
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	retryDelay = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := retry(ctx, "test", DefaultMaxRetries, func() error {
+		calls++
+		return errors.New("leadership lost")
+	})
+
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+}
@@ -0,0 +1,64 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// stopClones waits until the in-progress clones complete or the timeout
+// elapses, and cancels the clones that are still in progress using the LXD
+// operation cancel API. From then on, the volumes of clones that fail are
+// deleted, so that no partially cloned volume blocks the request that the
+// provisioner retries once the controller is restarted.
+//
+// The timeout should be shorter than the termination grace period of the
+// controller pod, so that the cleanup completes before the controller is
+// killed.
+func (c *controllerServer) stopClones(timeout time.Duration) {
+	c.shuttingDown.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for volumeID, op := range c.activeClones.List() {
+		err := op.WaitContext(ctx)
+		if err == nil || ctx.Err() == nil {
+			// The clone has completed or failed on its own.
+			continue
+		}
+
+		klog.InfoS("Cancelling in-progress clone on shutdown", "volumeID", volumeID, "operationID", op.Get().ID, "timeout", timeout)
+
+		err = op.Cancel()
+		if err != nil {
+			klog.ErrorS(err, "Failed to cancel in-progress clone", "volumeID", volumeID, "operationID", op.Get().ID)
+		}
+	}
+}
+
+// deletePartialVolume deletes the given volume that was left behind by a
+// cancelled clone or by a create request that cannot be completed. Failures
+// are only logged.
+func deletePartialVolume(client lxdClient.DevLXDServer, poolName string, volName string) {
+	// Do not use the request context, which may already be cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	op, err := client.DeleteStoragePoolVolume(poolName, "custom", volName)
+	if err == nil {
+		err = waitOperation(ctx, "CreateVolume", op)
+	}
+
+	if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+		klog.ErrorS(err, "Failed to delete partially created volume", "pool", poolName, "volume", volName)
+		return
+	}
+
+	klog.InfoS("Deleted partially created volume", "pool", poolName, "volume", volName)
+}
@@ -0,0 +1,167 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// fakeCloneDevLXDOperation implements lxdClient.DevLXDOperation that
+// completes once the done channel is closed, or fails once cancelled.
+type fakeCloneDevLXDOperation struct {
+	lxdClient.DevLXDOperation
+
+	done      chan struct{}
+	cancelled chan struct{}
+}
+
+func newFakeCloneDevLXDOperation() *fakeCloneDevLXDOperation {
+	return &fakeCloneDevLXDOperation{
+		done:      make(chan struct{}),
+		cancelled: make(chan struct{}),
+	}
+}
+
+func (f *fakeCloneDevLXDOperation) Get() api.DevLXDOperation {
+	return api.DevLXDOperation{ID: "clone"}
+}
+
+func (f *fakeCloneDevLXDOperation) Cancel() error {
+	close(f.cancelled)
+	return nil
+}
+
+func (f *fakeCloneDevLXDOperation) WaitContext(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return nil
+	case <-f.cancelled:
+		return errors.New("Operation cancelled")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestControllerStopClones(t *testing.T) {
+	op := newFakeCloneDevLXDOperation()
+
+	var cloned atomic.Bool
+	var deleted atomic.Bool
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getStateFunc: func() (*api.DevLXDGet, error) {
+				return &api.DevLXDGet{
+					DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+						SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+							{Name: "ceph", Remote: true},
+						},
+					},
+				}, nil
+			},
+			getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+				return &api.DevLXDStoragePool{Name: pool, Driver: "ceph"}, "", nil
+			},
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				if name == "pvc-source" {
+					return &api.DevLXDStorageVolume{
+						Name:        name,
+						ContentType: "filesystem",
+						Config:      map[string]string{"size": "1048576"},
+					}, "", nil
+				}
+
+				return nil, "", api.NewStatusError(http.StatusNotFound, "Volume not found")
+			},
+			createVolFunc: func(target string, pool string, volume api.DevLXDStorageVolumesPost) (lxdClient.DevLXDOperation, error) {
+				cloned.Store(true)
+				return op, nil
+			},
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				require.Equal(t, "pvc-11112222", name)
+				deleted.Store(true)
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := controller.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:          "pvc-1111-2222",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 1048576},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+				},
+			},
+			VolumeContentSource: &csi.VolumeContentSource{
+				Type: &csi.VolumeContentSource_Volume{
+					Volume: &csi.VolumeContentSource_VolumeSource{
+						VolumeId: "remote/pvc-source",
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterStoragePool: "remote",
+			},
+		})
+
+		errCh <- err
+	}()
+
+	// Wait for the clone to start.
+	require.Eventually(t, func() bool {
+		return len(controller.activeClones.List()) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	// Ensure the clone that does not complete in time is cancelled and
+	// the partially cloned volume is deleted.
+	controller.stopClones(20 * time.Millisecond)
+
+	select {
+	case err := <-errCh:
+		require.ErrorContains(t, err, "Operation cancelled")
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the clone to be cancelled")
+	}
+
+	require.True(t, cloned.Load(), "Clone should have been started")
+	require.True(t, deleted.Load(), "Partially cloned volume should have been deleted")
+	require.Empty(t, controller.activeClones.List())
+}
+
+func TestControllerStopClonesCompleted(t *testing.T) {
+	controller := NewControllerServer(&Driver{})
+
+	// Ensure the clone that completes in time is not cancelled.
+	op := newFakeCloneDevLXDOperation()
+	controller.activeClones.Set("remote/pvc-volume-name", op)
+
+	time.AfterFunc(10*time.Millisecond, func() { close(op.done) })
+	controller.stopClones(5 * time.Second)
+
+	select {
+	case <-op.cancelled:
+		require.FailNow(t, "Completed clone should not have been cancelled")
+	default:
+	}
+}
@@ -0,0 +1,155 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/lxd/locking"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Soft deletion gives operators a recovery window for accidentally deleted
+// volumes. When a delete grace period is configured, DeleteVolume does not
+// delete the LXD volume. Instead, it marks the volume with the configuration
+// key [VolumeConfigDeleteAfter] containing the time after which the volume is
+// deleted by a background sweeper.
+//
+// A soft-deleted volume can be recovered by removing the key from the volume
+// configuration and statically provisioning a PV that references the volume.
+const (
+	// VolumeConfigDeleteAfter is the LXD volume configuration key that marks
+	// the volume as deleted. It contains the time in RFC 3339 format after
+	// which the volume is permanently deleted.
+	VolumeConfigDeleteAfter = "user.delete-after"
+)
+
+// softDeleteSweepInterval is the maximum interval at which soft-deleted
+// volumes are checked for expiry.
+const softDeleteSweepInterval = 10 * time.Minute
+
+// softDeleteExpiry returns the time after which the given soft-deleted volume
+// is deleted. It returns false if the volume is not soft-deleted.
+func softDeleteExpiry(vol *api.DevLXDStorageVolume) (time.Time, bool) {
+	value := vol.Config[VolumeConfigDeleteAfter]
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	expiry, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// Delete volumes with an invalid expiry immediately, rather than
+		// keeping them forever.
+		klog.ErrorS(err, "Invalid soft deletion expiry", "pool", vol.Pool, "volume", vol.Name, "value", value)
+		return time.Time{}, true
+	}
+
+	return expiry, true
+}
+
+// softDeleteVolume marks the given volume as deleted, so that it is deleted
+// once the grace period elapses. Volumes that are already marked keep their
+// original expiry.
+func softDeleteVolume(ctx context.Context, client lxdClient.DevLXDServer, poolName string, vol *api.DevLXDStorageVolume, etag string, gracePeriod time.Duration) (time.Time, error) {
+	expiry, ok := softDeleteExpiry(vol)
+	if ok {
+		return expiry, nil
+	}
+
+	expiry = time.Now().Add(gracePeriod).UTC().Truncate(time.Second)
+
+	config := maps.Clone(vol.Config)
+	if config == nil {
+		config = make(map[string]string)
+	}
+
+	config[VolumeConfigDeleteAfter] = expiry.Format(time.RFC3339)
+
+	err := updateVolumeConfig(ctx, client, "DeleteVolume", poolName, vol, etag, config)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return expiry, nil
+}
+
+// sweepSoftDeletedVolumes deletes the soft-deleted volumes whose grace period
+// has elapsed. Failures are logged and the volumes are retried on the next
+// sweep, as are all volumes while the driver is in maintenance mode.
+func (c *controllerServer) sweepSoftDeletedVolumes(ctx context.Context) {
+	// Volumes are not deleted in maintenance mode.
+	if c.driver.MaintenanceMode() {
+		return
+	}
+
+	client, err := c.driver.DevLXDClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping sweep of soft-deleted volumes")
+		return
+	}
+
+	now := time.Now()
+
+	for _, poolName := range c.softDeletePools.List() {
+		vols, err := client.GetStoragePoolVolumes(poolName)
+		if err != nil {
+			klog.ErrorS(err, "Failed to list volumes for sweep of soft-deleted volumes", "pool", poolName)
+			continue
+		}
+
+		for _, vol := range vols {
+			expiry, ok := softDeleteExpiry(&vol)
+			if !ok || vol.Type != "custom" || !isManagedVolume(&vol) || now.Before(expiry) {
+				continue
+			}
+
+			target := c.volumeTarget(&vol)
+			volClient := client
+			if target != "" {
+				volClient = client.UseTarget(target)
+			}
+
+			// Skip volumes that are in use by another request.
+			lockKey := c.volumeLockKey(target, poolName, vol.Name)
+			unlock := locking.TryLock(lockKey)
+			if unlock == nil {
+				continue
+			}
+
+			op, err := volClient.DeleteStoragePoolVolume(poolName, "custom", vol.Name)
+			if err == nil {
+				err = waitOperation(ctx, "DeleteVolume", op)
+			}
+
+			unlock()
+
+			if err != nil {
+				klog.ErrorS(err, "Failed to delete soft-deleted volume", "pool", poolName, "volume", vol.Name)
+				continue
+			}
+
+			klog.InfoS("Deleted soft-deleted volume", "pool", poolName, "volume", vol.Name, "deleteAfter", vol.Config[VolumeConfigDeleteAfter])
+		}
+	}
+}
+
+// RunSoftDeleteSweeper periodically deletes expired soft-deleted volumes
+// until the context is cancelled.
+func (c *controllerServer) RunSoftDeleteSweeper(ctx context.Context, interval time.Duration) {
+	c.trackStorageClassPools(ctx, c.softDeletePools)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.sweepSoftDeletedVolumes(ctx)
+		}
+	}
+}
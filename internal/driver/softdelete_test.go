@@ -0,0 +1,195 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/lxd/locking"
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestControllerDeleteVolumeSoftDelete(t *testing.T) {
+	var updated *api.DevLXDStorageVolumePut
+	var deleted bool
+
+	vol := &api.DevLXDStorageVolume{
+		Name:   "pvc-volume-name",
+		Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue},
+	}
+
+	d := &Driver{
+		name:                    "lxd.csi.canonical.com",
+		nodeID:                  "test-node",
+		deleteVolumeGracePeriod: time.Hour,
+		devLXD: &fakeDevLXDServer{
+			getVolFunc: func(pool string, volType string, name string) (*api.DevLXDStorageVolume, string, error) {
+				return vol, "etag", nil
+			},
+			updateVolFunc: func(target string, pool string, volType string, name string, volume api.DevLXDStorageVolumePut, ETag string) (lxdClient.DevLXDOperation, error) {
+				require.Equal(t, "etag", ETag)
+				updated = &volume
+				return &fakeDevLXDOperation{}, nil
+			},
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				deleted = true
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	// Ensure the volume is marked as deleted instead of being deleted.
+	_, err := controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "remote/pvc-volume-name"})
+	require.NoError(t, err)
+	require.False(t, deleted, "Volume should not have been deleted")
+	require.NotNil(t, updated, "Volume should have been marked as deleted")
+	require.Equal(t, VolumeManagedByValue, updated.Config[VolumeConfigManagedBy])
+
+	expiry, err := time.Parse(time.RFC3339, updated.Config[VolumeConfigDeleteAfter])
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), expiry, time.Minute)
+	require.Equal(t, []string{"remote"}, controller.softDeletePools.List())
+
+	// Ensure deleting an already marked volume keeps the original expiry.
+	vol.Config = updated.Config
+	updated = nil
+
+	_, err = controller.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "remote/pvc-volume-name"})
+	require.NoError(t, err)
+	require.False(t, deleted, "Volume should not have been deleted")
+	require.Nil(t, updated, "Volume should not have been updated")
+}
+
+func TestSweepSoftDeletedVolumes(t *testing.T) {
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	pending := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	var deleted []string
+
+	d := &Driver{
+		name:   "lxd.csi.canonical.com",
+		nodeID: "test-node",
+		devLXD: &fakeDevLXDServer{
+			getVolsFunc: func(pool string) ([]api.DevLXDStorageVolume, error) {
+				return []api.DevLXDStorageVolume{
+					{
+						Name:   "expired",
+						Type:   "custom",
+						Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, VolumeConfigDeleteAfter: expired},
+					},
+					{
+						Name:   "pending",
+						Type:   "custom",
+						Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, VolumeConfigDeleteAfter: pending},
+					},
+					{
+						Name:   "active",
+						Type:   "custom",
+						Config: map[string]string{VolumeConfigManagedBy: VolumeManagedByValue},
+					},
+					{
+						Name:   "unmanaged",
+						Type:   "custom",
+						Config: map[string]string{VolumeConfigDeleteAfter: expired},
+					},
+				}, nil
+			},
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				deleted = append(deleted, pool+"/"+name)
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+
+	// Ensure pools are not swept until tracked.
+	controller.sweepSoftDeletedVolumes(context.Background())
+	require.Empty(t, deleted)
+
+	// Ensure only the expired managed volume is deleted.
+	controller.softDeletePools.Track("remote")
+	controller.sweepSoftDeletedVolumes(context.Background())
+	require.Equal(t, []string{"remote/expired"}, deleted)
+}
+
+func TestSweepSoftDeletedVolumesClustered(t *testing.T) {
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	var deleted []string
+
+	d := &Driver{
+		name:        "lxd.csi.canonical.com",
+		nodeID:      "test-node",
+		isClustered: true,
+		devLXD: &fakeDevLXDServer{
+			getVolsFunc: func(pool string) ([]api.DevLXDStorageVolume, error) {
+				return []api.DevLXDStorageVolume{
+					{
+						Name:     "expired",
+						Type:     "custom",
+						Location: "none",
+						Config:   map[string]string{VolumeConfigManagedBy: VolumeManagedByValue, VolumeConfigDeleteAfter: expired},
+					},
+				}, nil
+			},
+			deleteVolFunc: func(pool string, volType string, name string) (lxdClient.DevLXDOperation, error) {
+				deleted = append(deleted, pool+"/"+name)
+				return &fakeDevLXDOperation{}, nil
+			},
+		},
+	}
+
+	controller := NewControllerServer(d)
+	controller.softDeletePools.Track("remote")
+
+	// Ensure volumes in remote pools are locked under the same key as used
+	// by concurrent requests, which do not target any cluster member.
+	unlock := locking.TryLock(controller.volumeLockKey("", "remote", "expired"))
+	require.NotNil(t, unlock)
+
+	controller.sweepSoftDeletedVolumes(context.Background())
+	require.Empty(t, deleted)
+
+	unlock()
+
+	// Ensure volumes in remote pools are deleted once unlocked.
+	controller.sweepSoftDeletedVolumes(context.Background())
+	require.Equal(t, []string{"remote/expired"}, deleted)
+}
+
+func TestTrackSoftDeletePoolsOfStorageClasses(t *testing.T) {
+	d := &Driver{
+		name:               "lxd.csi.canonical.com",
+		storagePoolAliases: map[string]string{"old": "new"},
+	}
+
+	controller := NewControllerServer(d)
+	controller.trackPoolsOf(controller.softDeletePools, []storagev1.StorageClass{
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "remote"},
+			Provisioner: "lxd.csi.canonical.com",
+			Parameters:  map[string]string{ParameterStoragePool: "remote"},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "aliased"},
+			Provisioner: "lxd.csi.canonical.com",
+			Parameters:  map[string]string{ParameterStoragePool: "old"},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "other"},
+			Provisioner: "other.csi.example.com",
+			Parameters:  map[string]string{ParameterStoragePool: "other"},
+		},
+	})
+
+	require.Equal(t, []string{"new", "remote"}, controller.softDeletePools.List())
+}
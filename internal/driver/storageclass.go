@@ -0,0 +1,105 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// storageClassValidationTimeout is the maximum duration of the storage class
+// validation at controller startup.
+const storageClassValidationTimeout = time.Minute
+
+// validateStorageClass ensures that volumes can be provisioned using the given
+// storage class parameters. It verifies the parameters are valid, the storage
+// pool exists, and the storage pool driver is supported.
+func validateStorageClass(client lxdClient.DevLXDServer, state *api.DevLXDGet, parameters map[string]string) error {
+	poolName, err := validateStorageClassParameters(parameters)
+	if err != nil {
+		return err
+	}
+
+	pool, _, err := client.GetStoragePool(poolName)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve storage pool %q: %w", poolName, err)
+	}
+
+	_, err = getSupportedStorageDriver(state, pool.Driver)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkStorageClasses validates the storage classes that use this driver as
+// a provisioner and logs the problems found. It returns the number of invalid
+// storage classes.
+func (d *Driver) checkStorageClasses(client lxdClient.DevLXDServer, storageClasses []storagev1.StorageClass) (int, error) {
+	state, err := client.GetState()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get LXD server info: %w", err)
+	}
+
+	invalid := 0
+	for _, sc := range storageClasses {
+		if sc.Provisioner != d.name {
+			continue
+		}
+
+		err := validateStorageClass(client, state, sc.Parameters)
+		if err != nil {
+			klog.ErrorS(err, "Invalid storage class", "storageClass", sc.Name)
+			invalid++
+			continue
+		}
+
+		klog.InfoS("Validated storage class", "storageClass", sc.Name)
+	}
+
+	return invalid, nil
+}
+
+// ValidateStorageClasses retrieves the storage classes from the Kubernetes API
+// and validates those that use this driver as a provisioner. Problems are only
+// logged, so that misconfigured storage classes are detected before a volume
+// is provisioned using them.
+func (d *Driver) ValidateStorageClasses(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, storageClassValidationTimeout)
+	defer cancel()
+
+	k8sClient, err := kubernetesClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping storage class validation")
+		return
+	}
+
+	storageClasses, err := k8sClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.ErrorS(err, "Skipping storage class validation: Failed to list storage classes")
+		return
+	}
+
+	client, err := d.DevLXDClient()
+	if err != nil {
+		klog.ErrorS(err, "Skipping storage class validation")
+		return
+	}
+
+	invalid, err := d.checkStorageClasses(client, storageClasses.Items)
+	if err != nil {
+		klog.ErrorS(err, "Skipping storage class validation")
+		return
+	}
+
+	if invalid > 0 {
+		klog.InfoS("Warning: Found invalid storage classes", "count", invalid)
+	}
+}
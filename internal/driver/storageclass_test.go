@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestValidateStorageClasses(t *testing.T) {
+	d := &Driver{
+		name: "lxd.csi.canonical.com",
+	}
+
+	client := &fakeDevLXDServer{
+		getStateFunc: func() (*api.DevLXDGet, error) {
+			return &api.DevLXDGet{
+				DevLXDGetUntrusted: api.DevLXDGetUntrusted{
+					SupportedStorageDrivers: []api.DevLXDServerStorageDriverInfo{
+						{Name: "zfs", Remote: false},
+						{Name: "cephobject", Remote: true},
+					},
+				},
+			}, nil
+		},
+		getPoolFunc: func(target string, pool string) (*api.DevLXDStoragePool, string, error) {
+			switch pool {
+			case "local":
+				return &api.DevLXDStoragePool{Name: pool, Driver: "zfs"}, "", nil
+			case "object":
+				return &api.DevLXDStoragePool{Name: pool, Driver: "cephobject"}, "", nil
+			}
+
+			return nil, "", api.NewStatusError(http.StatusNotFound, "Storage pool not found")
+		},
+	}
+
+	newStorageClass := func(name string, provisioner string, parameters map[string]string) storagev1.StorageClass {
+		return storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: name},
+			Provisioner: provisioner,
+			Parameters:  parameters,
+		}
+	}
+
+	tests := []struct {
+		Name           string
+		StorageClass   storagev1.StorageClass
+		expectInvalid  int
+		expectErrorMsg string
+	}{
+		{
+			Name:          "Ensure valid storage class is accepted",
+			StorageClass:  newStorageClass("valid", d.name, map[string]string{ParameterStoragePool: "local"}),
+			expectInvalid: 0,
+		},
+		{
+			Name:           "Ensure storage class with missing pool is rejected",
+			StorageClass:   newStorageClass("missing-pool", d.name, map[string]string{ParameterStoragePool: "missing"}),
+			expectInvalid:  1,
+			expectErrorMsg: `Failed to retrieve storage pool "missing"`,
+		},
+		{
+			Name:           "Ensure storage class with unsupported driver is rejected",
+			StorageClass:   newStorageClass("object", d.name, map[string]string{ParameterStoragePool: "object"}),
+			expectInvalid:  1,
+			expectErrorMsg: `CSI does not support storage driver "cephobject"`,
+		},
+		{
+			Name:           "Ensure storage class with unknown parameter is rejected",
+			StorageClass:   newStorageClass("unknown-param", d.name, map[string]string{ParameterStoragePool: "local", "unknown": "value"}),
+			expectInvalid:  1,
+			expectErrorMsg: `Invalid parameter "unknown"`,
+		},
+		{
+			Name:           "Ensure storage class with invalid discard mode is rejected",
+			StorageClass:   newStorageClass("invalid-discard", d.name, map[string]string{ParameterStoragePool: "local", ParameterDiscard: "always"}),
+			expectInvalid:  1,
+			expectErrorMsg: `Invalid parameter "discard" value "always"`,
+		},
+		{
+			Name:          "Ensure storage classes of other provisioners are ignored",
+			StorageClass:  newStorageClass("other", "other.csi.example.com", map[string]string{"unknown": "value"}),
+			expectInvalid: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			invalid, err := d.checkStorageClasses(client, []storagev1.StorageClass{test.StorageClass})
+			require.NoError(t, err)
+			require.Equal(t, test.expectInvalid, invalid)
+
+			if test.expectErrorMsg != "" {
+				state, err := client.GetState()
+				require.NoError(t, err)
+
+				err = validateStorageClass(client, state, test.StorageClass.Parameters)
+				require.ErrorContains(t, err, test.expectErrorMsg)
+			}
+		})
+	}
+}
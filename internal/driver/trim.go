@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/canonical/lxd-csi-driver/internal/fs"
+)
+
+// volumeTrimmer periodically discards unused blocks of the filesystem volumes
+// published on the node that use periodic discard.
+type volumeTrimmer struct {
+	lock sync.Mutex
+
+	// Volume IDs of the trimmed volumes keyed by their target paths.
+	volumes map[string]string
+
+	// trim discards unused blocks of the filesystem mounted at the given
+	// path. It can be overridden in tests.
+	trim func(path string) (uint64, error)
+}
+
+// newVolumeTrimmer returns a new volume trimmer.
+func newVolumeTrimmer() *volumeTrimmer {
+	return &volumeTrimmer{
+		volumes: make(map[string]string),
+		trim:    fs.Trim,
+	}
+}
+
+// Track starts trimming the volume published at the given target path.
+func (t *volumeTrimmer) Track(targetPath string, volumeID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.volumes[targetPath] = volumeID
+}
+
+// Untrack stops trimming the volume published at the given target path.
+func (t *volumeTrimmer) Untrack(targetPath string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.volumes, targetPath)
+}
+
+// TrimAll trims all tracked volumes. Failures are logged and do not
+// prevent other volumes from being trimmed.
+func (t *volumeTrimmer) TrimAll() {
+	t.lock.Lock()
+	volumes := make(map[string]string, len(t.volumes))
+	for targetPath, volumeID := range t.volumes {
+		volumes[targetPath] = volumeID
+	}
+
+	t.lock.Unlock()
+
+	// The same volume may be published to multiple target paths,
+	// but it needs to be trimmed only once.
+	trimmed := make(map[string]bool, len(volumes))
+
+	for targetPath, volumeID := range volumes {
+		if trimmed[volumeID] {
+			continue
+		}
+
+		bytes, err := t.trim(targetPath)
+		if err != nil {
+			klog.ErrorS(err, "Failed to trim volume", "volumeID", volumeID, "targetPath", targetPath)
+			continue
+		}
+
+		trimmed[volumeID] = true
+		klog.InfoS("Trimmed volume", "volumeID", volumeID, "targetPath", targetPath, "bytes", bytes)
+	}
+}
+
+// Run periodically trims the tracked volumes until the context is cancelled.
+func (t *volumeTrimmer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.TrimAll()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeTrimmer(t *testing.T) {
+	trimmer := newVolumeTrimmer()
+
+	var trimmed []string
+	trimmer.trim = func(path string) (uint64, error) {
+		trimmed = append(trimmed, path)
+		if path == "/pods/2/vol-b" {
+			return 0, errors.New("Operation not supported")
+		}
+
+		return 1024, nil
+	}
+
+	// Track the same volume published to multiple target paths.
+	trimmer.Track("/pods/1/vol-a", "remote/vol-a")
+	trimmer.Track("/pods/2/vol-a", "remote/vol-a")
+	trimmer.Track("/pods/2/vol-b", "remote/vol-b")
+
+	// Ensure each volume is trimmed once, and failures do not stop trimming.
+	trimmer.TrimAll()
+	require.Len(t, trimmed, 3-1)
+	require.Contains(t, trimmed, "/pods/2/vol-b")
+
+	// Ensure untracked volumes are no longer trimmed.
+	trimmed = nil
+	trimmer.Untrack("/pods/1/vol-a")
+	trimmer.Untrack("/pods/2/vol-a")
+	trimmer.TrimAll()
+	require.Equal(t, []string{"/pods/2/vol-b"}, trimmed)
+}
@@ -0,0 +1,96 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	lxdClient "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// redactedConfigValue replaces the values of sensitive configuration keys
+// in the logged configuration changes.
+const redactedConfigValue = "<redacted>"
+
+// sensitiveConfigKeyParts contains the parts of the configuration keys whose
+// values must not be logged.
+var sensitiveConfigKeyParts = []string{"secret", "password", "passphrase", "token", "credential"}
+
+// isSensitiveConfigKey returns true if the value of the given configuration
+// key must not be logged.
+func isSensitiveConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveConfigKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// volumeConfigDiff returns the sorted changes between the old and the new
+// volume configuration in the "<key>: <old> -> <new>" format. Removed and
+// added keys have an empty old or new value. The values of sensitive keys
+// are redacted.
+func volumeConfigDiff(oldConfig map[string]string, newConfig map[string]string) []string {
+	keys := make(map[string]bool, len(newConfig))
+	for key := range oldConfig {
+		keys[key] = true
+	}
+
+	for key := range newConfig {
+		keys[key] = true
+	}
+
+	var changes []string
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		oldValue, oldOK := oldConfig[key]
+		newValue, newOK := newConfig[key]
+		if oldOK == newOK && oldValue == newValue {
+			continue
+		}
+
+		if isSensitiveConfigKey(key) {
+			if oldOK {
+				oldValue = redactedConfigValue
+			}
+
+			if newOK {
+				newValue = redactedConfigValue
+			}
+		}
+
+		changes = append(changes, key+": "+oldValue+" -> "+newValue)
+	}
+
+	return changes
+}
+
+// updateVolumeConfig replaces the configuration of the given volume and
+// waits for the update to complete. The changed configuration keys are
+// logged, which provides an audit trail of the volume updates performed
+// by the driver.
+func updateVolumeConfig(ctx context.Context, client lxdClient.DevLXDServer, rpc string, poolName string, vol *api.DevLXDStorageVolume, etag string, config map[string]string) error {
+	volReq := api.DevLXDStorageVolumePut{
+		Description: vol.Description,
+		Config:      config,
+	}
+
+	op, err := client.UpdateStoragePoolVolume(poolName, "custom", vol.Name, volReq, etag)
+	if err == nil {
+		err = waitOperation(ctx, rpc, op)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	klog.InfoS("Updated volume configuration", "rpc", rpc, "pool", poolName, "volume", vol.Name, "changes", volumeConfigDiff(vol.Config, config))
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeConfigDiff(t *testing.T) {
+	tests := []struct {
+		Name          string
+		oldConfig     map[string]string
+		newConfig     map[string]string
+		expectChanges []string
+	}{
+		{
+			Name:          "Ensure size change is reported",
+			oldConfig:     map[string]string{"size": "1073741824", VolumeConfigManagedBy: VolumeManagedByValue},
+			newConfig:     map[string]string{"size": "2147483648", VolumeConfigManagedBy: VolumeManagedByValue},
+			expectChanges: []string{"size: 1073741824 -> 2147483648"},
+		},
+		{
+			Name:          "Ensure added and removed keys are reported in order",
+			oldConfig:     map[string]string{"user.b": "old"},
+			newConfig:     map[string]string{"user.a": "new"},
+			expectChanges: []string{"user.a:  -> new", "user.b: old -> "},
+		},
+		{
+			Name:          "Ensure sensitive values are redacted",
+			oldConfig:     map[string]string{"user.api-token": "abc"},
+			newConfig:     map[string]string{"user.api-token": "def", "user.Password": "secret"},
+			expectChanges: []string{"user.Password:  -> <redacted>", "user.api-token: <redacted> -> <redacted>"},
+		},
+		{
+			Name:          "Ensure unchanged config reports no changes",
+			oldConfig:     map[string]string{"size": "1024"},
+			newConfig:     map[string]string{"size": "1024"},
+			expectChanges: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Equal(t, test.expectChanges, volumeConfigDiff(test.oldConfig, test.newConfig))
+		})
+	}
+}
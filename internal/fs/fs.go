@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unsafe"
 
 	"github.com/fsnotify/fsnotify"
 	"golang.org/x/sys/unix"
@@ -87,6 +91,109 @@ func ResolveMountOptions(options []string) (uintptr, string) {
 	return mountFlags, strings.Join(mountOptions, ",")
 }
 
+// selinuxContextOption is the mount option used by kubelet to pass
+// the SELinux context of the pod to the volume mount.
+const selinuxContextOption = "context="
+
+// ExtractSELinuxContext extracts the SELinux context from the provided
+// mount options. It returns the context (without surrounding quotes) and
+// the remaining mount options.
+func ExtractSELinuxContext(options []string) (selinuxContext string, remaining []string) {
+	for _, opt := range options {
+		value, ok := strings.CutPrefix(opt, selinuxContextOption)
+		if !ok {
+			remaining = append(remaining, opt)
+			continue
+		}
+
+		// Kubelet quotes the context, as it may contain commas (MCS categories).
+		selinuxContext = strings.Trim(value, `"`)
+	}
+
+	return selinuxContext, remaining
+}
+
+// mountPropagationFlags maps the supported mount propagation options to
+// the corresponding mount flags.
+var mountPropagationFlags = map[string]uintptr{
+	"private":  unix.MS_PRIVATE,
+	"rprivate": unix.MS_REC | unix.MS_PRIVATE,
+	"slave":    unix.MS_SLAVE,
+	"rslave":   unix.MS_REC | unix.MS_SLAVE,
+	"shared":   unix.MS_SHARED,
+	"rshared":  unix.MS_REC | unix.MS_SHARED,
+}
+
+// defaultMountPropagation is the propagation of volume mounts when none is
+// requested. Mounts from the host propagate into the volume mount, but not
+// the other way around.
+const defaultMountPropagation = unix.MS_REC | unix.MS_SLAVE
+
+// ExtractMountPropagation extracts the mount propagation from the provided
+// mount options. Supported values are "private", "slave" and "shared", and
+// their recursive variants prefixed with "r". If multiple values are given,
+// the last one takes precedence. If none is given, "rslave" is used.
+// It returns the propagation mount flags and the remaining mount options.
+func ExtractMountPropagation(options []string) (propagation uintptr, remaining []string) {
+	propagation = defaultMountPropagation
+
+	for _, opt := range options {
+		flags, ok := mountPropagationFlags[opt]
+		if !ok {
+			remaining = append(remaining, opt)
+			continue
+		}
+
+		propagation = flags
+	}
+
+	return propagation, remaining
+}
+
+// selinuxXattr is the extended attribute containing the SELinux context.
+const selinuxXattr = "security.selinux"
+
+// SetSELinuxContext recursively labels the files under the given path
+// with the provided SELinux context. The files are not relabeled if the
+// path itself is already labeled with the context.
+//
+// Bind mounts ignore the "context=" mount option, therefore the context
+// is applied to the files directly.
+func SetSELinuxContext(path string, selinuxContext string) error {
+	if getSELinuxContext(path) == selinuxContext {
+		return nil
+	}
+
+	label := []byte(selinuxContext)
+
+	return filepath.WalkDir(path, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		err = unix.Lsetxattr(p, selinuxXattr, label, 0)
+		if err != nil {
+			return fmt.Errorf("Failed to set SELinux context %q on %q: %w", selinuxContext, p, err)
+		}
+
+		return nil
+	})
+}
+
+// getSELinuxContext returns the SELinux context of the given path, or an
+// empty string if the path is not labeled.
+func getSELinuxContext(path string) string {
+	buf := make([]byte, 256)
+
+	n, err := unix.Lgetxattr(path, selinuxXattr, buf)
+	if err != nil {
+		return ""
+	}
+
+	// The context may be terminated by a NUL byte.
+	return strings.TrimRight(string(buf[:n]), "\x00")
+}
+
 // IsMountPoint returns true if path is a mount point.
 func IsMountPoint(path string) (bool, error) {
 	mounter := kmount.New("")
@@ -98,6 +205,128 @@ func IsMountPoint(path string) (bool, error) {
 	return mounted, nil
 }
 
+// VolumeStats contains the usage statistics of a mounted volume.
+type VolumeStats struct {
+	// IsBlock indicates the path is a block device, in which case
+	// only TotalBytes is set.
+	IsBlock bool
+
+	TotalBytes     int64
+	AvailableBytes int64
+	UsedBytes      int64
+
+	TotalInodes int64
+	FreeInodes  int64
+	UsedInodes  int64
+
+	// ReadOnly indicates the mount is read-only.
+	ReadOnly bool
+}
+
+// GetVolumeStats returns the usage statistics of the volume mounted at the given path.
+func GetVolumeStats(path string) (*VolumeStats, error) {
+	var statfs unix.Statfs_t
+	err := unix.Statfs(path, &statfs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve filesystem statistics of %q: %w", path, err)
+	}
+
+	stats := &VolumeStats{
+		ReadOnly: statfs.Flags&unix.ST_RDONLY != 0,
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Block volumes are bind mounted device nodes. Report their size only.
+	if info.Mode()&os.ModeDevice != 0 {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open block device %q: %w", path, err)
+		}
+
+		defer func() { _ = file.Close() }()
+
+		size, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to determine size of block device %q: %w", path, err)
+		}
+
+		stats.IsBlock = true
+		stats.TotalBytes = size
+		return stats, nil
+	}
+
+	blockSize := int64(statfs.Bsize)
+	stats.TotalBytes = int64(statfs.Blocks) * blockSize
+	stats.AvailableBytes = int64(statfs.Bavail) * blockSize
+	stats.UsedBytes = (int64(statfs.Blocks) - int64(statfs.Bfree)) * blockSize
+	stats.TotalInodes = int64(statfs.Files)
+	stats.FreeInodes = int64(statfs.Ffree)
+	stats.UsedInodes = stats.TotalInodes - stats.FreeInodes
+
+	return stats, nil
+}
+
+// fitrim is the FITRIM ioctl request number, _IOWR('X', 121, struct fstrim_range),
+// which is not defined by the unix package.
+const fitrim = 0xc0185879
+
+// fstrimRange is the argument of the FITRIM ioctl (struct fstrim_range).
+type fstrimRange struct {
+	start  uint64
+	length uint64
+	minLen uint64
+}
+
+// Trim discards unused blocks of the filesystem mounted at the given path,
+// returning the space to the underlying storage. It returns the number of
+// bytes that were trimmed.
+func Trim(path string) (uint64, error) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() { _ = dir.Close() }()
+
+	r := fstrimRange{
+		start:  0,
+		length: math.MaxUint64,
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, dir.Fd(), fitrim, uintptr(unsafe.Pointer(&r)))
+	if errno != 0 {
+		return 0, fmt.Errorf("Failed to trim filesystem at %q: %w", path, errno)
+	}
+
+	// The kernel updates the length to the number of trimmed bytes.
+	return r.length, nil
+}
+
+// sysClassBlockPath is the sysfs directory containing the block devices.
+// It can be overridden in tests.
+var sysClassBlockPath = "/sys/class/block"
+
+// SetReadAhead sets the read-ahead of the given block device in kibibytes.
+func SetReadAhead(devPath string, kb uint64) error {
+	resolvedPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return fmt.Errorf("Failed to resolve block device %q: %w", devPath, err)
+	}
+
+	path := filepath.Join(sysClassBlockPath, filepath.Base(resolvedPath), "queue", "read_ahead_kb")
+
+	err = os.WriteFile(path, []byte(strconv.FormatUint(kb, 10)), 0)
+	if err != nil {
+		return fmt.Errorf("Failed to set read-ahead of block device %q: %w", devPath, err)
+	}
+
+	return nil
+}
+
 // Mount mounts a volume to a target path.
 func Mount(sourcePath string, targetPath string, contentType string, mountOptions []string) error {
 	if sourcePath == "" {
@@ -133,6 +362,7 @@ func Mount(sourcePath string, targetPath string, contentType string, mountOption
 		return fmt.Errorf("Invalid content type %q", contentType)
 	}
 
+	propagation, mountOptions := ExtractMountPropagation(mountOptions)
 	flags, mountOptionsStr := filesystem.ResolveMountOptions(mountOptions)
 
 	// Mount the filesystem
@@ -152,10 +382,9 @@ func Mount(sourcePath string, targetPath string, contentType string, mountOption
 		}
 	}
 
-	flags = unix.MS_REC | unix.MS_SLAVE
-	err = unix.Mount("", targetPath, "", uintptr(flags), "")
+	err = unix.Mount("", targetPath, "", propagation, "")
 	if err != nil {
-		return fmt.Errorf("Unable to make mount %q private: %w", targetPath, err)
+		return fmt.Errorf("Unable to set propagation of mount %q: %w", targetPath, err)
 	}
 
 	return nil
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 // waitUntil condition returns true or timeout is reached.
@@ -86,3 +87,141 @@ func Test_WatchFile_SymlinkSwap(t *testing.T) {
 	// Wait until change is detected and onChange handler triggered (hits >= 1).
 	waitUntil(t, time.Second, func() bool { return atomic.LoadInt32(&hits) >= 1 })
 }
+
+func Test_ExtractSELinuxContext(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Options       []string
+		expectContext string
+		expectOptions []string
+	}{
+		{
+			Name:          "No SELinux context",
+			Options:       []string{"noatime", "nodev"},
+			expectContext: "",
+			expectOptions: []string{"noatime", "nodev"},
+		},
+		{
+			Name:          "Quoted SELinux context with categories",
+			Options:       []string{"noatime", `context="system_u:object_r:container_file_t:s0:c1,c2"`},
+			expectContext: "system_u:object_r:container_file_t:s0:c1,c2",
+			expectOptions: []string{"noatime"},
+		},
+		{
+			Name:          "Unquoted SELinux context",
+			Options:       []string{"context=system_u:object_r:container_file_t:s0"},
+			expectContext: "system_u:object_r:container_file_t:s0",
+			expectOptions: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			selinuxContext, options := ExtractSELinuxContext(test.Options)
+			require.Equal(t, test.expectContext, selinuxContext)
+			require.Equal(t, test.expectOptions, options)
+		})
+	}
+}
+
+func TestExtractMountPropagation(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Options           []string
+		expectPropagation uintptr
+		expectOptions     []string
+	}{
+		{
+			Name:              "Default propagation",
+			Options:           []string{"bind", "noatime"},
+			expectPropagation: unix.MS_REC | unix.MS_SLAVE,
+			expectOptions:     []string{"bind", "noatime"},
+		},
+		{
+			Name:              "Private propagation",
+			Options:           []string{"bind", "private"},
+			expectPropagation: unix.MS_PRIVATE,
+			expectOptions:     []string{"bind"},
+		},
+		{
+			Name:              "Recursive private propagation",
+			Options:           []string{"bind", "rprivate"},
+			expectPropagation: unix.MS_REC | unix.MS_PRIVATE,
+			expectOptions:     []string{"bind"},
+		},
+		{
+			Name:              "Slave propagation",
+			Options:           []string{"bind", "slave"},
+			expectPropagation: unix.MS_SLAVE,
+			expectOptions:     []string{"bind"},
+		},
+		{
+			Name:              "Recursive slave propagation",
+			Options:           []string{"bind", "rslave"},
+			expectPropagation: unix.MS_REC | unix.MS_SLAVE,
+			expectOptions:     []string{"bind"},
+		},
+		{
+			Name:              "Shared propagation",
+			Options:           []string{"bind", "shared"},
+			expectPropagation: unix.MS_SHARED,
+			expectOptions:     []string{"bind"},
+		},
+		{
+			Name:              "Recursive shared propagation",
+			Options:           []string{"bind", "rshared", "noatime"},
+			expectPropagation: unix.MS_REC | unix.MS_SHARED,
+			expectOptions:     []string{"bind", "noatime"},
+		},
+		{
+			Name:              "Last propagation takes precedence",
+			Options:           []string{"bind", "rshared", "private"},
+			expectPropagation: unix.MS_PRIVATE,
+			expectOptions:     []string{"bind"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			propagation, options := ExtractMountPropagation(test.Options)
+			require.Equal(t, test.expectPropagation, propagation)
+			require.Equal(t, test.expectOptions, options)
+		})
+	}
+}
+
+func TestGetVolumeStats(t *testing.T) {
+	stats, err := GetVolumeStats(t.TempDir())
+	require.NoError(t, err)
+	require.False(t, stats.IsBlock)
+	require.Positive(t, stats.TotalBytes)
+	require.LessOrEqual(t, stats.UsedBytes, stats.TotalBytes)
+
+	_, err = GetVolumeStats(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestSetReadAhead(t *testing.T) {
+	sysClassBlockPath = t.TempDir()
+
+	queuePath := filepath.Join(sysClassBlockPath, "sdb", "queue")
+	require.NoError(t, os.MkdirAll(queuePath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(queuePath, "read_ahead_kb"), []byte("128"), 0644))
+
+	// Device is referenced through a symlink as in "/dev/disk/by-id".
+	devDir := t.TempDir()
+	device := filepath.Join(devDir, "sdb")
+	require.NoError(t, os.WriteFile(device, nil, 0600))
+	require.NoError(t, os.Symlink(device, filepath.Join(devDir, "scsi-0QEMU_QEMU_HARDDISK_lxd_pvc")))
+
+	err := SetReadAhead(filepath.Join(devDir, "scsi-0QEMU_QEMU_HARDDISK_lxd_pvc"), 4096)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(queuePath, "read_ahead_kb"))
+	require.NoError(t, err)
+	require.Equal(t, "4096", string(data))
+
+	// Ensure missing device is reported.
+	err = SetReadAhead(filepath.Join(devDir, "missing"), 4096)
+	require.Error(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 
@@ -52,7 +53,116 @@ func ToGRPCCode(err error) codes.Code {
 		return codes.DeadlineExceeded
 	case errors.Is(err, context.Canceled):
 		return codes.Canceled
+	case IsLeadershipChange(err):
+		// Requests failing due to the LXD cluster leadership change can be
+		// retried once the new leader is elected.
+		return codes.Unavailable
 	}
 
 	return codes.Internal
 }
+
+// matchesAny returns true if the message of the given error contains any of
+// the given substrings. The comparison ignores case, as the capitalization of
+// LXD error messages differs between LXD versions.
+func matchesAny(err error, substrings ...string) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, s := range substrings {
+		if strings.Contains(msg, strings.ToLower(s)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// memberOfflineMessages contains error messages returned by LXD when
+// a request targets a cluster member that is offline or unreachable.
+var memberOfflineMessages = []string{
+	"Target cluster member is offline",
+	"The cluster member hosting the storage volume is offline",
+	"Failed to connect to cluster member",
+}
+
+// memberUnreachableMessages contains socket errors returned by LXD when
+// forwarding a request to a cluster member fails. The same errors occur
+// when the devLXD socket itself is unreachable, therefore they indicate an
+// offline member only if returned by LXD.
+var memberUnreachableMessages = []string{
+	"connect: connection refused",
+	"connect: no route to host",
+}
+
+// IsMemberOffline returns true if the given error indicates that the targeted
+// LXD cluster member is offline or unreachable.
+func IsMemberOffline(err error) bool {
+	if matchesAny(err, memberOfflineMessages...) {
+		return true
+	}
+
+	// Only errors returned by LXD are LXD API status errors, while errors
+	// of the connection to the devLXD socket are not.
+	_, isLXDError := api.StatusErrorMatch(err)
+	return isLXDError && matchesAny(err, memberUnreachableMessages...)
+}
+
+// instanceBusyMessages contains error messages returned by LXD when an
+// instance cannot be modified because another operation is in progress.
+var instanceBusyMessages = []string{
+	"Instance is busy running a",
+	"An operation with this conflict reference is already running",
+}
+
+// IsInstanceBusy returns true if the given error indicates that the instance
+// is locked by another LXD operation. Such requests can be retried once the
+// operation completes.
+func IsInstanceBusy(err error) bool {
+	return matchesAny(err, instanceBusyMessages...)
+}
+
+// instanceNotRunningMessages contains error messages returned by LXD when
+// a device cannot be hot-plugged or hot-unplugged because the instance is
+// stopping or starting. For example, the QEMU monitor of a virtual machine
+// disconnects while the virtual machine is shutting down.
+var instanceNotRunningMessages = []string{
+	"Instance is not running",
+	"The instance is not running",
+	"The container is not running",
+	"Monitor is disconnected",
+}
+
+// IsInstanceNotRunning returns true if the given error indicates that the
+// instance stopped running while its devices were being updated. Such
+// requests can be retried once the instance is fully stopped or started.
+func IsInstanceNotRunning(err error) bool {
+	return matchesAny(err, instanceNotRunningMessages...)
+}
+
+// leadershipChangeMessages contains error messages returned by LXD when a
+// request is processed while the leadership of the LXD cluster database is
+// changing. For example:
+//
+//   - "not leader" or "leadership lost" when the member that was processing
+//     the request is no longer the database leader.
+//   - "no available dqlite leader server found" while a new leader is elected.
+//   - "Failed getting leader address" or "Failed connecting to cluster leader"
+//     when the member cannot reach the new leader yet.
+//
+// Such requests can be safely retried shortly after.
+var leadershipChangeMessages = []string{
+	"not leader",
+	"leadership lost",
+	"no available dqlite leader server found",
+	"failed getting leader address",
+	"failed connecting to cluster leader",
+}
+
+// IsLeadershipChange returns true if the given error indicates that the request
+// failed due to a leadership change in the LXD cluster.
+func IsLeadershipChange(err error) bool {
+	return matchesAny(err, leadershipChangeMessages...)
+}
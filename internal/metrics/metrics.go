@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricType is the type of the metric as reported in the exposition format.
+type metricType string
+
+const (
+	typeCounter metricType = "counter"
+	typeGauge   metricType = "gauge"
+)
+
+// registry contains all metrics created by this package.
+var registry = &metricRegistry{}
+
+// metricRegistry keeps track of registered metrics.
+type metricRegistry struct {
+	lock    sync.Mutex
+	metrics []*metric
+}
+
+// register adds the metric to the registry.
+func (r *metricRegistry) register(m *metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, existing := range r.metrics {
+		if existing.name == m.name {
+			panic(fmt.Sprintf("Metric %q is already registered", m.name))
+		}
+	}
+
+	r.metrics = append(r.metrics, m)
+}
+
+// metric is a single named metric with a set of label values.
+type metric struct {
+	name       string
+	help       string
+	kind       metricType
+	labelNames []string
+
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+// newMetric creates and registers a new metric.
+func newMetric(name string, help string, kind metricType, labelNames []string) *metric {
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+	}
+
+	registry.register(m)
+	return m
+}
+
+// key returns the series key for the given label values.
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("Metric %q expects %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+
+	labels := make([]string, len(labelValues))
+	for i, value := range labelValues {
+		labels[i] = m.labelNames[i] + "=" + strconv.Quote(value)
+	}
+
+	return strings.Join(labels, ",")
+}
+
+// add adds the given value to the series identified by the label values.
+func (m *metric) add(value float64, labelValues []string) {
+	key := m.key(labelValues)
+
+	m.lock.Lock()
+	m.values[key] += value
+	m.lock.Unlock()
+}
+
+// set sets the value of the series identified by the label values.
+func (m *metric) set(value float64, labelValues []string) {
+	key := m.key(labelValues)
+
+	m.lock.Lock()
+	m.values[key] = value
+	m.lock.Unlock()
+}
+
+// value returns the value of the series identified by the label values.
+func (m *metric) value(labelValues []string) float64 {
+	key := m.key(labelValues)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.values[key]
+}
+
+// write writes the metric in the Prometheus text exposition format.
+func (m *metric) write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		value := strconv.FormatFloat(m.values[key], 'g', -1, 64)
+		if key == "" {
+			_, _ = fmt.Fprintf(w, "%s %s\n", m.name, value)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s{%s} %s\n", m.name, key, value)
+		}
+	}
+}
+
+// Counter is a metric whose value only increases.
+type Counter struct {
+	m *metric
+}
+
+// NewCounter creates and registers a new counter with the given label names.
+func NewCounter(name string, help string, labelNames ...string) *Counter {
+	return &Counter{m: newMetric(name, help, typeCounter, labelNames)}
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+// Add increases the counter by the given non-negative value.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("Counter %q cannot be decreased", c.m.name))
+	}
+
+	c.m.add(value, labelValues)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.m.value(labelValues)
+}
+
+// Gauge is a metric whose value can increase and decrease.
+type Gauge struct {
+	m *metric
+}
+
+// NewGauge creates and registers a new gauge with the given label names.
+func NewGauge(name string, help string, labelNames ...string) *Gauge {
+	return &Gauge{m: newMetric(name, help, typeGauge, labelNames)}
+}
+
+// Set sets the gauge to the given value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.m.set(value, labelValues)
+}
+
+// Add adds the given value to the gauge. The value can be negative.
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.m.add(value, labelValues)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.m.value(labelValues)
+}
+
+// Handler returns an HTTP handler that exposes all registered metrics
+// in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registry.lock.Lock()
+		metrics := slices.Clone(registry.metrics)
+		registry.lock.Unlock()
+
+		slices.SortFunc(metrics, func(a *metric, b *metric) int {
+			return strings.Compare(a.name, b.name)
+		})
+
+		for _, m := range metrics {
+			m.write(w)
+		}
+	})
+}
+
+// Serve starts serving the metrics on the given listener under "/metrics".
+// It blocks until the listener is closed.
+func Serve(listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	err := server.Serve(listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
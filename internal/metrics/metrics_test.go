@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	counter := NewCounter("test_operations_total", "Number of test operations.", "pool", "result")
+	counter.Inc("default", "success")
+	counter.Inc("default", "success")
+	counter.Add(3, "remote", "error")
+
+	gauge := NewGauge("test_in_flight", "Number of in-flight test operations.")
+	gauge.Add(2)
+	gauge.Add(-1)
+
+	require.Equal(t, float64(2), counter.Value("default", "success"))
+	require.Equal(t, float64(1), gauge.Value())
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	require.Contains(t, string(body), "# TYPE test_in_flight gauge\ntest_in_flight 1\n")
+	require.Contains(t, string(body), "# TYPE test_operations_total counter\n")
+	require.Contains(t, string(body), `test_operations_total{pool="default",result="success"} 2`+"\n")
+	require.Contains(t, string(body), `test_operations_total{pool="remote",result="error"} 3`+"\n")
+}
+
+func TestMetricLabelMismatch(t *testing.T) {
+	counter := NewCounter("test_label_mismatch_total", "Counter with a single label.", "pool")
+
+	require.Panics(t, func() { counter.Inc() })
+	require.Panics(t, func() { counter.Add(-1, "default") })
+}
@@ -204,9 +204,13 @@ var _ = ginkgo.DescribeTableSubtree("[Volume binding mode]", func(driver string)
 
 			// Ensure the pod is running.
 			pod.WaitReady(ctx)
+			nodeName := pod.NodeName(ctx)
 
-			// Cleanup.
+			// Ensure the volume is detached from the node once the pod is removed.
 			pod.Delete(ctx)
+			pvc.WaitDetached(ctx, getLXDClient(), nodeName)
+
+			// Cleanup.
 			pvc.Delete(ctx)
 		},
 		ginkgo.SpecTimeout(5*time.Minute),
@@ -292,6 +296,57 @@ var _ = ginkgo.DescribeTableSubtree("[Volume binding mode]", func(driver string)
 	)
 }, getTestLXDStorageDrivers())
 
+var _ = ginkgo.DescribeTableSubtree("[Storage class validation]", func(driver string) {
+	var cfg *rest.Config
+	var namespace = "default"
+
+	ginkgo.BeforeEach(func() {
+		cfg = testutils.GetClientConfig()
+	})
+
+	ginkgo.It("Fail to provision a volume using invalid storage classes",
+		func(ctx ginkgo.SpecContext) {
+			poolName, cleanup := getTestLXDStoragePool(driver)
+			defer cleanup()
+
+			tests := []struct {
+				sc             specs.StorageClass
+				expectErrorMsg string
+			}{
+				{
+					sc:             specs.NewStorageClassWithUnknownParameter(cfg, "sc-unknown", poolName),
+					expectErrorMsg: `Invalid parameter "unknownParameter" in storage class`,
+				},
+				{
+					sc:             specs.NewStorageClassWithoutPool(cfg, "sc-no-pool"),
+					expectErrorMsg: `Storage class parameter "storagePool" is required`,
+				},
+			}
+
+			for _, test := range tests {
+				sc := test.sc.WithVolumeBindingMode(storagev1.VolumeBindingImmediate)
+				sc.Create(ctx)
+				defer sc.ForceDelete(context.Background())
+
+				pvc := specs.NewPersistentVolumeClaim(cfg, "pvc", namespace).WithStorageClassName(sc.Name)
+				pvc.Create(ctx)
+				defer pvc.ForceDelete(context.Background())
+
+				// Ensure provisioning fails with the expected error.
+				hasWarning := func(ctx context.Context) bool {
+					return len(pvc.WarningEvents(ctx, test.expectErrorMsg)) > 0
+				}
+
+				gomega.Eventually(hasWarning).WithContext(ctx).Should(gomega.BeTrue(), "PVC %q has no warning event containing %q\n%s", pvc.PrettyName(), test.expectErrorMsg, pvc.StateString(ctx))
+
+				// Cleanup.
+				pvc.Delete(ctx)
+			}
+		},
+		ginkgo.SpecTimeout(5*time.Minute),
+	)
+}, getTestLXDStorageDrivers())
+
 var _ = ginkgo.DescribeTableSubtree("[Volume read/write]", func(driver string) {
 	var cfg *rest.Config
 	var namespace = "default"
@@ -384,6 +439,20 @@ var _ = ginkgo.DescribeTableSubtree("[Volume read/write]", func(driver string) {
 			gomega.Expect(err).NotTo(gomega.HaveOccurred())
 			gomega.Expect(data).To(gomega.Equal(msg))
 
+			// Write to and read back from an offset past the start of the volume.
+			offset := int64(32 * 1024 * 1024)
+			err = pod.WriteDeviceAt(ctx, dev, offset, msg)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+			data, err = pod.ReadDeviceAt(ctx, dev, offset, len(msg))
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(data).To(gomega.Equal(msg))
+
+			// Ensure data at the start of the volume is not affected.
+			data, err = pod.ReadDevice(ctx, dev, len(msg))
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(data).To(gomega.Equal(msg))
+
 			// Cleanup.
 			pod.Delete(ctx)
 			pvc.Delete(ctx)
@@ -504,6 +573,51 @@ var _ = ginkgo.DescribeTableSubtree("[Volume access mode] ", func(driver string)
 		ginkgo.SpecTimeout(5*time.Minute),
 	)
 
+	ginkgo.It("Share FS volume between pods on the same node",
+		func(ctx ginkgo.SpecContext) {
+			poolName, cleanup := getTestLXDStoragePool(driver)
+			defer cleanup()
+
+			sc := specs.NewStorageClass(cfg, "sc", poolName)
+			sc.Create(ctx)
+			defer sc.ForceDelete(context.Background())
+
+			// Create FS PVC.
+			pvc := specs.NewPersistentVolumeClaim(cfg, "pvc", namespace).WithStorageClassName(sc.Name).WithAccessModes(corev1.ReadWriteOnce)
+			pvc.Create(ctx)
+			defer pvc.ForceDelete(context.Background())
+
+			// Create the first pod and wait for it to be scheduled.
+			pod1 := specs.NewPod(cfg, "pod", namespace).WithPVC(pvc, "/mnt/test")
+			pod1.Create(ctx)
+			defer pod1.ForceDelete(context.Background())
+			pod1.WaitReady(ctx)
+
+			// Create the second pod on the same node.
+			pod2 := specs.NewPod(cfg, "pod", namespace).WithPVC(pvc, "/mnt/test").WithNodeName(pod1.NodeName(ctx))
+			pod2.Create(ctx)
+			defer pod2.ForceDelete(context.Background())
+			pod2.WaitReady(ctx)
+
+			// Write to the volume from the first pod.
+			path := "/mnt/test/test.txt"
+			msg := []byte("This is a test of a shared FS volume.")
+			err := pod1.WriteFile(ctx, path, msg)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+			// Read the data from the second pod.
+			data, err := pod2.ReadFile(ctx, path)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(data).To(gomega.Equal(msg))
+
+			// Cleanup.
+			pod1.Delete(ctx)
+			pod2.Delete(ctx)
+			pvc.Delete(ctx)
+		},
+		ginkgo.SpecTimeout(5*time.Minute),
+	)
+
 	ginkgo.It("Create volume with access mode ReadWriteOncePod",
 		func(ctx ginkgo.SpecContext) {
 			requiresStandaloneLXD()
@@ -971,3 +1085,53 @@ var _ = ginkgo.DescribeTableSubtree("[Volume snapshots]", func(driver string) {
 		ginkgo.SpecTimeout(5*time.Minute),
 	)
 }, getTestLXDStorageDrivers())
+
+var _ = ginkgo.DescribeTableSubtree("[Volume concurrency]", func(driver string) {
+	var cfg *rest.Config
+	var namespace = "default"
+
+	ginkgo.BeforeEach(func() {
+		cfg = testutils.GetClientConfig()
+	})
+
+	ginkgo.It("Provision many volumes concurrently",
+		func(ctx ginkgo.SpecContext) {
+			requiresStandaloneLXD()
+
+			poolName, cleanup := getTestLXDStoragePool(driver)
+			defer cleanup()
+
+			sc := specs.NewStorageClass(cfg, "sc", poolName).
+				WithVolumeBindingMode(storagev1.VolumeBindingImmediate)
+			sc.Create(ctx)
+			defer sc.ForceDelete(context.Background())
+
+			// Create a batch of PVCs at once. The batch exceeds the default
+			// limit of 10 concurrent operations per storage pool, so that
+			// some of the requests wait for an operation slot.
+			pvcs := make([]specs.PersistentVolumeClaim, 20)
+			for i := range pvcs {
+				pvcs[i] = specs.NewPersistentVolumeClaim(cfg, "pvc", namespace).
+					WithStorageClassName(sc.Name).
+					WithSize("32Mi")
+
+				defer pvcs[i].ForceDelete(context.Background())
+			}
+
+			specs.CreatePersistentVolumeClaims(ctx, pvcs...)
+			specs.WaitBoundAll(ctx, pvcs...)
+
+			// Ensure no lock contention errors were reported to the user.
+			for _, pvc := range pvcs {
+				events := pvc.WarningEvents(ctx, "Aborted")
+				gomega.Expect(events).To(gomega.BeEmpty(), "PVC %q has unexpected Aborted warning events\n%s", pvc.PrettyName(), pvc.StateString(ctx))
+			}
+
+			// Cleanup.
+			for _, pvc := range pvcs {
+				pvc.Delete(ctx)
+			}
+		},
+		ginkgo.SpecTimeout(10*time.Minute),
+	)
+}, getTestLXDStorageDrivers())
@@ -68,6 +68,12 @@ func (p Pod) WithSecurityContext(securityContext *corev1.PodSecurityContext) Pod
 	return p
 }
 
+// WithNodeName schedules the Pod on the node with the given name.
+func (p Pod) WithNodeName(nodeName string) Pod {
+	p.Spec.NodeName = nodeName
+	return p
+}
+
 // WithPVC adds a PersistentVolumeClaim to the Pod's volumes.
 // The path is the mount path inside the container for filesystem volumes
 // and device path inside the container for block volumes.
@@ -90,6 +96,9 @@ func (p Pod) WithPVC(pvc PersistentVolumeClaim, path string) Pod {
 			})
 		} else {
 			// For block volumes, we use the device path.
+			err := checkDevicePathAvailable(p.Spec.Containers[0], path)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred(), "Cannot add PVC %q to Pod %q", pvc.Name, p.PrettyName())
+
 			p.Spec.Containers[0].VolumeDevices = append(p.Spec.Containers[0].VolumeDevices, corev1.VolumeDevice{
 				Name:       pvc.Name,
 				DevicePath: path,
@@ -100,6 +109,19 @@ func (p Pod) WithPVC(pvc PersistentVolumeClaim, path string) Pod {
 	return p
 }
 
+// checkDevicePathAvailable returns an error if the given device path is already
+// used by another block volume in the container. Kubernetes does not reject
+// such Pods upfront, which results in a Pod that never starts.
+func checkDevicePathAvailable(container corev1.Container, path string) error {
+	for _, device := range container.VolumeDevices {
+		if device.DevicePath == path {
+			return fmt.Errorf("Device path %q is already used by volume %q", path, device.Name)
+		}
+	}
+
+	return nil
+}
+
 // State returns the actual state of the Pod.
 func (p Pod) State(ctx context.Context) (*corev1.Pod, error) {
 	return p.client.CoreV1().Pods(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
@@ -270,30 +292,60 @@ func (p *Pod) ReadFile(ctx context.Context, path string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(strings.TrimSpace(out))
 }
 
-// WriteDevice writes raw bytes to a block device inside the Pod.
-// Data is base64-encoded before sending to avoid issues with shell quoting.
+// WriteDevice writes raw bytes to the start of a block device inside the Pod.
 func (p *Pod) WriteDevice(ctx context.Context, device string, data []byte) error {
-	ginkgo.By("Write " + strconv.Itoa(len(data)) + " bytes to device " + device + " in pod " + p.PrettyName())
+	return p.WriteDeviceAt(ctx, device, 0, data)
+}
+
+// WriteDeviceAt writes raw bytes to a block device inside the Pod starting
+// at the given byte offset.
+// Data is base64-encoded before sending to avoid issues with shell quoting.
+func (p *Pod) WriteDeviceAt(ctx context.Context, device string, offset int64, data []byte) error {
+	ginkgo.By("Write " + strconv.Itoa(len(data)) + " bytes at offset " + strconv.FormatInt(offset, 10) + " to device " + device + " in pod " + p.PrettyName())
 	b64 := base64.StdEncoding.EncodeToString(data)
 	script := fmt.Sprintf(`
 set -e
-echo %q | base64 -d | dd of=%q bs=1 conv=fsync,notrunc status=none
-`, b64, device)
+echo %q | base64 -d | dd of=%q bs=1 seek=%d conv=fsync,notrunc status=none
+`, b64, device, offset)
 
 	_, err := p.Exec(ctx, []string{"sh", "-c", script})
 	return err
 }
 
-// ReadDevice reads exactly n bytes from a block device inside the Pod.
+// ReadDevice reads exactly n bytes from the start of a block device inside the Pod.
 func (p *Pod) ReadDevice(ctx context.Context, device string, n int) ([]byte, error) {
-	ginkgo.By("Read " + strconv.Itoa(n) + " bytes from device " + device + " in pod " + p.PrettyName())
-	script := fmt.Sprintf(`dd if=%q bs=1 count=%d status=none | base64`, device, n)
+	return p.ReadDeviceAt(ctx, device, 0, n)
+}
+
+// ReadDeviceAt reads exactly n bytes from a block device inside the Pod
+// starting at the given byte offset. Regions that were never written are
+// returned as they are stored on the device (typically zeroes).
+func (p *Pod) ReadDeviceAt(ctx context.Context, device string, offset int64, n int) ([]byte, error) {
+	ginkgo.By("Read " + strconv.Itoa(n) + " bytes at offset " + strconv.FormatInt(offset, 10) + " from device " + device + " in pod " + p.PrettyName())
+	script := fmt.Sprintf(`dd if=%q bs=1 skip=%d count=%d status=none | base64`, device, offset, n)
 	out, err := p.Exec(ctx, []string{"sh", "-c", script})
 	if err != nil {
 		return nil, err
 	}
 
-	return base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) != n {
+		return nil, fmt.Errorf("Failed to read %d bytes at offset %d from device %q in pod %q: only %d bytes read", n, offset, device, p.PrettyName(), len(data))
+	}
+
+	return data, nil
+}
+
+// NodeName returns the name of the node the Pod is scheduled on.
+func (p Pod) NodeName(ctx context.Context) string {
+	state, err := p.State(ctx)
+	gomega.Expect(err).NotTo(gomega.HaveOccurred(), "Failed to get state of Pod %q\n%s", p.PrettyName(), p.StateString(ctx))
+	gomega.Expect(state.Spec.NodeName).NotTo(gomega.BeEmpty(), "Pod %q is not scheduled\n%s", p.PrettyName(), p.StateString(ctx))
+	return state.Spec.NodeName
 }
 
 // WaitReady waits until the Pod is in the Ready state.
@@ -0,0 +1,44 @@
+package specs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCheckDevicePathAvailable(t *testing.T) {
+	container := corev1.Container{
+		VolumeDevices: []corev1.VolumeDevice{
+			{Name: "pvc-1", DevicePath: "/dev/vda42"},
+		},
+	}
+
+	tests := []struct {
+		Name         string
+		path         string
+		expectErrMsg string
+	}{
+		{
+			Name: "Ensure unused device path is accepted",
+			path: "/dev/vda43",
+		},
+		{
+			Name:         "Ensure duplicate device path is rejected",
+			path:         "/dev/vda42",
+			expectErrMsg: `Device path "/dev/vda42" is already used by volume "pvc-1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			err := checkDevicePathAvailable(container, test.path)
+			if test.expectErrMsg != "" {
+				require.EqualError(t, err, test.expectErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
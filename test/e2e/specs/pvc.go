@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	"github.com/onsi/ginkgo/v2"
@@ -19,8 +20,13 @@ import (
 	"k8s.io/utils/ptr"
 
 	"github.com/canonical/lxd-csi-driver/test/testutils"
+	lxd "github.com/canonical/lxd/client"
 )
 
+// DefaultPVCAccessMode is the access mode of PersistentVolumeClaims created
+// using NewPersistentVolumeClaim.
+const DefaultPVCAccessMode = corev1.ReadWriteOnce
+
 // PersistentVolumeClaim represents a Kubernetes PersistentVolumeClaim.
 type PersistentVolumeClaim struct {
 	corev1.PersistentVolumeClaim
@@ -32,7 +38,8 @@ type PersistentVolumeClaim struct {
 }
 
 // NewPersistentVolumeClaim creates a new PersistentVolumeClaim with the given name and
-// namespace. By default, the size is set to 64MiB and access mode is set to ReadWriteOnce.
+// namespace. By default, the size is set to 64MiB and access mode is set to
+// DefaultPVCAccessMode (ReadWriteOnce).
 func NewPersistentVolumeClaim(cfg *rest.Config, name string, namespace string) PersistentVolumeClaim {
 	manifest := corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
@@ -41,7 +48,7 @@ func NewPersistentVolumeClaim(cfg *rest.Config, name string, namespace string) P
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{
-				corev1.ReadWriteOnce,
+				DefaultPVCAccessMode,
 			},
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
@@ -57,6 +64,12 @@ func NewPersistentVolumeClaim(cfg *rest.Config, name string, namespace string) P
 	}
 }
 
+// NewBlockPVC creates a new PersistentVolumeClaim with the given name and
+// namespace that requests a block volume.
+func NewBlockPVC(cfg *rest.Config, name string, namespace string) PersistentVolumeClaim {
+	return NewPersistentVolumeClaim(cfg, name, namespace).WithVolumeMode(corev1.PersistentVolumeBlock)
+}
+
 // PrettyName returns the string consisting of PersistentVolumeClaim's name and namespace.
 func (pvc PersistentVolumeClaim) PrettyName() string {
 	return prettyName(pvc.Namespace, pvc.Name)
@@ -164,6 +177,26 @@ func (pvc PersistentVolumeClaim) Create(ctx context.Context) {
 	gomega.Expect(err).NotTo(gomega.HaveOccurred(), "Failed to create PVC %q", pvc.PrettyName())
 }
 
+// CreatePersistentVolumeClaims creates the given PersistentVolumeClaims concurrently.
+func CreatePersistentVolumeClaims(ctx context.Context, pvcs ...PersistentVolumeClaim) {
+	var wg sync.WaitGroup
+	for _, pvc := range pvcs {
+		wg.Go(func() {
+			defer ginkgo.GinkgoRecover()
+			pvc.Create(ctx)
+		})
+	}
+
+	wg.Wait()
+}
+
+// WaitBoundAll waits until all given PersistentVolumeClaims are bound.
+func WaitBoundAll(ctx context.Context, pvcs ...PersistentVolumeClaim) {
+	for _, pvc := range pvcs {
+		pvc.WaitBound(ctx)
+	}
+}
+
 // Patch updates the PersistentVolumeClaim in the Kubernetes cluster.
 func (pvc *PersistentVolumeClaim) Patch(ctx context.Context) {
 	ginkgo.By("Update PersistentVolumeClaim " + pvc.PrettyName())
@@ -275,6 +308,58 @@ func (pvc PersistentVolumeClaim) WaitCondition(ctx context.Context, conditionTyp
 	gomega.Eventually(isCondMet).WithContext(ctx).Should(gomega.BeTrue(), "PVC %q condition %q did not reach %q\n%s", pvc.PrettyName(), conditionType, conditionStatus, pvc.StateString(ctx))
 }
 
+// LXDVolumeName returns the name of the LXD storage volume backing the PersistentVolumeClaim.
+// It is extracted from the volume handle of the bound PersistentVolume, which has
+// format "[<target>:]<pool>/<volume>".
+func (pvc PersistentVolumeClaim) LXDVolumeName(ctx context.Context) string {
+	state, err := pvc.State(ctx)
+	gomega.Expect(err).NotTo(gomega.HaveOccurred(), "Failed to get state of PVC %q\n%s", pvc.PrettyName(), pvc.StateString(ctx))
+	gomega.Expect(state.Spec.VolumeName).NotTo(gomega.BeEmpty(), "PVC %q is not bound\n%s", pvc.PrettyName(), pvc.StateString(ctx))
+
+	pv, err := pvc.client.CoreV1().PersistentVolumes().Get(ctx, state.Spec.VolumeName, metav1.GetOptions{})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred(), "Failed to get PV %q of PVC %q", state.Spec.VolumeName, pvc.PrettyName())
+	gomega.Expect(pv.Spec.CSI).NotTo(gomega.BeNil(), "PV %q is not a CSI volume", pv.Name)
+
+	_, volName, found := strings.Cut(pv.Spec.CSI.VolumeHandle, "/")
+	gomega.Expect(found).To(gomega.BeTrue(), "PV %q has unexpected volume handle format %q", pv.Name, pv.Spec.CSI.VolumeHandle)
+
+	return volName
+}
+
+// WaitDetached waits until the LXD storage volume backing the PersistentVolumeClaim
+// is no longer attached to the LXD instance with the given name.
+//
+// The volume name must be retrieved while the PVC is still bound, therefore the
+// function must be called before the PVC is deleted.
+func (pvc PersistentVolumeClaim) WaitDetached(ctx context.Context, client lxd.InstanceServer, instanceName string) {
+	volName := pvc.LXDVolumeName(ctx)
+
+	ginkgo.By("Wait for volume " + volName + " of PersistentVolumeClaim " + pvc.PrettyName() + " to be detached from instance " + instanceName)
+	volDetached := func(g gomega.Gomega) {
+		inst, _, err := client.GetInstance(instanceName)
+		g.Expect(err).NotTo(gomega.HaveOccurred(), "Failed to get LXD instance %q", instanceName)
+		g.Expect(inst.Devices).NotTo(gomega.HaveKey(volName), "Volume %q is still attached to instance %q", volName, instanceName)
+	}
+
+	gomega.Eventually(volDetached).WithContext(ctx).Should(gomega.Succeed(), "Volume of PVC %q is not detached from instance %q\n%s", pvc.PrettyName(), instanceName, pvc.StateString(ctx))
+}
+
+// WarningEvents returns the warning events related to the PersistentVolumeClaim
+// whose message contains the given substring.
+func (pvc PersistentVolumeClaim) WarningEvents(ctx context.Context, substring string) []corev1.Event {
+	events, err := pvc.Events(ctx)
+	gomega.Expect(err).NotTo(gomega.HaveOccurred(), "Failed to get events of PVC %q", pvc.PrettyName())
+
+	var result []corev1.Event
+	for _, e := range events.Items {
+		if e.Type == corev1.EventTypeWarning && strings.Contains(e.Message, substring) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
 // WaitGone waits until the PVC is no longer present in the Kubernetes cluster.
 func (pvc PersistentVolumeClaim) WaitGone(ctx context.Context) {
 	ginkgo.By("Wait for PersistentVolumeClaim " + pvc.PrettyName() + " to be gone")
@@ -49,6 +49,21 @@ func NewStorageClass(cfg *rest.Config, namePrefix string, lxdStoragePool string)
 	}
 }
 
+// NewStorageClassWithUnknownParameter creates a new StorageClass definition
+// that targets the given LXD storage pool, but contains a parameter that is
+// not supported by the driver. Volume provisioning using it must fail.
+func NewStorageClassWithUnknownParameter(cfg *rest.Config, namePrefix string, lxdStoragePool string) StorageClass {
+	return NewStorageClass(cfg, namePrefix, lxdStoragePool).WithParameters(map[string]string{
+		"unknownParameter": "value",
+	})
+}
+
+// NewStorageClassWithoutPool creates a new StorageClass definition that does
+// not specify the LXD storage pool. Volume provisioning using it must fail.
+func NewStorageClassWithoutPool(cfg *rest.Config, namePrefix string) StorageClass {
+	return NewStorageClass(cfg, namePrefix, "").WithRawParameters(nil)
+}
+
 // PrettyName returns the string consisting of StorageClass's name.
 func (sc StorageClass) PrettyName() string {
 	return prettyName(sc.Namespace, sc.Name)
@@ -64,6 +79,14 @@ func (sc StorageClass) WithParameters(params map[string]string) StorageClass {
 	return sc
 }
 
+// WithRawParameters replaces all parameters of the StorageClass, including
+// the storage pool, with the given ones. Unlike [StorageClass.WithParameters],
+// it allows constructing invalid StorageClasses for negative tests.
+func (sc StorageClass) WithRawParameters(params map[string]string) StorageClass {
+	sc.Parameters = maps.Clone(params)
+	return sc
+}
+
 // WithVolumeBindingMode sets the volume binding mode for the StorageClass.
 func (sc StorageClass) WithVolumeBindingMode(mode storagev1.VolumeBindingMode) StorageClass {
 	sc.VolumeBindingMode = &mode